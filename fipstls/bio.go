@@ -1,9 +1,13 @@
 package fipstls
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"syscall"
+	"time"
 
 	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
 )
@@ -17,6 +21,16 @@ type BIO struct {
 	sockfd     int
 	localAddr  net.Addr
 	remoteAddr net.Addr
+	// file is a duplicate of sockfd registered with the Go netpoller, used to wait for the
+	// socket to become readable or writable. It is nil for blocking sockets.
+	file    *os.File
+	rawConn syscall.RawConn
+	// conn is the connection read from and written to by a [BIO] created with [NewConnBIO].
+	conn net.Conn
+	// reader buffers the data read from conn for libssl.
+	reader *connReader
+	// attached is set once bio is attached to an SSL object, which frees it.
+	attached bool
 }
 
 func (b *BIO) String() string {
@@ -32,94 +46,161 @@ const (
 )
 
 // NewBIO will create a new [libssl.BIO] connected to the host. It can be either blocking (mode=0)
-// or non-blocking (mode=1).
+// or non-blocking (mode=1). The connection is established with a zero [net.Dialer].
 func NewBIO(addr, network string, mode int) (b *BIO, err error) {
+	return dialSocketBIO(context.Background(), &net.Dialer{}, network, addr, mode)
+}
+
+// dialSocketBIO connects to addr with nd, and creates a socket [BIO] for the connection. The
+// hostname of the [BIO] is the one of addr, rather than the address it resolved to. The address
+// of a Unix domain socket is its path, or its name prefixed with '@' for a Linux abstract
+// socket, and has no hostname.
+func dialSocketBIO(ctx context.Context, nd *net.Dialer, network, addr string,
+	mode int) (*BIO, error) {
+	b := &BIO{closer: noopCloser{}, sockfd: -1}
 	if !libsslInit {
-		return nil, ErrNoLibSslInit
+		return b, ErrNoLibSslInit
 	}
-	b = &BIO{closer: noopCloser{}}
-	b.hostname, b.port, err = net.SplitHostPort(addr)
+	family, err := parseNetwork(network)
 	if err != nil {
 		return b, err
 	}
-	family, err := parseNetwork(network)
+	var hostname, port string
+	if family != syscall.AF_UNIX {
+		if hostname, port, err = net.SplitHostPort(addr); err != nil {
+			return b, err
+		}
+	}
+	conn, err := nd.DialContext(ctx, network, addr)
 	if err != nil {
 		return b, err
 	}
-	b.bio, b.sockfd, err = libssl.CreateBIO(b.hostname, b.port, family, mode)
+	b, err = NewSocketBIO(conn, mode)
+	b.hostname, b.port = hostname, port
+	return b, err
+}
+
+// NewSocketBIO will create a new [libssl.BIO] for the socket of conn, which must be backed by a
+// socket like the connections of the net package. It can be either blocking (mode=0) or
+// non-blocking (mode=1). Unlike [NewConnBIO], libssl reads from and writes to the socket
+// directly, which kTLS requires. The [BIO] takes ownership of the socket, and conn is closed.
+func NewSocketBIO(conn net.Conn, mode int) (b *BIO, err error) {
+	defer conn.Close()
+	b = &BIO{
+		closer:     noopCloser{},
+		sockfd:     -1,
+		localAddr:  conn.LocalAddr(),
+		remoteAddr: conn.RemoteAddr(),
+	}
+	if b.remoteAddr != nil {
+		b.hostname, b.port, _ = net.SplitHostPort(b.remoteAddr.String())
+	}
+	if !libsslInit {
+		return b, ErrNoLibSslInit
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return b, ErrNoSocket
+	}
+	rawConn, err := sc.SyscallConn()
 	if err != nil {
 		return b, err
 	}
-	if err := b.setAddrInfo(); err != nil {
+	// libssl gets its own descriptor, as conn closes the one of the net package.
+	var dupErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		b.sockfd, dupErr = syscall.Dup(int(fd))
+	}); err != nil {
+		return b, err
+	}
+	if dupErr != nil {
+		b.sockfd = -1
+		return b, os.NewSyscallError("dup", dupErr)
+	}
+	syscall.CloseOnExec(b.sockfd)
+	b.bio, err = libssl.NewSocketBIO(b.sockfd, mode)
+	if err != nil {
+		syscall.Close(b.sockfd)
 		return b, err
 	}
 	b.closer = newOnceCloser(func() error {
-		if b.bio == nil {
+		b.closeIO()
+		if b.attached {
 			return nil
 		}
 		return libssl.BIOFree(b.bio)
 	})
-	return b, nil
-}
-
-func parseNetwork(network string) (int, error) {
-	switch network {
-	case "tcp", "tcp4":
-		return syscall.AF_INET, nil
-	case "tcp6":
-		return syscall.AF_INET6, nil
-	case "unix":
-		return syscall.AF_UNIX, nil
-	default:
-		return 0, net.UnknownNetworkError(network)
+	if mode == SOCK_NONBLOCK {
+		if err := b.register(); err != nil {
+			return b, err
+		}
 	}
+	return b, nil
 }
 
-// setAddrInfo initializes the local and remote addresses of the [BIO] socket connection.
-func (b *BIO) setAddrInfo() (err error) {
-	sockname, err := syscall.Getsockname(b.sockfd)
+// register adds the non-blocking socket to the Go netpoller. The socket is duplicated so that
+// the runtime and libssl can each close their descriptor independently.
+func (b *BIO) register() error {
+	fd, err := syscall.Dup(b.sockfd)
 	if err != nil {
 		return err
 	}
-	peername, err := syscall.Getpeername(b.sockfd)
+	syscall.CloseOnExec(fd)
+	b.file = os.NewFile(uintptr(fd), fmt.Sprintf("fipstls:%d", b.sockfd))
+	b.rawConn, err = b.file.SyscallConn()
 	if err != nil {
+		b.closeIO()
 		return err
 	}
-	b.localAddr, b.remoteAddr = sockaddrToNetAddr(sockname), sockaddrToNetAddr(peername)
 	return nil
 }
 
-func sockaddrToNetAddr(sa syscall.Sockaddr) net.Addr {
-	switch sa := sa.(type) {
-	case *syscall.SockaddrInet4:
-		return &net.TCPAddr{
-			IP:   sa.Addr[:],
-			Port: int(sa.Port),
-		}
-	case *syscall.SockaddrInet6:
-		return &net.TCPAddr{
-			IP:   sa.Addr[:],
-			Port: int(sa.Port),
-			Zone: zoneToString(int(sa.ZoneId)),
+// NewConnBIO will create a new [libssl.BIO] that reads from and writes to conn, such as a proxy
+// tunnel or a connection upgraded with STARTTLS. The [BIO] takes ownership of conn, which is
+// closed with the [BIO] or the [Conn] using it. It requires OpenSSL 1.1.0 or later.
+func NewConnBIO(conn net.Conn) (b *BIO, err error) {
+	b = &BIO{
+		sockfd:     -1,
+		conn:       conn,
+		localAddr:  conn.LocalAddr(),
+		remoteAddr: conn.RemoteAddr(),
+	}
+	b.closer = newOnceCloser(func() error {
+		err := b.closeIO()
+		if b.bio == nil || b.attached {
+			return err
 		}
-	case *syscall.SockaddrUnix:
-		return &net.UnixAddr{
-			Name: sa.Name,
-			Net:  "unix",
+		if ferr := libssl.BIOFree(b.bio); ferr != nil {
+			return ferr
 		}
-	default:
-		return nil
+		return err
+	})
+	if b.remoteAddr != nil {
+		// Addresses without a port, such as the ones of net.Pipe, have no hostname.
+		b.hostname, b.port, _ = net.SplitHostPort(b.remoteAddr.String())
+	}
+	if !libsslInit {
+		return b, ErrNoLibSslInit
+	}
+	b.reader = &connReader{conn: conn}
+	b.bio, err = libssl.NewGoBIO(b.reader)
+	if err != nil {
+		return b, err
 	}
+	return b, nil
 }
 
-func zoneToString(zone int) string {
-	if zone == 0 {
-		return ""
-	}
-	if ifi, err := net.InterfaceByIndex(zone); err == nil {
-		return ifi.Name
+func parseNetwork(network string) (int, error) {
+	switch network {
+	case "tcp", "tcp4":
+		return syscall.AF_INET, nil
+	case "tcp6":
+		return syscall.AF_INET6, nil
+	case "unix":
+		return syscall.AF_UNIX, nil
+	default:
+		return 0, net.UnknownNetworkError(network)
 	}
-	return fmt.Sprintf("%d", zone)
 }
 
 // BIO returns a pointer to the underlying [libssl.BIO] C object.
@@ -142,17 +223,175 @@ func (b *BIO) RemoteAddr() net.Addr {
 	return b.remoteAddr
 }
 
-// FD returns the socket file descriptor.
+// FD returns the socket file descriptor, or -1 if the [BIO] was created with [NewConnBIO].
 func (b *BIO) FD() int {
 	return b.sockfd
 }
 
-// CloseFD will close the socket file descriptor.
+// CloseFD will close the socket file descriptor, or the connection if the [BIO] was created with
+// [NewConnBIO].
 func (b *BIO) CloseFD() error {
+	if b.conn != nil {
+		return b.conn.Close()
+	}
+	b.closeIO()
 	return syscall.Close(b.sockfd)
 }
 
-// Close frees the [libssl.BIO] object allocated for [BIO].
+// closeIO removes the socket from the netpoller or closes the connection, waking up any
+// goroutine waiting on it.
+func (b *BIO) closeIO() error {
+	switch {
+	case b.conn != nil:
+		return b.conn.Close()
+	case b.file != nil:
+		return b.file.Close()
+	}
+	return nil
+}
+
+// pollRead calls f until it returns true, parking the goroutine in the netpoller until the
+// socket is readable in between calls. It fails if the read deadline expires or the [BIO] is
+// closed.
+func (b *BIO) pollRead(f func() bool) error {
+	if b.conn != nil {
+		return b.pollConn(f, libssl.BIOTakeReadError, b.reader.fill)
+	}
+	if b.rawConn == nil {
+		return errBlockingBIO
+	}
+	return pollErr(b.rawConn.Read(func(uintptr) bool { return f() }))
+}
+
+// pollWrite calls f until it returns true, parking the goroutine in the netpoller until the
+// socket is writable in between calls. It fails if the write deadline expires or the [BIO] is
+// closed.
+func (b *BIO) pollWrite(f func() bool) error {
+	if b.conn != nil {
+		return b.pollConn(f, libssl.BIOTakeWriteError, nil)
+	}
+	if b.rawConn == nil {
+		return errBlockingBIO
+	}
+	return pollErr(b.rawConn.Write(func(uintptr) bool { return f() }))
+}
+
+// pollConn calls f until it returns true, calling wait in between calls if it is not nil. Reads
+// of a [BIO] created with [NewConnBIO] wait for data from the connection in wait, while writes
+// block in the connection. The error of a failed connection, typically because its deadline
+// expired, is returned instead of calling f again.
+func (b *BIO) pollConn(f func() bool, takeErr func(*libssl.BIO) error, wait func() error) error {
+	for {
+		if err := takeErr(b.bio); err != nil {
+			return err
+		}
+		if wait != nil {
+			if err := wait(); err != nil {
+				return err
+			}
+		}
+		if f() {
+			return nil
+		}
+	}
+}
+
+// write writes all of p to the socket or connection, waiting for the socket to become writable
+// if needed. It is used without holding the SSL object to send the records libssl queued.
+func (b *BIO) write(p []byte) (int, error) {
+	switch {
+	case b.conn != nil:
+		return b.conn.Write(p)
+	case b.rawConn == nil:
+		n, err := writeFD(b.sockfd, p)
+		return n, b.writeError(err)
+	}
+	var written int
+	var werr error
+	err := b.rawConn.Write(func(fd uintptr) bool {
+		var n int
+		n, werr = writeFD(int(fd), p[written:])
+		written += n
+		return werr != syscall.EAGAIN
+	})
+	if err != nil {
+		return written, pollErr(err)
+	}
+	return written, b.writeError(werr)
+}
+
+// writeError wraps the error of a failed socket write like the net package does.
+func (b *BIO) writeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	opErr := &net.OpError{Op: opWrite, Addr: b.remoteAddr, Err: os.NewSyscallError("write", err)}
+	if b.remoteAddr != nil {
+		opErr.Net = b.remoteAddr.Network()
+	}
+	return opErr
+}
+
+// writeFD writes p to the socket fd until it is written or the write fails.
+func writeFD(fd int, p []byte) (int, error) {
+	var written int
+	for written < len(p) {
+		n, err := syscall.Write(fd, p[written:])
+		if n > 0 {
+			written += n
+		}
+		switch {
+		case err == syscall.EINTR:
+		case err != nil:
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// takeError returns and clears the error that failed the last read or write of a [BIO] created
+// with [NewConnBIO].
+func (b *BIO) takeError() error {
+	if err := libssl.BIOTakeReadError(b.bio); err != nil {
+		return err
+	}
+	return libssl.BIOTakeWriteError(b.bio)
+}
+
+// setReadDeadline sets the deadline for goroutines in pollRead.
+func (b *BIO) setReadDeadline(t time.Time) error {
+	if b.conn != nil {
+		return b.conn.SetReadDeadline(t)
+	}
+	if b.file == nil {
+		return nil
+	}
+	return b.file.SetReadDeadline(t)
+}
+
+// setWriteDeadline sets the deadline for goroutines in pollWrite.
+func (b *BIO) setWriteDeadline(t time.Time) error {
+	if b.conn != nil {
+		return b.conn.SetWriteDeadline(t)
+	}
+	if b.file == nil {
+		return nil
+	}
+	return b.file.SetWriteDeadline(t)
+}
+
+var errBlockingBIO = errors.New("fipstls: cannot wait on a blocking BIO")
+
+// pollErr maps the errors returned by the netpoller to their net package equivalents.
+func pollErr(err error) error {
+	if err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	return net.ErrClosed
+}
+
+// Close frees the [libssl.BIO] object allocated for [BIO], unless it is owned by the SSL object
+// of a [Conn], and closes the socket or connection.
 func (b *BIO) Close() error {
 	return b.closer.Close()
 }
@@ -0,0 +1,172 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// newEchoListener returns a crypto/tls listener echoing the data received on each connection,
+// so that the memory of the server is not allocated by libssl.
+func newEchoListener(tb testing.TB, version uint16) net.Listener {
+	tb.Helper()
+	return listenEcho(tb, "tcp4", "127.0.0.1:0", version)
+}
+
+// listenEcho returns a crypto/tls listener on addr echoing the data of its connections.
+func listenEcho(tb testing.TB, network, addr string, version uint16) net.Listener {
+	tb.Helper()
+	cert, err := tls.LoadX509KeyPair(testutils.CertPath, testKeyPath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ln, err := tls.Listen(network, addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   version,
+		MaxVersion:   version,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+// dialEcho dials the echo listener ln and checks that a message is echoed.
+func dialEcho(tb testing.TB, ln net.Listener, config *fipstls.Config) *fipstls.Conn {
+	tb.Helper()
+	c, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	conn := fipstls.Client(c, config)
+	msg := []byte("hello")
+	if _, err := conn.Write(msg); err != nil {
+		conn.Close()
+		tb.Fatalf("Write() failed: %v", err)
+	}
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, got); err != nil || !bytes.Equal(got, msg) {
+		conn.Close()
+		tb.Fatalf("Read() = %q, %v, want %q", got, err, msg)
+	}
+	return conn
+}
+
+func TestBufferModes(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	for _, tc := range []struct {
+		name   string
+		config fipstls.Config
+	}{
+		{name: "ReleaseBuffers", config: fipstls.Config{ReleaseBuffers: true}},
+		{name: "ReadAhead", config: fipstls.Config{ReadAhead: true}},
+		{name: "ReadBufferLen", config: fipstls.Config{ReadAhead: true, ReadBufferLen: 64 << 10}},
+		{name: "all", config: fipstls.Config{ReleaseBuffers: true, ReadAhead: true,
+			ReadBufferLen: 4096}},
+	} {
+		for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+			t.Run(fmt.Sprintf("%s version %x", tc.name, version), func(t *testing.T) {
+				ln := newEchoListener(t, version)
+				defer ln.Close()
+				config := tc.config
+				config.CaFile = testutils.CertPath
+				config.ServerName = "localhost"
+				conn := dialEcho(t, ln, &config)
+				defer conn.Close()
+
+				// Echo messages of many small records and of full records, so that the
+				// buffers are released and allocated again between them.
+				for _, size := range []int{10, 100000} {
+					want := bytes.Repeat([]byte{'x'}, size)
+					errCh := make(chan error, 1)
+					go func() {
+						_, err := conn.Write(want)
+						errCh <- err
+					}()
+					got := make([]byte, size)
+					if _, err := io.ReadFull(conn, got); err != nil {
+						t.Fatalf("Read() failed: %v", err)
+					}
+					if err := <-errCh; err != nil {
+						t.Fatalf("Write() failed: %v", err)
+					}
+					if !bytes.Equal(got, want) {
+						t.Fatalf("Echoed %d bytes differ", size)
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("invalid ReadBufferLen", func(t *testing.T) {
+		_, err := fipstls.NewCtx(&fipstls.Config{ReadBufferLen: -1})
+		if !errors.Is(err, fipstls.ErrInvalidRecordSize) {
+			t.Errorf("NewCtx() err = %v, want %v", err, fipstls.ErrInvalidRecordSize)
+		}
+	})
+}
+
+// BenchmarkIdleConnMemory reports the C heap memory held by each idle client connection, after
+// it has echoed a message, with the default buffers and with the buffer modes of [Config].
+func BenchmarkIdleConnMemory(b *testing.B) {
+	if !testutils.LimitCHeapArenas() {
+		b.Skip("The C heap cannot be measured")
+	}
+	initTest(nil)
+	const conns = 100
+	for _, tc := range []struct {
+		name   string
+		config fipstls.Config
+	}{
+		{name: "default"},
+		{name: "ReleaseBuffers", config: fipstls.Config{ReleaseBuffers: true}},
+		{name: "ReadAhead", config: fipstls.Config{ReadAhead: true}},
+		{name: "ReleaseBuffers ReadAhead", config: fipstls.Config{ReleaseBuffers: true,
+			ReadAhead: true}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			ln := newEchoListener(b, tls.VersionTLS13)
+			defer ln.Close()
+			config := tc.config
+			config.CaFile = testutils.CertPath
+			config.ServerName = "localhost"
+			var total float64
+			for i := 0; i < b.N; i++ {
+				before, _ := testutils.CHeapInUse()
+				open := make([]*fipstls.Conn, 0, conns)
+				for j := 0; j < conns; j++ {
+					open = append(open, dialEcho(b, ln, &config))
+				}
+				after, _ := testutils.CHeapInUse()
+				total += float64(after) - float64(before)
+				b.StopTimer()
+				for _, conn := range open {
+					conn.Close()
+				}
+				b.StartTimer()
+			}
+			b.ReportMetric(total/float64(b.N*conns), "C-bytes/conn")
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package fipstls
+
+import (
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// SignatureScheme is a TLS signature scheme code point as defined in RFC 8446, Section 4.2.3.
+// The values match crypto/tls.SignatureScheme.
+type SignatureScheme uint16
+
+// CertificateRequestInfo contains information from a server's CertificateRequest, which is
+// used by [Config.GetClientCertificate] to select a certificate.
+type CertificateRequestInfo struct {
+	// AcceptableCAs are the DER-encoded X.501 distinguished names of the certificate
+	// authorities the server accepts. It is empty if the server accepts any.
+	AcceptableCAs [][]byte
+
+	// SignatureSchemes are the signature algorithms the server accepts.
+	SignatureSchemes []SignatureScheme
+}
+
+// Certificate is a certificate chain and the private key of its leaf certificate.
+type Certificate struct {
+	// Certificate is the DER-encoded certificate chain, leaf first.
+	Certificate [][]byte
+
+	// PrivateKey is the DER-encoded private key of the leaf certificate in PKCS #8, PKCS #1 or
+	// SEC 1 form.
+	PrivateKey []byte
+}
+
+// LoadCertificate reads a certificate chain and private key from a pair of PEM files.
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cert := &Certificate{}
+	for {
+		var block *pem.Block
+		block, certPEM = pem.Decode(certPEM)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, fmt.Errorf("fipstls: no certificate found in %s", certFile)
+	}
+	for {
+		var block *pem.Block
+		block, keyPEM = pem.Decode(keyPEM)
+		if block == nil {
+			break
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			cert.PrivateKey = block.Bytes
+			return cert, nil
+		}
+	}
+	return nil, fmt.Errorf("fipstls: no private key found in %s", keyFile)
+}
+
+// getClientCertificate wraps [Config.GetClientCertificate] for the libssl callbacks.
+func (c *Conn) getClientCertificate(req *libssl.CertificateRequest) (*libssl.Certificate, error) {
+	info := &CertificateRequestInfo{AcceptableCAs: req.CANames}
+	for _, alg := range req.SigAlgs {
+		info.SignatureSchemes = append(info.SignatureSchemes, SignatureScheme(alg))
+	}
+	c.l.Logf(LogLevelDebug, "Certificate requested by server with %d acceptable CAs",
+		len(info.AcceptableCAs))
+	cert, err := c.config.GetClientCertificate(info)
+	if err != nil {
+		c.l.Logf(LogLevelErr, "GetClientCertificate failed: %v", err)
+		return nil, err
+	}
+	if cert == nil || len(cert.Certificate) == 0 {
+		c.l.Logf(LogLevelInfo, "No client certificate selected")
+		return nil, nil
+	}
+	if len(cert.PrivateKey) == 0 {
+		return nil, errors.New("fipstls: client certificate has no private key")
+	}
+	return &libssl.Certificate{Chain: cert.Certificate, Key: cert.PrivateKey}, nil
+}
@@ -0,0 +1,133 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// newClientAuthListener returns a crypto/tls listener that requires a client certificate and
+// advertises the test certificate as the acceptable CA.
+func newClientAuthListener(t *testing.T, version uint16) net.Listener {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(testutils.CertPath, testKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(parseCertFile(t, testutils.CertPath))
+	ln, err := tls.Listen("tcp4", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   version,
+		MaxVersion:   version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+func TestGetClientCertificate(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	want := parseCertFile(t, testutils.CertPath)
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		t.Run(fmt.Sprintf("version %x", version), func(t *testing.T) {
+			ln := newClientAuthListener(t, version)
+			defer ln.Close()
+			errCh := serveTLS(ln, func(state tls.ConnectionState) ([]byte, error) {
+				if len(state.PeerCertificates) == 0 {
+					return nil, errors.New("no client certificate")
+				}
+				return state.PeerCertificates[0].Raw[:32], nil
+			})
+
+			var info *fipstls.CertificateRequestInfo
+			conn := dialListener(t, ln, &fipstls.Config{
+				CaFile: testutils.CertPath,
+				GetClientCertificate: func(
+					i *fipstls.CertificateRequestInfo) (*fipstls.Certificate, error) {
+					info = i
+					return fipstls.LoadCertificate(testutils.CertPath, testKeyPath)
+				},
+			})
+			defer conn.Close()
+			got := make([]byte, 32)
+			if _, err := conn.Read(got); err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("Server failed: %v", err)
+			}
+			if !bytes.Equal(got, want.Raw[:32]) {
+				t.Error("Server received the wrong client certificate")
+			}
+			if info == nil {
+				t.Fatal("GetClientCertificate was not called")
+			}
+			if len(info.AcceptableCAs) != 1 || !bytes.Equal(info.AcceptableCAs[0], want.RawSubject) {
+				t.Errorf("AcceptableCAs = %x, want [%x]", info.AcceptableCAs, want.RawSubject)
+			}
+			if len(info.SignatureSchemes) == 0 {
+				t.Error("SignatureSchemes is empty")
+			}
+		})
+	}
+}
+
+func TestGetClientCertificateError(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	ln := newClientAuthListener(t, tls.VersionTLS13)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	errNoCert := errors.New("no certificate for this server")
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	d := fipstls.NewDialer(&fipstls.Config{
+		CaFile: testutils.CertPath,
+		GetClientCertificate: func(*fipstls.CertificateRequestInfo) (*fipstls.Certificate, error) {
+			return nil, errNoCert
+		},
+	}, getFipsDialOpts()...)
+	conn, err := d.DialContext(context.Background(), "tcp4", net.JoinHostPort("localhost", port))
+	if err == nil {
+		conn.Close()
+		t.Fatal("DialContext() succeeded")
+	}
+	if !errors.Is(err, errNoCert) {
+		t.Errorf("DialContext() = %v, want %v", err, errNoCert)
+	}
+}
+
+func TestLoadCertificate(t *testing.T) {
+	cert, err := fipstls.LoadCertificate(testutils.CertPath, testKeyPath)
+	if err != nil {
+		t.Fatalf("LoadCertificate() failed: %v", err)
+	}
+	if len(cert.Certificate) != 1 || len(cert.PrivateKey) == 0 {
+		t.Errorf("LoadCertificate() = %d certificates, %d key bytes", len(cert.Certificate),
+			len(cert.PrivateKey))
+	}
+	if _, err := fipstls.LoadCertificate(testKeyPath, testKeyPath); err == nil {
+		t.Error("LoadCertificate() without a certificate succeeded")
+	}
+}
@@ -0,0 +1,126 @@
+package fipstls
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// ChannelBindingType is a TLS channel binding type registered for use with SASL mechanisms such
+// as SCRAM-SHA-256-PLUS.
+type ChannelBindingType string
+
+const (
+	// ChannelBindingTLSExporter is the tls-exporter channel binding (RFC 9266). It requires
+	// TLS 1.3 or the Extended Master Secret extension.
+	ChannelBindingTLSExporter ChannelBindingType = "tls-exporter"
+
+	// ChannelBindingTLSServerEndPoint is the tls-server-end-point channel binding (RFC 5929),
+	// a hash of the server certificate.
+	ChannelBindingTLSServerEndPoint ChannelBindingType = "tls-server-end-point"
+
+	// ChannelBindingTLSUnique is the tls-unique channel binding (RFC 5929), the first Finished
+	// message of the handshake. It is undefined for TLS 1.3.
+	ChannelBindingTLSUnique ChannelBindingType = "tls-unique"
+)
+
+// tlsExporterLabel is the exporter label of the tls-exporter channel binding.
+const tlsExporterLabel = "EXPORTER-Channel-Binding"
+
+// ChannelBinding returns the channel binding data of the given type. It returns an error
+// wrapping [ErrChannelBindingUnsupported] if the type is not defined for the connection.
+func (c *Conn) ChannelBinding(kind ChannelBindingType) ([]byte, error) {
+	if c.closed.Load() {
+		return nil, net.ErrClosed
+	}
+	if !c.handshakeComplete.Load() {
+		return nil, ErrHandshakeIncomplete
+	}
+	switch kind {
+	case ChannelBindingTLSExporter:
+		cb, err := c.ExportKeyingMaterial(tlsExporterLabel, []byte{}, 32)
+		if errors.Is(err, ErrEKMUnavailable) {
+			return nil, fmt.Errorf("%w: %s requires TLS 1.3 or Extended Master Secret",
+				ErrChannelBindingUnsupported, kind)
+		}
+		return cb, err
+	case ChannelBindingTLSServerEndPoint:
+		return c.tlsServerEndPoint()
+	case ChannelBindingTLSUnique:
+		return c.tlsUnique()
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrChannelBindingUnsupported, kind)
+	}
+}
+
+// tlsServerEndPoint hashes the server certificate with the hash of its signature algorithm,
+// or SHA-256 if that is MD5 or SHA-1, as defined in RFC 5929, Section 4.1.
+func (c *Conn) tlsServerEndPoint() ([]byte, error) {
+	var der []byte
+	err := c.withSSL(func() (err error) {
+		getCert := libssl.SSLGetPeerCertificate
+		if libssl.SSLIsServer(c.ssl) {
+			getCert = libssl.SSLGetCertificate
+		}
+		der, err = getCert(c.ssl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if der == nil {
+		return nil, fmt.Errorf("%w: %s requires a server certificate",
+			ErrChannelBindingUnsupported, ChannelBindingTLSServerEndPoint)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	var h crypto.Hash
+	switch cert.SignatureAlgorithm {
+	case x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1,
+		x509.SHA256WithRSA, x509.DSAWithSHA256, x509.ECDSAWithSHA256, x509.SHA256WithRSAPSS:
+		h = crypto.SHA256
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		h = crypto.SHA384
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		h = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("%w: %s is undefined for signature algorithm %v",
+			ErrChannelBindingUnsupported, ChannelBindingTLSServerEndPoint,
+			cert.SignatureAlgorithm)
+	}
+	hash := h.New()
+	hash.Write(der)
+	return hash.Sum(nil), nil
+}
+
+// tlsUnique returns the first Finished message of the latest handshake, which is sent by the
+// client in a full handshake and by the server in a resumed one.
+func (c *Conn) tlsUnique() ([]byte, error) {
+	state := c.ConnectionState()
+	if state.Version >= Version13 {
+		return nil, fmt.Errorf("%w: %s is undefined for TLS 1.3", ErrChannelBindingUnsupported,
+			ChannelBindingTLSUnique)
+	}
+	// Resumed sessions without Extended Master Secret are vulnerable to the triple handshake
+	// attack, see RFC 7627, Section 5.4.
+	var finished []byte
+	err := c.withSSL(func() error {
+		if state.DidResume && !libssl.SSLExtmsSupport(c.ssl) {
+			return fmt.Errorf("%w: %s is undefined for resumed sessions without Extended "+
+				"Master Secret", ErrChannelBindingUnsupported, ChannelBindingTLSUnique)
+		}
+		if libssl.SSLIsServer(c.ssl) == state.DidResume {
+			finished = libssl.SSLGetFinished(c.ssl)
+		} else {
+			finished = libssl.SSLGetPeerFinished(c.ssl)
+		}
+		return nil
+	})
+	return finished, err
+}
@@ -0,0 +1,87 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+func TestChannelBinding(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	exporter := func(state tls.ConnectionState) ([]byte, error) {
+		return state.ExportKeyingMaterial("EXPORTER-Channel-Binding", []byte{}, 32)
+	}
+	// The test certificate is signed with a SHA-256 signature algorithm
+	serverEndPoint := sha256.Sum256(parseCertFile(t, testutils.CertPath).Raw)
+	testCases := []struct {
+		kind    fipstls.ChannelBindingType
+		version uint16
+		// want returns the channel binding computed by the crypto/tls server, or is nil if
+		// the channel binding is unsupported.
+		want func(tls.ConnectionState) ([]byte, error)
+	}{
+		{kind: fipstls.ChannelBindingTLSExporter, version: tls.VersionTLS12, want: exporter},
+		{kind: fipstls.ChannelBindingTLSExporter, version: tls.VersionTLS13, want: exporter},
+		{
+			kind:    fipstls.ChannelBindingTLSServerEndPoint,
+			version: tls.VersionTLS13,
+			want: func(tls.ConnectionState) ([]byte, error) {
+				return serverEndPoint[:], nil
+			},
+		},
+		{
+			kind:    fipstls.ChannelBindingTLSUnique,
+			version: tls.VersionTLS12,
+			want: func(state tls.ConnectionState) ([]byte, error) {
+				return state.TLSUnique, nil
+			},
+		},
+		{kind: fipstls.ChannelBindingTLSUnique, version: tls.VersionTLS13},
+		{kind: "tls-bogus", version: tls.VersionTLS13},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s version %x", tc.kind, tc.version), func(t *testing.T) {
+			ln := newTLSListener(t, tc.version)
+			defer ln.Close()
+			errCh := serveTLS(ln, func(state tls.ConnectionState) ([]byte, error) {
+				if tc.want == nil {
+					return nil, nil
+				}
+				return tc.want(state)
+			})
+			conn := dialListener(t, ln, &fipstls.Config{CaFile: testutils.CertPath})
+			defer conn.Close()
+
+			got, err := conn.ChannelBinding(tc.kind)
+			if tc.want == nil {
+				if !errors.Is(err, fipstls.ErrChannelBindingUnsupported) {
+					t.Fatalf("ChannelBinding() err = %v, want %v", err,
+						fipstls.ErrChannelBindingUnsupported)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChannelBinding() failed: %v", err)
+			}
+			want := make([]byte, len(got))
+			if _, err := io.ReadFull(conn, want); err != nil {
+				t.Fatalf("Failed to read server channel binding: %v", err)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("Server failed: %v", err)
+			}
+			if len(got) == 0 || !bytes.Equal(got, want) {
+				t.Errorf("ChannelBinding() = %x, want %x", got, want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/http/httptrace"
 	"net/url"
@@ -540,3 +541,115 @@ func BenchmarkClientDefault(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkConnReadWrite measures the throughput and allocations of Conn.Read and Conn.Write
+// over a loopback TCP connection.
+func BenchmarkConnReadWrite(b *testing.B) {
+	initTest(nil)
+	for _, size := range []int{1024, 16 * 1024} {
+		b.Run(fmt.Sprintf("Write %d", size), func(b *testing.B) {
+			client, server := newBenchmarkConns(b)
+			go io.Copy(io.Discard, server)
+			buf := make([]byte, size)
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.Write(buf); err != nil {
+					b.Fatalf("Write() failed: %v", err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("Read %d", size), func(b *testing.B) {
+			client, server := newBenchmarkConns(b)
+			go func() {
+				buf := make([]byte, size)
+				for {
+					if _, err := server.Write(buf); err != nil {
+						return
+					}
+				}
+			}()
+			buf := make([]byte, size)
+			b.SetBytes(int64(size))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := io.ReadFull(client, buf); err != nil {
+					b.Fatalf("Read() failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkConnSmallWrites measures sending 16 buffers of 64 bytes with a Conn.Write each and
+// with a single Conn.WriteBuffers.
+func BenchmarkConnSmallWrites(b *testing.B) {
+	initTest(nil)
+	const count, size = 16, 64
+	bufs := make(net.Buffers, count)
+	for i := range bufs {
+		bufs[i] = make([]byte, size)
+	}
+	b.Run("Write", func(b *testing.B) {
+		client, server := newBenchmarkConns(b)
+		go io.Copy(io.Discard, server)
+		b.SetBytes(count * size)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, buf := range bufs {
+				if _, err := client.Write(buf); err != nil {
+					b.Fatalf("Write() failed: %v", err)
+				}
+			}
+		}
+	})
+	b.Run("WriteBuffers", func(b *testing.B) {
+		client, server := newBenchmarkConns(b)
+		go io.Copy(io.Discard, server)
+		b.SetBytes(count * size)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			v := append(net.Buffers(nil), bufs...)
+			if _, err := client.WriteBuffers(&v); err != nil {
+				b.Fatalf("WriteBuffers() failed: %v", err)
+			}
+		}
+	})
+}
+
+// newBenchmarkConns returns a client and server Conn that completed their handshake over a
+// loopback TCP connection, and are closed when the benchmark ends.
+func newBenchmarkConns(b *testing.B) (*fipstls.Conn, *fipstls.Conn) {
+	c1, c2 := newConnPair(b, "tcp")
+	client := fipstls.Client(c1, &fipstls.Config{
+		CaFile:     testutils.CertPath,
+		ServerName: "localhost",
+	})
+	server := fipstls.Server(c2, &fipstls.Config{
+		CertFile: testutils.CertPath,
+		KeyFile:  testKeyPath,
+	})
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Handshake(time.Time{}) }()
+	if err := client.Handshake(time.Time{}); err != nil {
+		b.Fatalf("Handshake() failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		b.Fatalf("Server Handshake() failed: %v", err)
+	}
+	b.Cleanup(func() {
+		// Close waits for the close_notify of the peer, so both sides are closed concurrently.
+		done := make(chan struct{})
+		go func() {
+			server.Close()
+			close(done)
+		}()
+		client.Close()
+		<-done
+	})
+	return client, server
+}
@@ -1,6 +1,9 @@
 package fipstls
 
 import (
+	"crypto"
+	"time"
+
 	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
 )
 
@@ -42,6 +45,30 @@ const (
 	VerifyPostHandshake
 )
 
+// CertificateType is a certificate type negotiated with RFC 7250.
+type CertificateType uint8
+
+const (
+	// CertificateTypeX509 is an X.509 certificate.
+	CertificateTypeX509 CertificateType = libssl.TLSEXT_cert_type_x509
+	// CertificateTypeRawPublicKey is a bare public key encoded as SubjectPublicKeyInfo.
+	CertificateTypeRawPublicKey CertificateType = libssl.TLSEXT_cert_type_rpk
+)
+
+// CertCompressionAlgorithm is a certificate compression algorithm defined in RFC 8879.
+type CertCompressionAlgorithm int
+
+const (
+	// CertCompressionNone means the certificate was not compressed.
+	CertCompressionNone CertCompressionAlgorithm = libssl.TLSEXT_comp_cert_none
+	// CertCompressionZlib is zlib compression.
+	CertCompressionZlib CertCompressionAlgorithm = libssl.TLSEXT_comp_cert_zlib
+	// CertCompressionBrotli is brotli compression.
+	CertCompressionBrotli CertCompressionAlgorithm = libssl.TLSEXT_comp_cert_brotli
+	// CertCompressionZstd is zstd compression.
+	CertCompressionZstd CertCompressionAlgorithm = libssl.TLSEXT_comp_cert_zstd
+)
+
 // Config is used to configure a TLS client.
 type Config struct {
 	// LibsslVersion is the libssl version to dynamically load.
@@ -84,14 +111,229 @@ type Config struct {
 	// SessionCacheDisabled disables session caching.
 	SessionCacheDisabled bool
 
+	// ClientSessionCache is a cache of sessions used to resume connections. Sessions are keyed
+	// by ServerName, or by the dialed host and port if ServerName is empty. Resumption is
+	// required for sending TLS 1.3 early data with [Conn.WriteEarlyData]. It is ignored if
+	// SessionCacheDisabled is set.
+	ClientSessionCache ClientSessionCache
+
+	// MaxEarlyData is the amount of TLS 1.3 early data (0-RTT) a server accepts from clients
+	// resuming a session, in bytes. Zero rejects early data. Early data is not protected
+	// against replay by the protocol, libssl only accepts it once per session on a server that
+	// shares its [Context]. It is returned first by [Conn.Read]. It is ignored by clients.
+	MaxEarlyData uint32
+
 	// CompressionDisabled disables compression.
 	CompressionDisabled bool
 
+	// CertCompression enables TLS 1.3 certificate compression with the algorithms in order of
+	// preference. Only the algorithms libssl was built with are used. It is a no-op before
+	// OpenSSL 3.2, which is logged at [LogLevelDebug].
+	CertCompression []CertCompressionAlgorithm
+
+	// PostHandshakeAuth lets a TLS 1.3 client answer certificate requests sent by the server
+	// after the handshake with the certificate in CertFile and KeyFile, or the one returned by
+	// GetClientCertificate.
+	PostHandshakeAuth bool
+
+	// GetClientCertificate returns the certificate a client authenticates with when the server
+	// requests one. It is called on every certificate request, so certificates can be rotated
+	// without creating a new [Dialer]. Returning a nil Certificate sends no certificate, and
+	// returning an error aborts the handshake. If set, CertFile and KeyFile are ignored.
+	GetClientCertificate func(*CertificateRequestInfo) (*Certificate, error)
+
+	// MaxFragmentLength asks the server to limit the plaintext length of the records it sends
+	// with the max_fragment_length extension (RFC 6066). It must be 0 (disabled), 512, 1024,
+	// 2048 or 4096, and requires OpenSSL 1.1.1 or later. The negotiated length is reported in
+	// [ConnectionState.MaxFragmentLength] and also limits the records sent.
+	MaxFragmentLength int
+
+	// MaxSendFragment is the maximum plaintext length of the records sent, between 512 and
+	// 16384 bytes. It defaults to 16384.
+	MaxSendFragment int
+
+	// SplitSendFragment is the maximum plaintext length of the records sent by each pipeline
+	// when libssl pipelines writes, between 512 bytes and MaxSendFragment. Since OpenSSL 3.0 it
+	// also limits the records of writes that are not pipelined. It defaults to MaxSendFragment.
+	SplitSendFragment int
+
+	// RecordPadding pads TLS 1.3 records to a multiple of RecordPadding bytes to hide the
+	// length of the application data, up to 16384 bytes. It requires OpenSSL 1.1.1 or later.
+	// Zero or one disables padding.
+	RecordPadding int
+
+	// EnableKTLS offloads record encryption and decryption to the kernel (kTLS) on Linux when
+	// the tls kernel module and the negotiated cipher suite support it. Connections silently
+	// fall back to encrypting in user space otherwise. The offload in effect is reported in
+	// [ConnectionState]. It requires OpenSSL 3.0 or later built with kTLS support, and only
+	// applies to connections over a socket [BIO], such as the ones created by a [Dialer].
+	EnableKTLS bool
+
+	// ReleaseBuffers frees the read and write buffers of idle connections, and allocates them
+	// again on the next read or write (SSL_MODE_RELEASE_BUFFERS). It saves about 17KB of C
+	// memory per idle connection with OpenSSL 3.0, at the cost of allocations on reads and
+	// writes, which suits servers and clients holding many mostly idle connections.
+	ReleaseBuffers bool
+
+	// ReadAhead lets libssl read as much data as the transport has available into its read
+	// buffer, instead of reading one record at a time. It saves system calls when many small
+	// records are received.
+	ReadAhead bool
+
+	// ReadBufferLen is the length of the read buffer, used with ReadAhead, in bytes. Zero uses
+	// the libssl default, which fits the largest record. It requires OpenSSL 1.1.0 or later.
+	ReadBufferLen int
+
+	// CloseTimeout bounds the time [Conn.Close] and [Conn.CloseWrite] spend sending the
+	// close_notify alert, and with WaitForCloseNotify, the time Close waits for the peer's.
+	// Zero uses 5 seconds.
+	CloseTimeout time.Duration
+
+	// WaitForCloseNotify makes [Conn.Close] wait for the close_notify alert of the peer after
+	// sending its own (bidirectional shutdown), discarding the data received meanwhile. Close
+	// returns [ErrTruncated] if the peer closes the transport without one, or a timeout error
+	// if none arrives within CloseTimeout.
+	WaitForCloseNotify bool
+
+	// Renegotiation controls what types of TLS 1.2 renegotiation are supported by a client.
+	// The default, RenegotiateNever, is correct for the vast majority of applications. Servers
+	// never accept renegotiation.
+	Renegotiation RenegotiationSupport
+
 	// RenegotiationDisabled disables all renegotiation.
+	//
+	// Deprecated: renegotiation is disabled unless enabled with Renegotiation. If set,
+	// Renegotiation is ignored.
 	RenegotiationDisabled bool
 
 	// NextProtos are the ALPN protocol to prefer when establishing a connection.
 	NextProtos []string
+
+	// PSK is an external pre-shared key offered by a client. With TLS 1.3 the key is offered
+	// in the handshake, and with TLS 1.2 the PSK cipher suites are enabled. In FIPS mode only
+	// FIPS-approved PSK cipher suites are negotiated.
+	PSK *PSK
+
+	// GetPSK returns the pre-shared key for the identity presented by a client when Method is
+	// ServerMethod. Returning a nil PSK rejects the identity, and returning an error aborts the
+	// handshake.
+	GetPSK func(identity string) (*PSK, error)
+
+	// ClientCertificateTypes are the certificate types, in order of preference, that a client
+	// may authenticate with or that a server accepts from clients. It defaults to X.509 only.
+	// A raw public key is derived from the private key in KeyFile. Raw public keys require
+	// OpenSSL 3.2 or later.
+	ClientCertificateTypes []CertificateType
+
+	// ServerCertificateTypes are the certificate types, in order of preference, that a client
+	// accepts from servers or that a server may authenticate with. It defaults to X.509 only.
+	ServerCertificateTypes []CertificateType
+
+	// PeerPublicKeys are the DER-encoded SubjectPublicKeyInfo keys the peer may authenticate
+	// with, whether sent as a raw public key or in a certificate. They are required to verify
+	// raw public keys unless InsecureSkipVerify is set. They require OpenSSL 3.2 or later.
+	//
+	// The keys are matched like DANE-EE SPKI records: a peer presenting one of them is
+	// authenticated by the key alone, and neither the certificate chain nor the hostname is
+	// validated, even when the key is sent in a certificate.
+	PeerPublicKeys [][]byte
+}
+
+// defaultCloseTimeout is the CloseTimeout used when none is set.
+const defaultCloseTimeout = 5 * time.Second
+
+// closeTimeout returns the CloseTimeout, or the default if it is not set.
+func (c *Config) closeTimeout() time.Duration {
+	if c.CloseTimeout > 0 {
+		return c.CloseTimeout
+	}
+	return defaultCloseTimeout
+}
+
+// usesRPK returns true if any raw public key option is set.
+func (c *Config) usesRPK() bool {
+	return len(c.ClientCertificateTypes) > 0 || len(c.ServerCertificateTypes) > 0 ||
+		len(c.PeerPublicKeys) > 0
+}
+
+func certTypes(types []CertificateType) []byte {
+	if len(types) == 0 {
+		return nil
+	}
+	b := make([]byte, len(types))
+	for i, t := range types {
+		b[i] = byte(t)
+	}
+	return b
+}
+
+// PSK is an external pre-shared key used to authenticate a connection without certificates.
+type PSK struct {
+	// Identity identifies the key to the server. With TLS 1.2 it must not contain NUL bytes.
+	Identity string
+
+	// Key is the secret shared with the peer. It must be between 1 and 256 bytes long.
+	Key []byte
+
+	// Hash is the hash algorithm associated with the key in TLS 1.3, either crypto.SHA256 or
+	// crypto.SHA384. It defaults to crypto.SHA256 and restricts TLS 1.3 to the cipher suite
+	// using the same hash.
+	Hash crypto.Hash
+}
+
+const (
+	minFragmentLen = 512
+	maxFragmentLen = 16384
+)
+
+// validateRecordLayer checks the record layer limits.
+func (c *Config) validateRecordLayer() error {
+	switch c.MaxFragmentLength {
+	case 0, 512, 1024, 2048, 4096:
+	default:
+		return ErrInvalidRecordSize
+	}
+	maxSend := c.MaxSendFragment
+	if maxSend == 0 {
+		maxSend = maxFragmentLen
+	}
+	if maxSend < minFragmentLen || maxSend > maxFragmentLen {
+		return ErrInvalidRecordSize
+	}
+	if c.SplitSendFragment != 0 &&
+		(c.SplitSendFragment < minFragmentLen || c.SplitSendFragment > maxSend) {
+		return ErrInvalidRecordSize
+	}
+	if c.RecordPadding < 0 || c.RecordPadding > maxFragmentLen {
+		return ErrInvalidRecordSize
+	}
+	if c.ReadBufferLen < 0 {
+		return ErrInvalidRecordSize
+	}
+	return nil
+}
+
+// maxPSKLen is the longest pre-shared key accepted by the libssl callbacks.
+const maxPSKLen = 256
+
+func (p *PSK) validate() error {
+	if p.Identity == "" || len(p.Key) == 0 || len(p.Key) > maxPSKLen {
+		return ErrInvalidPSK
+	}
+	switch p.Hash {
+	case 0, crypto.SHA256, crypto.SHA384:
+		return nil
+	default:
+		return ErrInvalidPSK
+	}
+}
+
+func (p *PSK) libssl() *libssl.PSK {
+	return &libssl.PSK{
+		Identity: []byte(p.Identity),
+		Key:      p.Key,
+		SHA384:   p.Hash == crypto.SHA384,
+	}
 }
 
 // newDefaultConfig returns a [Config] with sane default options.
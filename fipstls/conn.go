@@ -33,18 +33,144 @@ type Conn struct {
 	in  sync.Mutex
 	out sync.Mutex
 
+	// sslMu serializes the SSL operations, which OpenSSL does not allow to run concurrently,
+	// and is held when ssl is freed, so that Close does not free ssl under a Read or Write. It
+	// is never held while waiting for the peer, see duplex.go.
+	sslMu    sync.Mutex
+	sslFreed bool
+
+	// wbio is the output BIO libssl writes records to, or nil if libssl writes to the socket.
+	// flushMu serializes sending the records, and flushPending holds the part of flushBuf
+	// that is still to be sent. unsent is set while a failed flush left records in
+	// flushPending.
+	wbio         *libssl.BIO
+	flushMu      sync.Mutex
+	flushBuf     *[]byte
+	flushPending []byte
+	unsent       atomic.Bool
+
+	// handshakeMu serializes handshakes, which may start implicitly from Read and Write.
+	handshakeMu sync.Mutex
+	// handshakeErr is the error of the failed handshake, or of setting up the connection.
+	handshakeErr error
+
 	// closeNotifySent is true if the Conn attempted to send an
 	// alertCloseNotify record.
 	closeNotifySent bool
 	closeErr        error
 
-	// deadlines
+	// deadlines. deadlineMu serializes setting them on the BIO.
+	deadlineMu        sync.Mutex
 	readDeadline      atomicTime
 	writeDeadline     atomicTime
 	handshakeDeadline atomicTime
 
 	// l is a logger
 	l Logger
+
+	// callbacks are the libssl callbacks attached to ssl.
+	callbacks *libssl.Callbacks
+
+	// sessionKey is the key used for the [ClientSessionCache], or empty if session caching is
+	// disabled.
+	sessionKey string
+	// sessionOffered is true if a cached session was offered to the peer.
+	sessionOffered bool
+	// maxEarlyData is the early data limit of the offered session.
+	maxEarlyData uint32
+	// earlyData holds the data sent with WriteEarlyData until the handshake completes.
+	earlyData []byte
+	// earlyIn holds the early data received by a server until it is read, and earlyInDone is
+	// set once all of it was received.
+	earlyIn     []byte
+	earlyInDone bool
+
+	// keyUpdatePolicy triggers automatic key updates.
+	keyUpdatePolicy KeyUpdatePolicy
+	// bytesSinceKeyUpdate counts the bytes written since the last key update.
+	bytesSinceKeyUpdate uint64
+	keyUpdateTimer      atomic.Pointer[time.Timer]
+	// keyUpdateQueued is set while a KeyUpdate message queued by libssl was not sent yet.
+	keyUpdateQueued bool
+	// keyUpdateMu is held by the interval key updates, which stop once keyUpdateStopped is set.
+	// keyUpdateDue hands an interval key update to the write holding out.
+	keyUpdateMu      sync.Mutex
+	keyUpdateStopped bool
+	keyUpdateDue     atomic.Bool
+
+	// writeCoalescing buffers small writes in wbuf until flushTimer fires. wbuf and packBuf,
+	// the buffer WriteBuffers packs into, are protected by out.
+	writeCoalescing WriteCoalescing
+	wbuf            []byte
+	packBuf         []byte
+	flushTimer      atomic.Pointer[time.Timer]
+
+	// certWait is closed by the next read while RequestClientCertificate waits for the client
+	// certificate, which a Read in progress processes instead.
+	certWait atomic.Pointer[chan struct{}]
+
+	// renegotiations counts the renegotiations requested by the server.
+	renegotiations int
+
+	handshakeComplete atomic.Bool
+	// stateMu protects state
+	stateMu sync.Mutex
+	state   ConnectionState
+}
+
+// EarlyDataStatus reports the outcome of TLS 1.3 early data sent with [Conn.WriteEarlyData], or
+// received by a server accepting it with [Config.MaxEarlyData].
+type EarlyDataStatus int
+
+const (
+	// EarlyDataNotSent means no early data was sent.
+	EarlyDataNotSent EarlyDataStatus = iota
+	// EarlyDataRejected means the server rejected the early data and the client resent it
+	// after the handshake.
+	EarlyDataRejected
+	// EarlyDataAccepted means the server accepted the early data.
+	EarlyDataAccepted
+)
+
+// ConnectionState records basic TLS details about the connection.
+type ConnectionState struct {
+	// Version is the TLS version used by the connection (e.g. Version13).
+	Version uint16
+
+	// HandshakeComplete is true if the handshake has concluded.
+	HandshakeComplete bool
+
+	// DidResume is true if this connection was successfully resumed from a previous session
+	// in [Config.ClientSessionCache].
+	DidResume bool
+
+	// NegotiatedProtocol is the application protocol negotiated with ALPN.
+	NegotiatedProtocol string
+
+	// EarlyData reports whether the server accepted the data sent with [Conn.WriteEarlyData]
+	// or, on a server, the early data of the client.
+	EarlyData EarlyDataStatus
+
+	// KeyUpdates is the number of TLS 1.3 key updates sent with [Conn.KeyUpdate] or the
+	// [KeyUpdatePolicy].
+	KeyUpdates uint64
+
+	// CertCompression is the algorithm the peer's certificate was compressed with, or
+	// CertCompressionNone if it was not compressed.
+	CertCompression CertCompressionAlgorithm
+
+	// MaxFragmentLength is the maximum record plaintext length negotiated with the
+	// max_fragment_length extension, or zero if none was negotiated.
+	MaxFragmentLength int
+
+	// PeerRawPublicKey is the DER-encoded SubjectPublicKeyInfo the peer authenticated with if
+	// it sent a raw public key instead of a certificate.
+	PeerRawPublicKey []byte
+
+	// KTLSSend and KTLSRecv are true if the kernel encrypts the records sent and decrypts the
+	// records received, see [Config.EnableKTLS].
+	KTLSSend bool
+	KTLSRecv bool
 }
 
 const (
@@ -82,14 +208,21 @@ func (c *connLogger) Wrap(prefix string) Logger {
 	return c.Logger.Wrap(prefix)
 }
 
-// NewConn creates a TLS [Conn] from a [Context] and [BIO].
+// NewConn creates a TLS [Conn] from a [Context] and [BIO]. The [Conn] holds a reference to the
+// C.SSL_CTX of the [Context] until it is closed. If NewConn fails, the [BIO] is released by
+// [BIO.Close].
 func NewConn(ctx *Context, bio *BIO, tls *Config, l Logger) (*Conn, error) {
 	if !libsslInit {
 		return nil, ErrNoLibSslInit
 	}
+	ctxRef, err := ctx.retain()
+	if err != nil {
+		return nil, err
+	}
 	ssl, err := libssl.NewSSL(ctx.Ctx())
 	if err != nil {
 		libssl.SSLFree(ssl)
+		ctxRef.Close()
 		return nil, err
 	}
 	c := &Conn{
@@ -99,32 +232,193 @@ func NewConn(ctx *Context, bio *BIO, tls *Config, l Logger) (*Conn, error) {
 		closer: noopCloser{},
 		l:      noopLogger{},
 	}
+	if _, ok := l.(noopLogger); !ok && l != nil {
+		c.l = newConnLogger(l, bio.String())
+	}
+	if err := c.setCallbacks(); err != nil {
+		libssl.SSLFree(c.ssl)
+		ctxRef.Close()
+		return nil, err
+	}
+	if len(c.config.CertCompression) > 0 && !libssl.SupportsCertCompression() {
+		c.l.Logf(LogLevelDebug, "Certificate compression ignored, %s does not support it",
+			libssl.VersionText())
+	}
+	if len(c.config.PeerPublicKeys) > 0 {
+		if err := libssl.SSLAddExpectedRPKs(c.ssl, c.config.PeerPublicKeys); err != nil {
+			c.l.Logf(LogLevelErr, "Failed to add expected peer public keys: %v", err)
+			libssl.SSLFree(c.ssl)
+			ctxRef.Close()
+			return nil, err
+		}
+	}
+	if c.config.PostHandshakeAuth && c.config.Method != ServerMethod {
+		if err := libssl.SSLSetPostHandshakeAuth(c.ssl); err != nil {
+			c.l.Logf(LogLevelErr, "Failed to enable post-handshake authentication: %v", err)
+			libssl.SSLFree(c.ssl)
+			ctxRef.Close()
+			return nil, err
+		}
+	}
 	if err := c.configureBIO(); err != nil {
 		libssl.SSLFree(c.ssl)
+		ctxRef.Close()
 		return nil, err
 	}
+	if c.config.Method != ServerMethod {
+		c.resumeSession()
+	}
 	c.closer = newOnceCloser(func() error {
 		c.l.Logf(LogLevelDebug, "Closer.close called")
+		c.stopKeyUpdatePolicy()
+		c.stopFlushTimer()
+		c.saveSession()
+		// Wake up blocked operations before waiting for them to release ssl.
+		c.bio.closeIO()
+		c.sslMu.Lock()
+		c.sslFreed = true
 		libssl.SSLFree(c.ssl)
-		return ctx.closer.Close()
+		c.sslMu.Unlock()
+		return ctxRef.Close()
 	})
-	if _, ok := l.(noopLogger); !ok {
-		c.l = newConnLogger(l, bio.String())
-	}
 	return c, nil
 }
 
+// setCallbacks attaches the callbacks invoked by libssl during the handshake.
+func (c *Conn) setCallbacks() error {
+	cb := &libssl.Callbacks{}
+	setPSKCallbacks(cb, c.config, c.l)
+	if c.config.GetClientCertificate != nil {
+		cb.ClientCert = c.getClientCertificate
+	}
+	if c.config.renegotiation() != RenegotiateNever {
+		cb.HandshakeStart = c.handshakeStart
+	}
+	if err := libssl.SSLSetCallbacks(c.ssl, cb); err != nil {
+		return err
+	}
+	c.callbacks = cb
+	return nil
+}
+
+// setPSKCallbacks sets the callbacks offering [Config.PSK] and looking up the pre-shared key of
+// a client identity with [Config.GetPSK].
+func setPSKCallbacks(cb *libssl.Callbacks, config *Config, l Logger) {
+	if config.PSK != nil {
+		psk := config.PSK.libssl()
+		identity := config.PSK.Identity
+		cb.PSKClient = func() *libssl.PSK {
+			l.Logf(LogLevelDebug, "Offering PSK identity %q", identity)
+			return psk
+		}
+	}
+	getPSK := config.GetPSK
+	if getPSK == nil {
+		return
+	}
+	cb.PSKServer = func(identity []byte) (*libssl.PSK, error) {
+		psk, err := getPSK(string(identity))
+		if err != nil {
+			l.Logf(LogLevelErr, "PSK lookup for identity %q failed: %v", identity, err)
+			return nil, err
+		}
+		if psk == nil {
+			l.Logf(LogLevelInfo, "Unknown PSK identity %q", identity)
+			return nil, nil
+		}
+		if err := psk.validate(); err != nil {
+			l.Logf(LogLevelErr, "PSK for identity %q is invalid: %v", identity, err)
+			return nil, err
+		}
+		return psk.libssl(), nil
+	}
+}
+
+// resumeSession offers the session cached for the peer, if there is one.
+func (c *Conn) resumeSession() {
+	cache := c.config.ClientSessionCache
+	if cache == nil || c.config.SessionCacheDisabled {
+		return
+	}
+	c.sessionKey = c.config.ServerName
+	if c.sessionKey == "" {
+		// Unix domain sockets have a path rather than a host and port.
+		if addr, ok := c.bio.RemoteAddr().(*net.UnixAddr); ok {
+			c.sessionKey = addr.Name
+		} else {
+			c.sessionKey = net.JoinHostPort(c.bio.Hostname(), c.bio.port)
+		}
+	}
+	cs, ok := cache.Get(c.sessionKey)
+	if !ok || cs == nil {
+		return
+	}
+	if err := cs.setSession(c.ssl); err != nil {
+		c.l.Logf(LogLevelErr, "Failed to set cached session: %v", err)
+		cache.Put(c.sessionKey, nil)
+		return
+	}
+	c.sessionOffered = true
+	c.maxEarlyData = cs.maxEarlyData
+}
+
+// saveSession stores the session in the [ClientSessionCache] so it can be resumed by later
+// connections to the same peer.
+func (c *Conn) saveSession() {
+	if c.sessionKey == "" || !c.handshakeComplete.Load() {
+		return
+	}
+	var cs *ClientSessionState
+	err := c.withSSL(func() (err error) {
+		cs, err = newClientSessionState(c.ssl)
+		return err
+	})
+	if err != nil {
+		c.l.Logf(LogLevelDebug, "Failed to save session: %v", err)
+		return
+	}
+	if cs != nil {
+		c.l.Logf(LogLevelDebug, "Saving session with max early data %d", cs.maxEarlyData)
+		c.config.ClientSessionCache.Put(c.sessionKey, cs)
+	}
+}
+
 func (c *Conn) configureBIO() error {
+	server := c.config.Method == ServerMethod
 	// If no ServerName is set, infer the ServerName
 	// from the hostname we're connecting to.
 	hostname := c.config.ServerName
-	if hostname == "" {
+	if hostname == "" && !server {
 		hostname = c.bio.Hostname()
+		// Without a hostname, libssl neither sends SNI nor verifies the certificate name.
+		if hostname == "" && !c.config.InsecureSkipVerify {
+			return ErrMissingServerName
+		}
+	}
+	// kTLS requires libssl to write to the socket.
+	if c.config.EnableKTLS && c.bio.conn == nil {
+		c.bio.attached = true
+		if server {
+			return libssl.SSLConfigureServerBIO(c.ssl, c.bio.BIO())
+		}
+		if err := libssl.SSLConfigureBIO(c.ssl, c.bio.BIO(), hostname); err != nil {
+			c.l.Logf(LogLevelErr, "Failed to configure BIO: %v", err)
+			return err
+		}
+		return nil
 	}
-	if err := libssl.SSLConfigureBIO(c.ssl, c.bio.BIO(), hostname); err != nil {
+	wbio, err := libssl.NewMemBIO()
+	if err != nil {
+		c.l.Logf(LogLevelErr, "Failed to create output BIO: %v", err)
+		return err
+	}
+	// ssl owns both BIOs from here on, even if configuring it fails.
+	c.bio.attached = true
+	if err := libssl.SSLConfigureMemBIOs(c.ssl, c.bio.BIO(), wbio, hostname, server); err != nil {
 		c.l.Logf(LogLevelErr, "Failed to configure BIO: %v", err)
 		return err
 	}
+	c.wbio = wbio
 	return nil
 }
 
@@ -133,16 +427,242 @@ func (c *Conn) connect() error {
 	return libssl.SSLConnect(c.ssl)
 }
 
-// Handshake initiates a TLS handshake with the peer.
+// earlyReadSize is the length of the early data read by a server at a time.
+const earlyReadSize = 4096
+
+// accept runs the handshake of a server. With [Config.MaxEarlyData] set, it first receives the
+// early data of the client into earlyIn.
+func (c *Conn) accept() error {
+	for c.config.MaxEarlyData > 0 && !c.earlyInDone {
+		if c.closed.Load() {
+			return c.closeErr
+		}
+		buf := make([]byte, earlyReadSize)
+		libssl.SSLClearError()
+		n, finished, err := libssl.SSLReadEarlyData(c.ssl, buf)
+		if err != nil {
+			return err
+		}
+		c.earlyIn = append(c.earlyIn, buf[:n]...)
+		c.earlyInDone = finished
+	}
+	return c.doHandshake()
+}
+
+// Handshake runs the TLS handshake with the peer if it has not run yet. [Conn.Read] and
+// [Conn.Write] run it implicitly, bounded by the read or write deadline. The error of a failed
+// handshake is returned by every later call, except for timeouts, after which the handshake
+// resumes on the next call.
 func (c *Conn) Handshake(deadline time.Time) error {
+	return c.handshakeBy(func() time.Time { return deadline })
+}
+
+// handshakeFor runs the handshake implicitly for the operation kind, bounded by its deadline as
+// set when the handshake starts.
+func (c *Conn) handshakeFor(kind string) error {
+	return c.handshakeBy(func() time.Time { return c.deadline(kind) })
+}
+
+// handshakeBy runs the handshake if it has not run yet, bounded by the deadline returned by
+// deadline once concurrent handshakes are done.
+func (c *Conn) handshakeBy(deadline func() time.Time) error {
+	if c.handshakeComplete.Load() {
+		return nil
+	}
+	c.handshakeMu.Lock()
+	defer c.handshakeMu.Unlock()
+	if c.handshakeErr != nil || c.handshakeComplete.Load() {
+		return c.handshakeErr
+	}
+	err := c.handshake(deadline())
+	if !isTimeout(err) {
+		c.handshakeErr = err
+	}
+	return err
+}
+
+func (c *Conn) handshake(deadline time.Time) error {
 	c.l.Logf(LogLevelDebug, "Handshake begin")
 	defer c.l.Logf(LogLevelDebug, "Handshake end")
 	c.handshakeDeadline.Store(deadline)
-	_, err := c.doIO(nil, func(b []byte) (int, error) { return 0, c.connect() }, opHandshake)
+	// The handshake deadline bounds waiting on the socket in both directions, until the read or
+	// write deadline is set again.
+	c.deadlineMu.Lock()
+	c.bio.setReadDeadline(deadline)
+	c.bio.setWriteDeadline(deadline)
+	c.deadlineMu.Unlock()
+	handshake := c.connect
+	if c.config.Method == ServerMethod {
+		handshake = c.accept
+	}
+	_, err := c.doIO(nil, func(b []byte) (int, error) { return 0, handshake() }, opHandshake)
+	c.restoreDeadlines()
 	if err != nil {
-		c.l.Logf(LogLevelDebug, "Post-Handshake negotiated protocols: %v", libssl.SSLStatusALPN(c.ssl))
+		// Throw away the session if resuming it failed, see RFC 5077, Section 3.2.
+		if c.sessionOffered && !isTimeout(err) {
+			c.config.ClientSessionCache.Put(c.sessionKey, nil)
+		}
+		return err
 	}
-	return err
+	var state ConnectionState
+	if err := c.withSSL(func() error {
+		c.l.Logf(LogLevelDebug, "Post-Handshake negotiated protocols: %v",
+			libssl.SSLStatusALPN(c.ssl))
+		state = newConnectionState(c.ssl, c.l)
+		switch status := libssl.SSLGetEarlyDataStatus(c.ssl); {
+		case status == libssl.SSL_EARLY_DATA_ACCEPTED:
+			state.EarlyData = EarlyDataAccepted
+		case status == libssl.SSL_EARLY_DATA_REJECTED || len(c.earlyData) > 0:
+			state.EarlyData = EarlyDataRejected
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if c.config.EnableKTLS {
+		c.l.Logf(LogLevelInfo, "kTLS send: %v, receive: %v", state.KTLSSend, state.KTLSRecv)
+	}
+	c.stateMu.Lock()
+	c.state = state
+	c.stateMu.Unlock()
+	if err := c.replayEarlyData(state.EarlyData); err != nil {
+		// The connection is unusable without the rejected early data, even after a timeout.
+		// The caller holds handshakeMu.
+		c.handshakeErr = err
+		return err
+	}
+	// Read and Write skip the handshake from here on, so the state has to be published first.
+	c.handshakeComplete.Store(true)
+	c.startKeyUpdatePolicy()
+	return nil
+}
+
+// newConnectionState returns the state of ssl once its handshake has completed.
+func newConnectionState(ssl *libssl.SSL, l Logger) ConnectionState {
+	peerRPK, err := libssl.SSLGetPeerRPK(ssl)
+	if err != nil {
+		l.Logf(LogLevelErr, "Failed to get peer raw public key: %v", err)
+	}
+	return ConnectionState{
+		Version:            uint16(libssl.SSLVersion(ssl)),
+		HandshakeComplete:  true,
+		DidResume:          libssl.SSLSessionReused(ssl),
+		NegotiatedProtocol: libssl.SSLGetALPNSelected(ssl),
+		CertCompression:    CertCompressionAlgorithm(libssl.SSLGetPeerCertComp(ssl)),
+		MaxFragmentLength:  libssl.SSLGetMaxFragmentLength(ssl),
+		PeerRawPublicKey:   peerRPK,
+		KTLSSend:           libssl.SSLKTLSSend(ssl),
+		KTLSRecv:           libssl.SSLKTLSRecv(ssl),
+	}
+}
+
+// ConnectionState returns basic TLS details about the connection.
+func (c *Conn) ConnectionState() ConnectionState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	return c.state
+}
+
+// ExportKeyingMaterial returns length bytes of exported key material in a new slice as defined
+// in RFC 5705 and RFC 8446, Section 7.5. If context is nil, it is not used as part of the seed
+// in TLS 1.2. It returns [ErrEKMUnavailable] if the connection negotiated TLS 1.2 without the
+// Extended Master Secret extension (RFC 7627), as the keying material is then not unique to
+// the connection.
+func (c *Conn) ExportKeyingMaterial(label string, context []byte, length int) ([]byte, error) {
+	if c.closed.Load() {
+		return nil, net.ErrClosed
+	}
+	if !c.handshakeComplete.Load() {
+		return nil, ErrHandshakeIncomplete
+	}
+	version := c.ConnectionState().Version
+	var ekm []byte
+	err := c.withSSL(func() (err error) {
+		ekm, err = exportKeyingMaterial(c.ssl, version, label, context, length)
+		return err
+	})
+	return ekm, err
+}
+
+// exportKeyingMaterial exports keying material from ssl, which negotiated version.
+func exportKeyingMaterial(ssl *libssl.SSL, version uint16, label string, context []byte,
+	length int) ([]byte, error) {
+	switch label {
+	case "client finished", "server finished", "master secret", "key expansion":
+		return nil, fmt.Errorf("fipstls: reserved ExportKeyingMaterial label: %s", label)
+	}
+	if version < Version13 && !libssl.SSLExtmsSupport(ssl) {
+		return nil, ErrEKMUnavailable
+	}
+	return libssl.SSLExportKeyingMaterial(ssl, label, context, length)
+}
+
+func (c *Conn) writeEarlyData(b []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	libssl.SSLClearError()
+	return libssl.SSLWriteEarlyData(c.ssl, b)
+}
+
+// WriteEarlyData sends b to the server as TLS 1.3 early data (0-RTT), before the handshake
+// completes. It must be called before [Conn.Handshake] and requires that a session permitting
+// early data was cached for the peer in [Config.ClientSessionCache] by a previous connection,
+// otherwise it returns [ErrEarlyDataUnavailable]. WriteEarlyData may be called multiple times
+// as long as the total does not exceed the limit set by the server for the session.
+//
+// If the server rejects the early data, [Conn.Handshake] transparently resends it as regular
+// application data once the handshake completes. [ConnectionState.EarlyData] reports which of
+// the two happened. If resending it fails, the handshake fails with that error, which is then
+// returned by every later call, even if it is a timeout.
+//
+// Early data is NOT protected against replay. An attacker who records the early data can send
+// it to the server again, and a server (or a cluster of servers sharing session keys) may
+// process it more than once. Only use early data for requests that are idempotent and safe to
+// replay, such as retry-safe telemetry pushes.
+func (c *Conn) WriteEarlyData(b []byte) (int, error) {
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	if c.handshakeComplete.Load() {
+		return 0, ErrHandshakeComplete
+	}
+	if c.maxEarlyData == 0 || uint64(len(c.earlyData)+len(b)) > uint64(c.maxEarlyData) {
+		return 0, ErrEarlyDataUnavailable
+	}
+	n, err := c.doIO(b, c.writeEarlyData, opWrite)
+	c.earlyData = append(c.earlyData, b[:n]...)
+	return n, err
+}
+
+// replayEarlyData resends the early data as regular application data after the handshake if
+// the server rejected it.
+func (c *Conn) replayEarlyData(status EarlyDataStatus) error {
+	if len(c.earlyData) == 0 {
+		return nil
+	}
+	data := c.earlyData
+	c.earlyData = nil
+	if status == EarlyDataAccepted {
+		c.l.Logf(LogLevelInfo, "Early data accepted by server (%d bytes)", len(data))
+		return nil
+	}
+	c.l.Logf(LogLevelInfo, "Early data rejected by server, replaying %d bytes", len(data))
+	// The handshake is not complete yet, so the data is written without Write.
+	c.out.Lock()
+	defer c.out.Unlock()
+	for written := 0; written < len(data); {
+		n, err := c.doIO(data[written:], c.write, opWrite)
+		written += n
+		if err != nil {
+			c.l.Logf(LogLevelErr, "Replaying early data failed after %d bytes: %v", written, err)
+			return err
+		}
+	}
+	c.bytesSinceKeyUpdate += uint64(len(data))
+	return nil
 }
 
 // LocalAddr returns the local address if known.
@@ -160,20 +680,22 @@ func (c *Conn) read(b []byte) (int, error) {
 	if c.closed.Load() {
 		return 0, net.ErrClosed
 	}
+	defer c.wakeCertWaiter()
 	libssl.SSLClearError()
-	r, n, err := libssl.SSLReadEx(c.ssl, int64(len(b)))
-	if err != nil {
-		return n, err
-	}
-	copy(b, r[:n])
-	return n, nil
+	return libssl.SSLReadEx(c.ssl, b)
 }
 
 // Read will read bytes into the buffer from the [Conn] connection, wrapped in an optional deadline.
 func (c *Conn) Read(b []byte) (int, error) {
 	c.l.Logf(LogLevelDebug, "Read begin")
 	defer c.l.Logf(LogLevelDebug, "Read end")
+	if err := c.handshakeFor(opRead); err != nil {
+		return 0, err
+	}
+	c.flushBeforeRead()
 	c.in.Lock()
+	// RequestClientCertificate reads the certificate itself once the input is released.
+	defer c.wakeCertWaiter()
 	defer c.in.Unlock()
 	if c.closed.Load() {
 		return 0, net.ErrClosed
@@ -181,14 +703,25 @@ func (c *Conn) Read(b []byte) (int, error) {
 	if len(b) == 0 {
 		return 0, nil
 	}
+	if len(c.earlyIn) > 0 {
+		n := copy(b, c.earlyIn)
+		c.earlyIn = c.earlyIn[n:]
+		return n, nil
+	}
 	return c.doIO(b, c.read, opRead)
 }
 
+// maxWriteSize bounds the data encrypted into the output BIO by a single write.
+const maxWriteSize = outputBufferSize
+
 // Write will write bytes from the buffer to the [Conn] connection.
 func (c *Conn) write(b []byte) (int, error) {
 	if c.closed.Load() {
 		return 0, net.ErrClosed
 	}
+	if c.wbio != nil && len(b) > maxWriteSize {
+		b = b[:maxWriteSize]
+	}
 	libssl.SSLClearError()
 	return libssl.SSLWriteEx(c.ssl, b)
 }
@@ -199,18 +732,13 @@ var ErrShutdown = errors.New("fipstls: protocol is shutdown")
 func (c *Conn) Write(b []byte) (int, error) {
 	c.l.Logf(LogLevelDebug, "Write begin")
 	defer c.l.Logf(LogLevelDebug, "Write end")
-	// interlock with Close below
-	for {
-		c.l.Logf(LogLevelDebug, "Write waiting...")
-		x := c.activeCall.Load()
-		if x&1 != 0 {
-			return 0, net.ErrClosed
-		}
-		if c.activeCall.CompareAndSwap(x, x+2) {
-			break
-		}
+	if err := c.beginCall(); err != nil {
+		return 0, err
+	}
+	defer c.endCall()
+	if err := c.handshakeFor(opWrite); err != nil {
+		return 0, err
 	}
-	defer c.activeCall.Add(-2)
 	c.l.Logf(LogLevelDebug, "Write grabbed lock")
 	c.out.Lock()
 	defer c.out.Unlock()
@@ -218,7 +746,28 @@ func (c *Conn) Write(b []byte) (int, error) {
 		// we're done writing
 		return 0, ErrShutdown
 	}
-	return c.doIO(b, c.write, opWrite)
+	if c.writeCoalescing.enabled() {
+		return c.bufferWrite(b)
+	}
+	return c.writeAll(b)
+}
+
+// beginCall registers an in-flight write, interlocking with Close below.
+func (c *Conn) beginCall() error {
+	for {
+		c.l.Logf(LogLevelDebug, "Write waiting...")
+		x := c.activeCall.Load()
+		if x&1 != 0 {
+			return net.ErrClosed
+		}
+		if c.activeCall.CompareAndSwap(x, x+2) {
+			return nil
+		}
+	}
+}
+
+func (c *Conn) endCall() {
+	c.activeCall.Add(-2)
 }
 
 // Shutdown will send a close-notify alert to the peer to gracefully shutdown
@@ -236,6 +785,8 @@ func (c *Conn) shutdown() error {
 func (c *Conn) Close() error {
 	c.l.Logf(LogLevelDebug, "Close begin")
 	defer c.l.Logf(LogLevelDebug, "Close end")
+	// A scheduled key update is not a concurrent call, and must not prevent the close_notify.
+	c.stopKeyUpdatePolicy()
 	var x int32
 	for {
 		c.l.Logf(LogLevelDebug, "Close waiting...")
@@ -272,30 +823,126 @@ func (c *Conn) Close() error {
 	return c.closeNotify()
 }
 
+// CloseWrite shuts down the writing side of the connection by sending a close_notify alert to
+// the peer, like [crypto/tls.Conn.CloseWrite]. Reads continue until the peer closes its side,
+// and later writes fail with [ErrShutdown]. It should only be called once the handshake has
+// completed, and does not close the underlying transport.
+func (c *Conn) CloseWrite() error {
+	if !c.handshakeComplete.Load() {
+		return ErrCloseWriteBeforeHandshake
+	}
+	if err := c.beginCall(); err != nil {
+		return err
+	}
+	defer c.endCall()
+	c.out.Lock()
+	defer c.out.Unlock()
+	return c.sendCloseNotify()
+}
+
 // closeNotify closes the Write side of the connection by sending a close notify shutdown alert
-// message to the peer.
+// message to the peer, waits for the peer's with [Config.WaitForCloseNotify], and frees the
+// connection.
 func (c *Conn) closeNotify() error {
 	c.l.Logf(LogLevelDebug, "Close-notify begin")
 	defer c.l.Logf(LogLevelDebug, "Close-notify end")
 	c.out.Lock()
 	defer c.out.Unlock()
-	if !c.closeNotifySent && !c.closed.Load() {
-		// Set a Write Deadline to prevent possibly blocking forever.
-		c.SetWriteDeadline(time.Now().Add(time.Second * 5))
-		_, c.closeErr = c.doIO(nil, func(b []byte) (int, error) { return 0, c.shutdown() },
-			opShutdown)
+	if c.closed.Load() {
+		return c.closeErr
+	}
+	defer c.closer.Done()
+	defer c.closer.Close()
+	// There is no session to shut down before the handshake completes.
+	if c.handshakeComplete.Load() {
+		err := c.sendCloseNotify()
+		if err == nil && c.config.WaitForCloseNotify {
+			err = c.awaitCloseNotify()
+		} else {
+			c.discardInput()
+		}
+		c.closeErr = err
 		c.l.Logf(LogLevelDebug, "Close error: %v", c.closeErr)
-		defer c.closer.Done()
-		defer c.closer.Close()
-		c.closeNotifySent = true
-		c.closed.Store(true)
-		// Any subsequent writes will fail.
-		c.SetWriteDeadline(time.Now())
 	}
+	c.closeNotifySent = true
+	c.closed.Store(true)
 	return c.closeErr
 }
 
-// SetDeadline sets the read and write deadlines of the [Conn] connection.
+// sendCloseNotify sends the close_notify alert once, after the buffered writes. The caller must
+// hold c.out.
+func (c *Conn) sendCloseNotify() error {
+	if c.closeNotifySent {
+		return c.closeErr
+	}
+	// Set a Write Deadline to prevent possibly blocking forever.
+	c.SetWriteDeadline(time.Now().Add(c.config.closeTimeout()))
+	c.stopFlushTimer()
+	if err := c.flush(); err != nil {
+		c.l.Logf(LogLevelErr, "Dropping %d buffered bytes on close", len(c.wbuf))
+	}
+	_, c.closeErr = c.doIO(nil, func(b []byte) (int, error) { return 0, c.shutdown() },
+		opShutdown)
+	c.closeNotifySent = true
+	// Any subsequent writes will fail.
+	c.SetWriteDeadline(time.Now())
+	return c.closeErr
+}
+
+// discardInput reads the records that already arrived without waiting for more. Closing a
+// socket with unread data resets the connection, which discards the data still in flight to the
+// peer.
+func (c *Conn) discardInput() {
+	if !c.in.TryLock() {
+		return
+	}
+	defer c.in.Unlock()
+	b := make([]byte, maxFragmentLen)
+	for i := 0; i < maxRetries; i++ {
+		// A BIO over a net.Conn only has the records read from the connection.
+		if c.bio.conn != nil {
+			c.bio.setReadDeadline(time.Now().Add(time.Millisecond))
+			if c.bio.reader.fill() != nil {
+				return
+			}
+		}
+		var err error
+		if c.withSSL(func() error {
+			_, err = c.read(b)
+			return nil
+		}) != nil || err != nil {
+			return
+		}
+	}
+}
+
+// awaitCloseNotify reads and discards data until the close_notify alert of the peer arrives or
+// [Config.CloseTimeout] expires. A Read in progress receives the alert instead, so there is
+// nothing to wait for then.
+func (c *Conn) awaitCloseNotify() error {
+	if !c.in.TryLock() {
+		c.l.Logf(LogLevelDebug, "Read in progress, not waiting for close notify")
+		return nil
+	}
+	defer c.in.Unlock()
+	c.SetReadDeadline(time.Now().Add(c.config.closeTimeout()))
+	b := make([]byte, maxFragmentLen)
+	for {
+		_, err := c.doIO(b, c.read, opRead)
+		if err == io.EOF {
+			c.l.Logf(LogLevelDebug, "Close notify received")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// SetDeadline sets the read and write deadlines of the [Conn] connection. Like [net.Conn], a
+// call that times out returns a [*net.OpError] wrapping [os.ErrDeadlineExceeded], and the
+// connection can be used again once the deadline is extended. A write that times out on a
+// connection using kTLS may have sent part of a record, and the connection must be closed.
 func (c *Conn) SetDeadline(t time.Time) error {
 	if err := c.SetReadDeadline(t); err != nil {
 		return err
@@ -323,8 +970,10 @@ func (t *atomicTime) Load() time.Time {
 // A zero value for t means Read will not time out.
 func (c *Conn) SetReadDeadline(t time.Time) error {
 	c.l.Logf(LogLevelDebug, "New rdeadline")
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
 	c.readDeadline.Store(t)
-	return nil
+	return c.bio.setReadDeadline(t)
 }
 
 // SetWriteDeadline sets the deadline for future [SSL.Write] calls
@@ -334,183 +983,240 @@ func (c *Conn) SetReadDeadline(t time.Time) error {
 // A zero value for t means Write will not time out.
 func (c *Conn) SetWriteDeadline(t time.Time) error {
 	c.l.Logf(LogLevelDebug, "New wdeadline")
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
 	c.writeDeadline.Store(t)
-	return nil
+	return c.bio.setWriteDeadline(t)
 }
 
-type ioResult struct {
-	n   int
-	err error
+// restoreDeadlines sets the read and write deadlines on the BIO again after the handshake.
+func (c *Conn) restoreDeadlines() {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	c.bio.setReadDeadline(c.readDeadline.Load())
+	c.bio.setWriteDeadline(c.writeDeadline.Load())
 }
 
+// ioWait is the socket readiness an SSL operation is waiting for.
+type ioWait int
+
+const (
+	waitNone ioWait = iota
+	waitRead
+	waitWrite
+)
+
 // retryResult represents the outcome of handling an SSL error
 type retryResult struct {
 	retry bool          // should retry the operation
 	err   error         // error to return if not retrying
 	sleep time.Duration // how long to sleep before retry
+	wait  ioWait        // socket readiness to wait for before retry
 }
 
 // retryable processes SSL errors and determines whether to retry operations
 func (c *Conn) retryable(err error, kind string) retryResult {
 	c.l.Logf(LogLevelDebug, "Got %v error", kind)
 	if err == nil {
-		return retryResult{false, nil, 0}
+		return retryResult{false, nil, 0, waitNone}
 	}
 
+	// An unexpected EOF is told apart by the error queue, which is drained by the log below.
+	sslErr, ok := err.(*libssl.SSLError)
+	truncated := ok && kind == opRead && libssl.SSLUnexpectedEOF(sslErr.Code)
+
 	// Handle SSL-specific errors
 	c.l.Logf(LogLevelDebug, "%v non-blocking got %v", kind, libssl.NewOpenSSLError(""))
-	if sslErr, ok := err.(*libssl.SSLError); ok {
+	if ok {
 		switch sslErr.Code {
-		case libssl.SSL_ERROR_WANT_READ, libssl.SSL_ERROR_WANT_WRITE:
-			c.l.Logf(LogLevelDebug, "%v non-blocking wants read/write", kind)
-			return retryResult{true, nil, time.Millisecond}
+		case libssl.SSL_ERROR_WANT_READ:
+			if kind == opShutdown {
+				// Our close notify was sent, don't wait for the peer's.
+				c.l.Logf(LogLevelDebug, "%v close notify sent", kind)
+				return retryResult{false, nil, 0, waitNone}
+			}
+			c.l.Logf(LogLevelDebug, "%v non-blocking wants read", kind)
+			return retryResult{true, nil, 0, waitRead}
+
+		case libssl.SSL_ERROR_WANT_WRITE:
+			c.l.Logf(LogLevelDebug, "%v non-blocking wants write", kind)
+			return retryResult{true, nil, 0, waitWrite}
 
 		case libssl.SSL_ERROR_ZERO_RETURN:
 			c.l.Logf(LogLevelDebug, "%v non-blocking return zero", kind)
-			return retryResult{false, io.EOF, 0}
+			return retryResult{false, io.EOF, 0, waitNone}
 
 		case libssl.SSL_ERROR_SSL:
 			switch kind {
 			case opShutdown:
 				c.l.Logf(LogLevelDebug, "%s non-blocking forced closed", kind)
-				return retryResult{false, nil, 0}
+				return retryResult{false, nil, 0, waitNone}
 			case opHandshake:
 				// Check verification error first
 				if verifyErr := libssl.SSLGetVerifyResult(c.ssl); verifyErr != nil {
-					return retryResult{false, verifyErr, 0}
+					return retryResult{false, verifyErr, 0, waitNone}
 				}
 			case opRead:
+				if truncated {
+					c.l.Logf(LogLevelInfo, "%v connection closed without close notify", kind)
+					return retryResult{false, ErrTruncated, 0, waitNone}
+				}
 				// Check verification error first
 				if verifyErr := libssl.SSLGetVerifyResult(c.ssl); verifyErr != nil {
-					return retryResult{false, verifyErr, 0}
+					return retryResult{false, verifyErr, 0, waitNone}
 				}
 				// otherwise, retry assuming its transient and ignore the error
 				// as it might be "SSL routines::sslv3 alert bad record mac" during
 				// connection closure.
-				return retryResult{true, nil, 0}
+				return retryResult{true, nil, 0, waitNone}
 			}
 			// For other operations, fallthrough to default error handling
 
 		case libssl.SSL_ERROR_SYSCALL:
+			if c.bio.conn != nil {
+				// The BIO keeps the error of the connection, or none at EOF.
+				if err := c.bio.takeError(); err != nil {
+					return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), err), 0,
+						waitNone}
+				}
+				// A close_notify fails reads with SSL_ERROR_ZERO_RETURN instead.
+				if kind == opRead {
+					c.l.Logf(LogLevelInfo, "%v connection closed without close notify", kind)
+					return retryResult{false, ErrTruncated, 0, waitNone}
+				}
+				return retryResult{false, io.EOF, 0, waitNone}
+			}
 			// Special handling for syscall errors
 			errno, sockErr := syscall.GetsockoptInt(c.bio.FD(), syscall.SOL_SOCKET,
 				syscall.SO_ERROR)
 			if sockErr != nil {
 				c.l.Logf(LogLevelDebug, "%s failed, could not get errno: %v", kind, errno)
-				return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), sockErr), 0}
+				return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), sockErr), 0,
+					waitNone}
 			}
 
 			if errno != 0 {
 				c.l.Logf(LogLevelDebug, "%s failed, errno: %v openssl error: %s", kind, errno,
 					libssl.NewOpenSSLError(""))
-				return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), err), 0}
+				return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), err), 0, waitNone}
+			}
+			if truncated {
+				c.l.Logf(LogLevelInfo, "%v connection closed without close notify", kind)
+				return retryResult{false, ErrTruncated, 0, waitNone}
 			}
 
 			// For other zero errno cases, retry
-			return retryResult{true, nil, time.Millisecond}
+			return retryResult{true, nil, time.Millisecond, waitNone}
 
 		case libssl.SSL_ERROR_WANT_CONNECT:
-			return retryResult{true, nil, time.Millisecond * 10}
+			return retryResult{true, nil, 0, waitWrite}
 
 		case libssl.SSL_ERROR_WANT_ACCEPT:
-			return retryResult{true, nil, time.Millisecond * 10}
+			return retryResult{true, nil, 0, waitRead}
 
 		case libssl.SSL_ERROR_WANT_X509_LOOKUP:
+			// A callback aborted the handshake
+			if err := c.callbacks.Err(); err != nil {
+				return retryResult{false, err, 0, waitNone}
+			}
 			// Certificate lookup in progress
-			return retryResult{true, nil, time.Millisecond * 100}
+			return retryResult{true, nil, time.Millisecond * 100, waitNone}
 		}
 	}
 
 	// Default error handling for non-SSL errors or unhandled SSL errors
 	c.l.Logf(LogLevelDebug, "%v error: %v", kind, err)
-	return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), err), 0}
+	return retryResult{false, newConnError(kind, c.bio.RemoteAddr(), err), 0, waitNone}
 }
 
-// ioLoop executes an SSL operation with proper error handling and retries
-func (c *Conn) ioLoop(b []byte, op func([]byte) (int, error), kind string, done <-chan struct{},
-	outCh chan<- ioResult) {
-	var retries int
-	maxRetries := 1000 // Prevent infinite loops
-
-	for retries < maxRetries {
-		select {
-		case <-done:
-			return
-		default:
-			r, err := op(b)
+// maxRetries bounds the retries of operations that fail without waiting on the socket.
+const maxRetries = 1000
 
-			retry := c.retryable(err, kind)
-			if !retry.retry {
-				select {
-				case outCh <- ioResult{r, retry.err}:
-				case <-done:
-				}
-				return
-			}
+// doIO executes an SSL operation with proper error handling and retries. When the operation
+// has to wait for the socket, the calling goroutine is parked in the Go netpoller until the
+// socket is ready, the deadline expires or the [Conn] is closed. Deadlines set while waiting
+// apply to the operation, and it can be retried after timing out.
+func (c *Conn) doIO(b []byte, op func([]byte) (int, error), kind string) (_ int, err error) {
+	c.l.Logf(LogLevelDebug, "%v non-blocking begin", kind)
+	defer c.l.Logf(LogLevelDebug, "%v non-blocking end", kind)
+	defer func() {
+		var opErr *net.OpError
+		if errors.Is(err, os.ErrDeadlineExceeded) && !errors.As(err, &opErr) {
+			err = newTimeoutError(kind, c.bio.LocalAddr(), c.bio.RemoteAddr())
+		}
+	}()
 
-			// Handle retry with optional sleep
-			if retry.sleep > 0 {
-				time.Sleep(retry.sleep)
+	// Like net.Conn, fail once the deadline is exceeded even if the operation could proceed.
+	if deadline := c.deadline(kind); !deadline.IsZero() && !time.Now().Before(deadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	var n int
+	var retry retryResult
+	want := waitNone
+	// attempt runs the operation and returns false if it has to wait for the same readiness
+	// again.
+	attempt := func() bool {
+		c.sslMu.Lock()
+		if c.sslFreed {
+			c.sslMu.Unlock()
+			n, retry = 0, retryResult{false, net.ErrClosed, 0, waitNone}
+			return true
+		}
+		var err error
+		n, err = op(b)
+		retry = c.retryable(err, kind)
+		flush := c.outputPending()
+		c.sslMu.Unlock()
+		// Send the records of the operation before waiting for the peer to respond to them.
+		if flush {
+			if err := c.flushOutput(); err != nil {
+				retry = retryResult{false, err, 0, waitNone}
+				return true
 			}
-
-			retries++
 		}
+		return !retry.retry || retry.wait != want
 	}
-
-	// Max retries exceeded
-	select {
-	case outCh <- ioResult{0, fmt.Errorf("%s: max retries exceeded", kind)}:
-	case <-done:
+	for retries := 0; ; {
+		switch want {
+		case waitRead:
+			err = c.bio.pollRead(attempt)
+		case waitWrite:
+			err = c.bio.pollWrite(attempt)
+		default:
+			attempt()
+		}
+		if err != nil {
+			c.l.Logf(LogLevelDebug, "%v non-blocking wait failed: %v", kind, err)
+			return 0, err
+		}
+		if !retry.retry {
+			return n, retry.err
+		}
+		want = retry.wait
+		if want != waitNone {
+			continue
+		}
+		if retries++; retries > maxRetries {
+			return 0, fmt.Errorf("%s: max retries exceeded", kind)
+		}
+		if deadline := c.deadline(kind); !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+		if retry.sleep > 0 {
+			time.Sleep(retry.sleep)
+		}
 	}
 }
 
-func (c *Conn) doIO(b []byte, op func([]byte) (int, error), kind string) (int, error) {
-	c.l.Logf(LogLevelDebug, "%v non-blocking begin", kind)
-	defer c.l.Logf(LogLevelDebug, "%v non-blocking end", kind)
-
-	done := make(chan struct{})
-	outCh := make(chan ioResult, 1)
-
-	// Start operation loop
-	go c.ioLoop(b, op, kind, done, outCh)
-
-	// Handle deadline
-	var timer *time.Timer
-	var timeoutCh <-chan time.Time
-
-	var deadline time.Time
+// deadline returns the deadline of the operation kind.
+func (c *Conn) deadline(kind string) time.Time {
 	switch kind {
 	case opHandshake:
-		deadline = c.handshakeDeadline.Load()
+		return c.handshakeDeadline.Load()
 	case opWrite, opShutdown:
-		deadline = c.writeDeadline.Load()
+		return c.writeDeadline.Load()
 	case opRead:
-		deadline = c.readDeadline.Load()
-	}
-
-	if !deadline.IsZero() {
-		if timeout := time.Until(deadline); timeout > 0 {
-			timer = time.NewTimer(timeout)
-			timeoutCh = timer.C
-		} else {
-			close(done)
-			return 0, os.ErrDeadlineExceeded
-		}
-		defer timer.Stop()
-	}
-
-	// Wait for result, timeout or close
-	select {
-	case r, ok := <-outCh:
-		if !ok {
-			return 0, net.ErrClosed
-		}
-		return r.n, r.err
-	case <-timeoutCh:
-		close(done)
-		return 0, os.ErrDeadlineExceeded
-	case <-c.closer.Done():
-		close(done)
-		return 0, c.closeErr
+		return c.readDeadline.Load()
 	}
+	return time.Time{}
 }
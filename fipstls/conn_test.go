@@ -0,0 +1,340 @@
+package fipstls_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+	"golang.org/x/net/nettest"
+)
+
+func TestConnBlockingIO(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dial := func(t *testing.T) net.Conn {
+		d := fipstls.NewDialer(&fipstls.Config{CaFile: ts.CaFile}, getFipsDialOpts()...)
+		conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		return conn
+	}
+	sleep := func(t *testing.T, conn net.Conn, d time.Duration) {
+		request := fmt.Sprintf("GET /sleep/%d HTTP/1.1\r\nHost: %s\r\n\r\n", d.Milliseconds(),
+			u.Host)
+		if _, err := conn.Write([]byte(request)); err != nil {
+			t.Fatalf("Failed to write request: %v", err)
+		}
+	}
+
+	t.Run("idle read without deadline", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+		sleep(t, conn, 1500*time.Millisecond)
+		response, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		if !strings.Contains(response, "HTTP/1.1 200") {
+			t.Errorf("Unexpected response: %s", response)
+		}
+	})
+
+	t.Run("deadline set while blocked", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+		sleep(t, conn, 2000*time.Millisecond)
+		time.AfterFunc(100*time.Millisecond, func() { conn.SetReadDeadline(time.Now()) })
+		start := time.Now()
+		_, err := conn.Read(make([]byte, 1024))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("Read() err = %v, want %v", err, os.ErrDeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Read() returned after %v, want it to return at the deadline", elapsed)
+		}
+	})
+
+	t.Run("close while blocked", func(t *testing.T) {
+		conn := dial(t)
+		sleep(t, conn, 2000*time.Millisecond)
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := conn.Read(make([]byte, 1024))
+			errCh <- err
+		}()
+		time.Sleep(100 * time.Millisecond)
+		conn.Close()
+		select {
+		case err := <-errCh:
+			if !errors.Is(err, net.ErrClosed) {
+				t.Errorf("Read() err = %v, want %v", err, net.ErrClosed)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Read() did not return after Close()")
+		}
+	})
+}
+
+// newTLSPeer returns a client Conn that completed its handshake with a crypto/tls server, the
+// server and its transport. The client uses a socket BIO if bio is "socket", and a BIO over a
+// net.Conn otherwise.
+func newTLSPeer(t *testing.T, bio string, config *fipstls.Config) (*fipstls.Conn, *tls.Conn,
+	net.Conn) {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(testutils.CertPath, testKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.CaFile = testutils.CertPath
+	config.ServerName = "localhost"
+	var client *fipstls.Conn
+	var raw net.Conn
+	if bio == "socket" {
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		d := fipstls.NewDialer(config, getFipsDialOpts()...)
+		t.Cleanup(func() { d.Close() })
+		connCh := make(chan *fipstls.Conn, 1)
+		go func() {
+			c, err := d.DialContext(context.Background(), "tcp4", ln.Addr().String())
+			if err != nil {
+				t.Errorf("Failed to dial: %v", err)
+				connCh <- nil
+				return
+			}
+			connCh <- c.(*fipstls.Conn)
+		}()
+		if raw, err = ln.Accept(); err != nil {
+			t.Fatal(err)
+		}
+		server := tls.Server(raw, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if err := server.Handshake(); err != nil {
+			t.Fatalf("Server Handshake() failed: %v", err)
+		}
+		if client = <-connCh; client == nil {
+			t.FailNow()
+		}
+		t.Cleanup(func() { client.Close(); raw.Close() })
+		return client, server, raw
+	}
+	c1, c2 := newConnPair(t, "tcp")
+	client = fipstls.Client(c1, config)
+	server := tls.Server(c2, &tls.Config{Certificates: []tls.Certificate{cert}})
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Handshake() }()
+	if err := client.Handshake(time.Time{}); err != nil {
+		t.Fatalf("Handshake() failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server Handshake() failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close(); c2.Close() })
+	return client, server, c2
+}
+
+func TestConnCloseWrite(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	for _, bio := range []string{"socket", "conn"} {
+		t.Run(bio, func(t *testing.T) {
+			client, server, _ := newTLSPeer(t, bio, &fipstls.Config{})
+			// Data the server sent before the close_notify is still read after it.
+			if _, err := server.Write([]byte("hello ")); err != nil {
+				t.Fatalf("Server Write() failed: %v", err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if _, err := client.Write([]byte("ping")); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			if err := client.CloseWrite(); err != nil {
+				t.Fatalf("CloseWrite() failed: %v", err)
+			}
+			if _, err := client.Write([]byte("ping")); !errors.Is(err, fipstls.ErrShutdown) {
+				t.Errorf("Write() after CloseWrite() err = %v, want %v", err,
+					fipstls.ErrShutdown)
+			}
+			// The server receives the data, then the close_notify, and still writes back.
+			server.SetDeadline(time.Now().Add(5 * time.Second))
+			if b, err := io.ReadAll(server); err != nil || string(b) != "ping" {
+				t.Fatalf("Server read %q, %v, want %q", b, err, "ping")
+			}
+			if _, err := server.Write([]byte("pong")); err != nil {
+				t.Fatalf("Server Write() failed: %v", err)
+			}
+			server.Close()
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if b, err := io.ReadAll(client); err != nil || string(b) != "hello pong" {
+				t.Errorf("Read after CloseWrite() = %q, %v, want %q", b, err, "hello pong")
+			}
+		})
+	}
+}
+
+func TestConnCloseWriteBeforeHandshake(t *testing.T) {
+	initTest(t)
+	c1, c2 := newConnPair(t, "pipe")
+	defer c2.Close()
+	client := fipstls.Client(c1, &fipstls.Config{})
+	defer client.Close()
+	if err := client.CloseWrite(); !errors.Is(err, fipstls.ErrCloseWriteBeforeHandshake) {
+		t.Errorf("CloseWrite() err = %v, want %v", err, fipstls.ErrCloseWriteBeforeHandshake)
+	}
+}
+
+func TestConnTruncation(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	for _, bio := range []string{"socket", "conn"} {
+		t.Run(bio+"/close_notify", func(t *testing.T) {
+			client, server, _ := newTLSPeer(t, bio, &fipstls.Config{})
+			server.Write([]byte("data"))
+			server.Close()
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if b, err := io.ReadAll(client); err != nil || string(b) != "data" {
+				t.Errorf("ReadAll() = %q, %v, want %q", b, err, "data")
+			}
+		})
+		t.Run(bio+"/truncated", func(t *testing.T) {
+			client, server, raw := newTLSPeer(t, bio, &fipstls.Config{})
+			server.Write([]byte("data"))
+			raw.Close()
+			client.SetReadDeadline(time.Now().Add(5 * time.Second))
+			b, err := io.ReadAll(client)
+			if string(b) != "data" {
+				t.Errorf("ReadAll() = %q, want %q", b, "data")
+			}
+			if !errors.Is(err, fipstls.ErrTruncated) || !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("ReadAll() err = %v, want %v", err, fipstls.ErrTruncated)
+			}
+		})
+	}
+}
+
+func TestConnWaitForCloseNotify(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	for _, bio := range []string{"socket", "conn"} {
+		t.Run(bio+"/received", func(t *testing.T) {
+			client, server, _ := newTLSPeer(t, bio, &fipstls.Config{WaitForCloseNotify: true})
+			const delay = 100 * time.Millisecond
+			go func() {
+				// Data sent before the close_notify is discarded by Close.
+				server.Write([]byte("data"))
+				io.Copy(io.Discard, server)
+				time.Sleep(delay)
+				server.Close()
+			}()
+			start := time.Now()
+			if err := client.Close(); err != nil {
+				t.Errorf("Close() failed: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < delay {
+				t.Errorf("Close() returned after %v, before the peer's close_notify", elapsed)
+			}
+		})
+		t.Run(bio+"/truncated", func(t *testing.T) {
+			client, server, raw := newTLSPeer(t, bio, &fipstls.Config{WaitForCloseNotify: true})
+			go func() {
+				io.Copy(io.Discard, server)
+				raw.Close()
+			}()
+			if err := client.Close(); !errors.Is(err, fipstls.ErrTruncated) {
+				t.Errorf("Close() err = %v, want %v", err, fipstls.ErrTruncated)
+			}
+		})
+		t.Run(bio+"/timeout", func(t *testing.T) {
+			client, _, _ := newTLSPeer(t, bio, &fipstls.Config{WaitForCloseNotify: true,
+				CloseTimeout: 100 * time.Millisecond})
+			start := time.Now()
+			if err := client.Close(); !errors.Is(err, os.ErrDeadlineExceeded) {
+				t.Errorf("Close() err = %v, want %v", err, os.ErrDeadlineExceeded)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Close() returned after %v, want it to return at CloseTimeout", elapsed)
+			}
+		})
+	}
+}
+
+// makeConnPipe returns a nettest.MakePipe creating a client and a server Conn over a loopback TCP
+// connection. The client uses a socket BIO if bio is "socket", and a BIO over a net.Conn
+// otherwise. The handshake runs on the first Read or Write.
+func makeConnPipe(bio string) nettest.MakePipe {
+	return func() (c1, c2 net.Conn, stop func(), err error) {
+		serverConfig := &fipstls.Config{CertFile: testutils.CertPath, KeyFile: testKeyPath}
+		clientConfig := &fipstls.Config{CaFile: testutils.CertPath, ServerName: "localhost"}
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer ln.Close()
+		if bio == "socket" {
+			// The Dialer completes the handshake, so the server has to run it meanwhile.
+			d := fipstls.NewDialer(clientConfig, getFipsDialOpts()...)
+			serverCh := make(chan *fipstls.Conn, 1)
+			go func() {
+				raw, err := ln.Accept()
+				if err != nil {
+					serverCh <- nil
+					return
+				}
+				s := fipstls.Server(raw, serverConfig)
+				s.Handshake(time.Now().Add(10 * time.Second))
+				serverCh <- s
+			}()
+			c1, err = d.DialContext(context.Background(), "tcp4", ln.Addr().String())
+			server := <-serverCh
+			if err != nil {
+				d.Close()
+				if server != nil {
+					server.Close()
+				}
+				return nil, nil, nil, err
+			}
+			return c1, server, func() { c1.Close(); server.Close(); d.Close() }, nil
+		}
+		raw, err := net.Dial("tcp4", ln.Addr().String())
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		raw2, err := ln.Accept()
+		if err != nil {
+			raw.Close()
+			return nil, nil, nil, err
+		}
+		c1 = fipstls.Client(raw, clientConfig)
+		c2 = fipstls.Server(raw2, serverConfig)
+		return c1, c2, func() { c1.Close(); c2.Close() }, nil
+	}
+}
+
+func TestConnNettest(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	for _, bio := range []string{"socket", "conn"} {
+		t.Run(bio, func(t *testing.T) {
+			nettest.TestConn(t, makeConnPipe(bio))
+		})
+	}
+}
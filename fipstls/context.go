@@ -3,19 +3,27 @@ package fipstls
 import (
 	"path/filepath"
 	"slices"
+	"sync"
 
 	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
 )
 
-// Context is used for configuring and creating SSL [Conn] connections.
+// Context is used for configuring and creating SSL [Conn] connections. A Context may be shared by
+// any number of connections.
 type Context struct {
 	ctx    *libssl.SSLCtx
 	closer Closer
+
+	mu     sync.Mutex
+	closed bool
 }
 
-// NewCtx configures the [Context] and allocates a C.SSL_CTX object.
+// NewCtx configures the [Context] and allocates a C.SSL_CTX object. The server method is used
+// if [Config.Method] is ServerMethod, and the client method otherwise.
 //
-// The C.SSL_CTX will be freed on [Conn.Close].
+// The C.SSL_CTX is reference counted: every [Conn] created from the [Context] holds a reference
+// to it, and the caller holds one until [Context.Close]. It is freed once the [Context] and all
+// of its connections are closed.
 func NewCtx(tls *Config) (*Context, error) {
 	if !libsslInit {
 		return nil, ErrNoLibSslInit
@@ -31,7 +39,22 @@ func NewCtx(tls *Config) (*Context, error) {
 }
 
 func (c *Context) new(tls *Config) error {
-	method, err := libssl.NewTLSClientMethod()
+	if tls.PSK != nil {
+		if err := tls.PSK.validate(); err != nil {
+			return err
+		}
+	}
+	if err := tls.validateRecordLayer(); err != nil {
+		return err
+	}
+	if tls.usesRPK() && !libssl.SupportsRPK() {
+		return ErrRawPublicKeyUnsupported
+	}
+	newMethod := libssl.NewTLSClientMethod
+	if tls.Method == ServerMethod {
+		newMethod = libssl.NewTLSServerMethod
+	}
+	method, err := newMethod()
 	if err != nil {
 		return err
 	}
@@ -51,6 +74,21 @@ func (c *Context) new(tls *Config) error {
 	return nil
 }
 
+const (
+	// fipsPSKCipherList replaces the TLS 1.2 PSK cipher suites in the defaults with the
+	// FIPS-approved ones listed in NIST SP 800-52r2.
+	fipsPSKCipherList = "DEFAULT:-PSK:" +
+		"ECDHE-PSK-AES128-CBC-SHA256:ECDHE-PSK-AES256-CBC-SHA384:" +
+		"DHE-PSK-AES128-GCM-SHA256:DHE-PSK-AES256-GCM-SHA384:" +
+		"PSK-AES128-GCM-SHA256:PSK-AES256-GCM-SHA384"
+
+	// fipsPSKCipherSuites are the FIPS-approved TLS 1.3 cipher suites usable with a PSK.
+	fipsPSKCipherSuites = "TLS_AES_128_GCM_SHA256:TLS_AES_256_GCM_SHA384"
+
+	// serverSessionIDContext is the session ID context of server contexts.
+	serverSessionIDContext = "fipstls"
+)
+
 // newCtxConfig creates the configuration that will be understood by the libssl SSLCtx APIs.
 func newCtxConfig(tls *Config) *libssl.CtxConfig {
 	// Copy common configuration options
@@ -62,10 +100,20 @@ func newCtxConfig(tls *Config) *libssl.CtxConfig {
 		CertFile: tls.CertFile,
 		KeyFile:  tls.KeyFile,
 	}
+	// libssl only asks for a client certificate if none is loaded
+	if tls.GetClientCertificate != nil {
+		ctxConfig.CertFile, ctxConfig.KeyFile = "", ""
+		ctxConfig.ClientCert = true
+	}
 	// Set path to CaFile if present
 	if tls.CaFile != "" && tls.CaPath == "" {
 		ctxConfig.CaPath = filepath.Dir(tls.CaFile)
 	}
+	// Servers resume sessions and external TLS 1.3 PSKs only with a session ID context
+	if tls.Method == ServerMethod {
+		ctxConfig.SessionIDContext = serverSessionIDContext
+		ctxConfig.MaxEarlyData = tls.MaxEarlyData
+	}
 	// Set h2 proto for HTTP/2 clients
 	if slices.Contains(tls.NextProtos, "h2") {
 		ctxConfig.NextProto = "h2"
@@ -74,12 +122,40 @@ func newCtxConfig(tls *Config) *libssl.CtxConfig {
 	if tls.SessionTicketsDisabled {
 		ctxConfig.Options |= libssl.SSL_OP_NO_TICKET
 	}
-	if tls.RenegotiationDisabled {
-		ctxConfig.Options |= libssl.SSL_OP_NO_SESSION_RESUMPTION_ON_RENEGOTIATION
+	if tls.renegotiation() == RenegotiateNever {
+		ctxConfig.Options |= libssl.SSL_OP_NO_RENEGOTIATION
+	} else {
+		ctxConfig.HandshakeStart = true
 	}
 	if tls.CompressionDisabled {
 		ctxConfig.Options |= libssl.SSL_OP_NO_COMPRESSION
 	}
+	ctxConfig.KTLS = tls.EnableKTLS
+	if tls.ReleaseBuffers {
+		ctxConfig.Mode |= libssl.SSL_MODE_RELEASE_BUFFERS
+	}
+	ctxConfig.ReadAhead = tls.ReadAhead
+	ctxConfig.ReadBufferLen = tls.ReadBufferLen
+	ctxConfig.MaxFragmentLength = tls.MaxFragmentLength
+	ctxConfig.MaxSendFragment = tls.MaxSendFragment
+	ctxConfig.SplitSendFragment = tls.SplitSendFragment
+	// A block size of one is the same as no padding
+	if tls.RecordPadding > 1 {
+		ctxConfig.BlockPadding = tls.RecordPadding
+	}
+	for _, alg := range tls.CertCompression {
+		ctxConfig.CertComp = append(ctxConfig.CertComp, int(alg))
+	}
+	ctxConfig.ClientCertTypes = certTypes(tls.ClientCertificateTypes)
+	ctxConfig.ServerCertTypes = certTypes(tls.ServerCertificateTypes)
+	ctxConfig.ExpectedRPKs = len(tls.PeerPublicKeys) > 0
+	// libssl only negotiates the PSK cipher suites once the PSK callbacks are installed
+	ctxConfig.PSKClient = tls.PSK != nil
+	ctxConfig.PSKServer = tls.GetPSK != nil
+	if (ctxConfig.PSKClient || ctxConfig.PSKServer) && FIPSMode() {
+		ctxConfig.CipherList = fipsPSKCipherList
+		ctxConfig.CipherSuites = fipsPSKCipherSuites
+	}
 	// Set verification mode to peer as default, using VerifyNone as the zero-value
 	verifyMode := tls.VerifyMode
 	if verifyMode == verifyNone {
@@ -109,7 +185,29 @@ func (c *Context) Ctx() *libssl.SSLCtx {
 	return c.ctx
 }
 
-// Close frees the C.SSL_CTX C object allocated for [Context].
+// Close releases the reference to the C.SSL_CTX C object held by the caller of [NewCtx]. The
+// C.SSL_CTX is freed once the connections created from the [Context] are closed too, and no new
+// connections can be created from it.
 func (c *Context) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
 	return c.closer.Close()
 }
+
+// retain takes a reference to the C.SSL_CTX for a new connection, and returns the closer
+// releasing it.
+func (c *Context) retain() (Closer, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || c.ctx == nil {
+		return nil, ErrContextClosed
+	}
+	if err := libssl.SSLCtxUpRef(c.ctx); err != nil {
+		return nil, err
+	}
+	ctx := c.ctx
+	return newOnceCloser(func() error {
+		return libssl.SSLCtxFree(ctx)
+	}), nil
+}
@@ -1,6 +1,11 @@
 package fipstls_test
 
 import (
+	"crypto"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
 	"testing"
 
 	"github.com/aristanetworks/go-openssl-fips/fipstls"
@@ -29,6 +34,49 @@ func TestNewCtx(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "PSK config",
+			config: &fipstls.Config{
+				PSK: &fipstls.PSK{Identity: "client", Key: []byte("secret"), Hash: crypto.SHA384},
+			},
+			wantErr: false,
+		},
+		{
+			name: "PSK without identity",
+			config: &fipstls.Config{
+				PSK: &fipstls.PSK{Key: []byte("secret")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "PSK key too long",
+			config: &fipstls.Config{
+				PSK: &fipstls.PSK{Identity: "client", Key: make([]byte, 257)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "PSK unsupported hash",
+			config: &fipstls.Config{
+				PSK: &fipstls.PSK{Identity: "client", Key: []byte("secret"), Hash: crypto.SHA1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid max fragment length",
+			config: &fipstls.Config{
+				MaxFragmentLength: 1000,
+			},
+			wantErr: true,
+		},
+		{
+			name: "Split send fragment above max send fragment",
+			config: &fipstls.Config{
+				MaxSendFragment:   1024,
+				SplitSendFragment: 2048,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -40,6 +88,13 @@ func TestNewCtx(t *testing.T) {
 			if !tc.wantErr && ctx == nil {
 				t.Fatal("Expected non-nil Context when no error")
 			}
+			if tc.wantErr {
+				// NewCtx returns the partially configured Context on error
+				if ctx != nil {
+					ctx.Close()
+				}
+				return
+			}
 			if ctx != nil {
 				// Test that we can access the underlying context
 				sslCtx := ctx.Ctx()
@@ -96,6 +151,27 @@ func TestContextWithConfig(t *testing.T) {
 				CompressionDisabled:    true,
 			},
 		},
+		{
+			name: "With renegotiation once as client",
+			config: &fipstls.Config{
+				Renegotiation: fipstls.RenegotiateOnceAsClient,
+			},
+		},
+		{
+			name: "With renegotiation freely as client",
+			config: &fipstls.Config{
+				Renegotiation: fipstls.RenegotiateFreelyAsClient,
+			},
+		},
+		{
+			name: "With record layer limits",
+			config: &fipstls.Config{
+				MaxFragmentLength: 1024,
+				MaxSendFragment:   4096,
+				SplitSendFragment: 1024,
+				RecordPadding:     512,
+			},
+		},
 		{
 			name: "With verify modes",
 			config: &fipstls.Config{
@@ -108,6 +184,23 @@ func TestContextWithConfig(t *testing.T) {
 				InsecureSkipVerify: true,
 			},
 		},
+		{
+			name: "With certificate compression",
+			config: &fipstls.Config{
+				CertCompression: []fipstls.CertCompressionAlgorithm{
+					fipstls.CertCompressionZstd,
+					fipstls.CertCompressionBrotli,
+					fipstls.CertCompressionZlib,
+				},
+			},
+		},
+		{
+			name: "With PSK server",
+			config: &fipstls.Config{
+				Method: fipstls.ServerMethod,
+				GetPSK: func(string) (*fipstls.PSK, error) { return nil, nil },
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -124,3 +217,49 @@ func TestContextWithConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestContextReferences(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ln := newTLSListener(t, tls.VersionTLS13)
+	defer ln.Close()
+	errCh := serveTLS(ln, func(tls.ConnectionState) ([]byte, error) { return []byte("ok"), nil })
+
+	ctx, err := fipstls.NewCtx(&fipstls.Config{CaFile: testutils.CertPath})
+	if err != nil {
+		t.Fatalf("NewCtx() error = %v", err)
+	}
+	nc, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		ctx.Close()
+		t.Fatal(err)
+	}
+	bio, err := fipstls.NewConnBIO(nc)
+	if err != nil {
+		ctx.Close()
+		t.Fatalf("NewConnBIO() error = %v", err)
+	}
+	config := &fipstls.Config{CaFile: testutils.CertPath, ServerName: "localhost"}
+	conn, err := fipstls.NewConn(ctx, bio, config, nil)
+	if err != nil {
+		ctx.Close()
+		bio.Close()
+		t.Fatalf("NewConn() error = %v", err)
+	}
+	defer conn.Close()
+
+	// The connection keeps its reference to the context after the context is closed.
+	if err := ctx.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if _, err := fipstls.NewConn(ctx, bio, config, nil); !errors.Is(err, fipstls.ErrContextClosed) {
+		t.Errorf("NewConn() after Close err = %v, want %v", err, fipstls.ErrContextClosed)
+	}
+	b, err := io.ReadAll(conn)
+	if err != nil || string(b) != "ok" {
+		t.Errorf("ReadAll() = %q, %v, want %q", b, err, "ok")
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("Server failed: %v", err)
+	}
+}
@@ -2,9 +2,12 @@ package fipstls
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
 	"net"
+	"runtime"
+	"sync"
 	"time"
 )
 
@@ -13,14 +16,18 @@ var DefaultNetwork = "tcp4"
 
 const dialLogPrefix = "[fipstls.Dialer]"
 
-// Dialer is used for dialing [Conn] connections.
+// Dialer is used for dialing [Conn] connections. The connections share a single [Context],
+// created on the first dial, which is freed once the [Dialer] and all of its connections are
+// closed. A [Dialer] created with [NewDialer] is also closed when it is garbage collected, but
+// only [Dialer.Close] frees the [Context] deterministically.
 type Dialer struct {
-	// TLS is used for configuring the [Context] used in creating [Conn] connections.
+	// TLS is used for configuring the [Context] used in creating [Conn] connections. It must
+	// not be modified after the first dial.
 	TLS *Config
 
 	// Timeout is the maximum amount of time a dial will wait for
-	// a connect to complete. If Deadline is also set, it may fail
-	// earlier.
+	// a connect and the TLS handshake to complete. If Deadline is
+	// also set, it may fail earlier.
 	//
 	// The default is no timeout.
 	//
@@ -41,9 +48,25 @@ type Dialer struct {
 	// Network is one of "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only), or "unix".
 	Network string
 
+	// NetDialer establishes the connections the TLS handshake runs over. Its Resolver,
+	// LocalAddr, KeepAlive, Control and FallbackDelay settings apply to every dial, and its
+	// Timeout and Deadline in addition to the ones of the [Dialer]. If nil, a zero
+	// [net.Dialer] is used.
+	NetDialer *net.Dialer
+
 	// Logger will be used to print logs at 3 verbosity levels:
 	// [LevelError], [LevelInfo], and [LevelDebug].
 	Logger Logger
+
+	// KeyUpdatePolicy triggers automatic TLS 1.3 key updates on dialed connections.
+	KeyUpdatePolicy KeyUpdatePolicy
+
+	// WriteCoalescing buffers small writes on dialed connections.
+	WriteCoalescing WriteCoalescing
+
+	mu     sync.Mutex
+	ctx    *Context
+	closed bool
 }
 
 // DialOption is used for configuring the [Dialer].
@@ -70,6 +93,13 @@ func WithNetwork(network string) DialOption {
 	}
 }
 
+// WithNetDialer sets the [net.Dialer] establishing the connections of the dialer.
+func WithNetDialer(nd *net.Dialer) DialOption {
+	return func(d *Dialer) {
+		d.NetDialer = nd
+	}
+}
+
 // WithLogging enables logging with the specified level and prefix and will write
 // logs by calling the stdlib logging [log.Logger.Printf].
 func WithLogging(prefix string, level LogLevel, w io.Writer) DialOption {
@@ -91,6 +121,20 @@ func WithLogger(l Logger) DialOption {
 	}
 }
 
+// WithKeyUpdatePolicy sets the policy for automatic TLS 1.3 key updates on dialed connections.
+func WithKeyUpdatePolicy(p KeyUpdatePolicy) DialOption {
+	return func(d *Dialer) {
+		d.KeyUpdatePolicy = p
+	}
+}
+
+// WithWriteCoalescing sets the policy for buffering small writes on dialed connections.
+func WithWriteCoalescing(p WriteCoalescing) DialOption {
+	return func(d *Dialer) {
+		d.WriteCoalescing = p
+	}
+}
+
 // NewDialer is returns a [Dialer] configured with [DialOption].
 func NewDialer(tls *Config, opts ...DialOption) *Dialer {
 	if tls == nil {
@@ -101,12 +145,18 @@ func NewDialer(tls *Config, opts ...DialOption) *Dialer {
 	for _, o := range opts {
 		o(d)
 	}
+	// Dialers that are never closed, like the ones of NewDialContext, release their reference
+	// to the Context once they are unreachable.
+	runtime.SetFinalizer(d, (*Dialer).Close)
 	return d
 }
 
 // DialContext specifies a dial function for creating [Conn] connections.
 // The network must be one of "tcp", "tcp4" (IPv4-only), "tcp6" (IPv6-only), or
-// "unix".
+// "unix". For "unix", addr is the path of the socket, or '@' followed by the name
+// of a Linux abstract socket. As the socket has no hostname, the server certificate
+// is verified against [Config.ServerName], which is required unless
+// [Config.InsecureSkipVerify] is set.
 func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
 	d.Network = network
 	if d.Network == "" {
@@ -118,7 +168,29 @@ func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Con
 	return d.dial(ctx, addr)
 }
 
-// NewDialContext returns a dial function for grpc to create [Conn] connections.
+// DialEarlyContext dials a [Conn] like [Dialer.DialContext] and sends earlyData to the server.
+// If a session permitting TLS 1.3 early data is cached for the peer in
+// [Config.ClientSessionCache], earlyData is sent as 0-RTT early data with the ClientHello and
+// transparently resent after the handshake if the server rejects it. Otherwise it is written as
+// regular application data once the handshake completes.
+//
+// Early data can be replayed by an attacker and processed more than once by the server. Only
+// send requests that are idempotent and safe to replay. See [Conn.WriteEarlyData].
+func (d *Dialer) DialEarlyContext(ctx context.Context, network, addr string,
+	earlyData []byte) (net.Conn, error) {
+	d.Network = network
+	if d.Network == "" {
+		d.Network = DefaultNetwork
+	}
+	if d.Logger == nil {
+		d.Logger = noopLogger{}
+	}
+	return d.dialEarly(ctx, addr, earlyData)
+}
+
+// NewDialContext returns a dial function for grpc to create [Conn] connections. The connections
+// share a [Context] that lives as long as the dial function and the connections: it is freed
+// once the dial function is garbage collected and the connections are closed.
 func NewDialContext(tls *Config, opts ...DialOption) func(context.Context,
 	string) (net.Conn, error) {
 	if tls == nil {
@@ -130,73 +202,153 @@ func NewDialContext(tls *Config, opts ...DialOption) func(context.Context,
 	return d.dial
 }
 
-type dialResult struct {
-	bio *BIO
-	err error
+func (d *Dialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	return d.dialEarly(ctx, addr, nil)
 }
 
-func (d *Dialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+func (d *Dialer) dialEarly(ctx context.Context, addr string, earlyData []byte) (net.Conn, error) {
 	d.Logger.Logf(LogLevelInfo, "Dialing with FIPS Mode = %v, Version = %s, ProviderInfo = %s",
 		FIPSMode(), Version(), ProviderInfo())
+	if d.Network == "unix" && d.TLS.ServerName == "" && !d.TLS.InsecureSkipVerify {
+		d.Logger.Logf(LogLevelErr, "Dialing '%s' requires a ServerName", addr)
+		return nil, ErrMissingServerName
+	}
+	// The deadline bounds both the connect and the handshake.
+	deadline := d.deadline(ctx, time.Now())
+	if !deadline.IsZero() {
+		if d, ok := ctx.Deadline(); !ok || deadline.Before(d) {
+			subCtx, cancel := context.WithDeadline(ctx, deadline)
+			defer cancel()
+			ctx = subCtx
+		}
+	}
 	bio, err := d.dialBIO(ctx, d.Network, addr)
 	if err != nil {
 		bio.Close()
 		return nil, err
 	}
-	return d.newConn(bio)
+	return d.newConn(ctx, bio, earlyData)
 }
 
 func (d *Dialer) dialBIO(ctx context.Context, network, addr string) (*BIO, error) {
 	d.Logger.Logf(LogLevelInfo, "Dialing '%s:%s' begin", network, addr)
 	defer d.Logger.Logf(LogLevelInfo, "Dialing '%s:%s' end", network, addr)
-	deadline := d.deadline(ctx, time.Now())
-	if !deadline.IsZero() {
-		if d, ok := ctx.Deadline(); !ok || deadline.Before(d) {
-			subCtx, cancel := context.WithDeadline(ctx, deadline)
-			defer cancel()
-			ctx = subCtx
-		}
+	nd := d.NetDialer
+	if nd == nil {
+		nd = &net.Dialer{}
 	}
-	ch := make(chan dialResult)
-	go func() {
-		// create non-blocking BIO
-		b, err := NewBIO(addr, network, SOCK_NONBLOCK)
-		ch <- dialResult{b, err}
-	}()
-	select {
-	case <-ctx.Done():
-		return &BIO{closer: noopCloser{}}, ctx.Err()
-	case b := <-ch:
-		if b.err != nil {
-			d.Logger.Logf(LogLevelErr, "Creating BIO failed: %v", b.err)
-			return b.bio, b.err
-		}
-		return b.bio, nil
+	b, err := dialSocketBIO(ctx, nd, network, addr, SOCK_NONBLOCK)
+	if err != nil {
+		d.Logger.Logf(LogLevelErr, "Creating BIO failed: %v", err)
+		return b, err
 	}
+	return b, nil
 }
 
-func (d *Dialer) newConn(bio *BIO) (net.Conn, error) {
+// newConn creates a [Conn] over bio and runs the handshake. If ctx is done before the [Conn] is
+// returned, the [Conn] is closed and the error of ctx is returned.
+func (d *Dialer) newConn(ctx context.Context, bio *BIO, earlyData []byte) (c net.Conn,
+	err error) {
 	d.Logger.Logf(LogLevelInfo, "New connection: %s", bio)
-	ctx, err := NewCtx(d.TLS)
+	sslCtx, err := d.sharedCtx()
 	if err != nil {
 		d.Logger.Logf(LogLevelErr, "Creating context failed: %v", err)
-		ctx.Close()
+		bio.Close()
 		return nil, err
 	}
-	conn, err := NewConn(ctx, bio, d.TLS, d.Logger)
+	conn, err := NewConn(sslCtx, bio, d.TLS, d.Logger)
 	if err != nil {
 		d.Logger.Logf(LogLevelErr, "Creating connection failed: %v", err)
-		conn.Close()
+		bio.Close()
+		return nil, err
+	}
+	// Closing the socket interrupts the handshake when ctx is done. The SSL object is only
+	// freed once the handshake returned, by closing the connection on every failure.
+	stop := context.AfterFunc(ctx, func() { bio.closeIO() })
+	defer func() {
+		if !stop() && err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err = ctxErr
+			}
+			conn.Close()
+			c = nil
+		}
+	}()
+	conn.keyUpdatePolicy = d.KeyUpdatePolicy
+	if err := conn.enableWriteCoalescing(d.WriteCoalescing); err != nil {
+		d.Logger.Logf(LogLevelErr, "Enabling write coalescing failed: %v", err)
 		return nil, err
 	}
-	if err := conn.Handshake(d.Deadline); err != nil {
+	var sendAfterHandshake bool
+	if len(earlyData) > 0 {
+		if _, err := conn.WriteEarlyData(earlyData); err != nil {
+			if !errors.Is(err, ErrEarlyDataUnavailable) {
+				d.Logger.Logf(LogLevelErr, "Writing early data failed: %v", err)
+				return nil, err
+			}
+			d.Logger.Logf(LogLevelInfo, "Early data unavailable, sending after handshake")
+			sendAfterHandshake = true
+		}
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.Handshake(deadline); err != nil {
 		d.Logger.Logf(LogLevelErr, "Handshake failed: %v", err)
-		conn.Close()
 		return nil, err
 	}
+	if sendAfterHandshake {
+		if _, err := conn.Write(earlyData); err != nil {
+			d.Logger.Logf(LogLevelErr, "Writing data failed: %v", err)
+			return nil, err
+		}
+	}
 	return conn, nil
 }
 
+// sharedCtx returns the [Context] shared by the connections of the [Dialer], creating it on the
+// first call.
+func (d *Dialer) sharedCtx() (*Context, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, ErrContextClosed
+	}
+	if d.ctx == nil {
+		ctx, err := NewCtx(d.TLS)
+		if err != nil {
+			ctx.Close()
+			return nil, err
+		}
+		d.ctx = ctx
+	}
+	return d.ctx, nil
+}
+
+// Close releases the [Context] shared by the dialed connections. Connections that are still
+// open keep their reference to it, and it is freed once they are closed. Dials fail with
+// [ErrContextClosed] after Close.
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	return d.releaseCtx()
+}
+
+// releaseCtx releases the [Context] shared by the connections dialed so far. Unless the [Dialer]
+// is closed, the next dial creates a new one.
+func (d *Dialer) releaseCtx() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.ctx == nil {
+		return nil
+	}
+	err := d.ctx.Close()
+	d.ctx = nil
+	return err
+}
+
 // deadline returns the earliest of:
 //   - now+Timeout
 //   - d.Deadline
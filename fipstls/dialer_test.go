@@ -2,22 +2,28 @@ package fipstls_test
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
 
 	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
 	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
 	pb "github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils/proto"
 )
@@ -362,8 +368,18 @@ func TestGrpcBidiStress(t *testing.T) {
 
 func BenchmarkGrpcBidiStream(b *testing.B) {
 	initTest(nil)
+	b.Run("Write", func(b *testing.B) {
+		benchmarkGrpcBidiStream(b, getFipsDialOpts()...)
+	})
+	b.Run("WriteCoalescing", func(b *testing.B) {
+		benchmarkGrpcBidiStream(b, append(getFipsDialOpts(),
+			fipstls.WithWriteCoalescing(fipstls.WriteCoalescing{Delay: time.Millisecond}))...)
+	})
+}
+
+func benchmarkGrpcBidiStream(b *testing.B, opts ...fipstls.DialOption) {
 	// Setup test server and client (extracted to helper function)
-	client, cleanup := testutils.NewGrpcTestClientServer(b, *useNetDial, getFipsDialOpts()...)
+	client, cleanup := testutils.NewGrpcTestClientServer(b, *useNetDial, opts...)
 	defer cleanup()
 
 	// Test configuration - fixed size for each iteration
@@ -449,3 +465,301 @@ func BenchmarkGrpcBidiStream(b *testing.B) {
 		b.ReportMetric(float64(memStats.TotalAlloc-startAlloc)/float64(totalMessages), "B/msg")
 	}
 }
+
+func TestDialCertCompression(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crypto/tls servers don't support certificate compression, so it's not negotiated.
+	logs := &logRecorder{debug: true}
+	d := fipstls.NewDialer(&fipstls.Config{
+		CaFile:          ts.CaFile,
+		CertCompression: []fipstls.CertCompressionAlgorithm{fipstls.CertCompressionZlib},
+	}, fipstls.WithLogger(logs))
+	conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if alg := conn.(*fipstls.Conn).ConnectionState().CertCompression; alg != fipstls.CertCompressionNone {
+		t.Errorf("CertCompression = %v, want %v", alg, fipstls.CertCompressionNone)
+	}
+	want := 0
+	if !libssl.SupportsCertCompression() {
+		want = 1
+	}
+	if got := logs.count("Certificate compression ignored"); got != want {
+		t.Errorf("Logged %d certificate compression fallbacks, want %d", got, want)
+	}
+}
+
+func TestDialerSharedContext(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ln := newEchoListener(t, tls.VersionTLS13)
+	defer ln.Close()
+
+	d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath}, getFipsDialOpts()...)
+	echo := func(conn net.Conn) {
+		t.Helper()
+		msg := []byte("ping")
+		if _, err := conn.Write(msg); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		got := make([]byte, len(msg))
+		if _, err := io.ReadFull(conn, got); err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("Read() = %q, %v, want %q", got, err, msg)
+		}
+	}
+	addr := strings.Replace(ln.Addr().String(), "127.0.0.1", "localhost", 1)
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := d.DialContext(context.Background(), "tcp4", addr)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		echo(conn)
+		conns = append(conns, conn)
+	}
+
+	// Open connections keep the context after the Dialer is closed.
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if _, err := d.DialContext(context.Background(), "tcp4", addr); !errors.Is(err,
+		fipstls.ErrContextClosed) {
+		t.Errorf("DialContext() after Close err = %v, want %v", err, fipstls.ErrContextClosed)
+	}
+	for _, conn := range conns {
+		echo(conn)
+		if err := conn.Close(); err != nil {
+			t.Errorf("Close() failed: %v", err)
+		}
+	}
+}
+
+func TestDialerNetDialer(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ln := newEchoListener(t, tls.VersionTLS13)
+	defer ln.Close()
+
+	var controlAddr string
+	nd := &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)},
+		KeepAlive: time.Minute,
+		Control: func(network, address string, c syscall.RawConn) error {
+			controlAddr = address
+			return nil
+		},
+	}
+	d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath},
+		append(getFipsDialOpts(), fipstls.WithNetDialer(nd))...)
+	defer d.Close()
+	addr := strings.Replace(ln.Addr().String(), "127.0.0.1", "localhost", 1)
+	conn, err := d.DialContext(context.Background(), "tcp4", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+	if controlAddr != ln.Addr().String() {
+		t.Errorf("Control() address = %q, want %q", controlAddr, ln.Addr())
+	}
+	laddr, ok := conn.LocalAddr().(*net.TCPAddr)
+	if !ok || !laddr.IP.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("LocalAddr() = %v, want 127.0.0.1", conn.LocalAddr())
+	}
+	if conn.RemoteAddr().String() != ln.Addr().String() {
+		t.Errorf("RemoteAddr() = %v, want %v", conn.RemoteAddr(), ln.Addr())
+	}
+	msg := []byte("ping")
+	if _, err := conn.Write(msg); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	got := make([]byte, len(msg))
+	if _, err := io.ReadFull(conn, got); err != nil || !bytes.Equal(got, msg) {
+		t.Fatalf("Read() = %q, %v, want %q", got, err, msg)
+	}
+}
+
+// newSilentListener returns a listener accepting connections that never respond to the
+// ClientHello. The connections are closed with the listener.
+func newSilentListener(t *testing.T) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				conn.Close()
+			}
+		}()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+	return ln
+}
+
+func TestDialerCancel(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ln := newSilentListener(t)
+	defer ln.Close()
+
+	tests := []struct {
+		name string
+		addr string
+	}{
+		// 192.0.2.0/24 is reserved for documentation, and does not answer connection attempts.
+		{name: "connect", addr: "192.0.2.1:443"},
+		{name: "handshake", addr: ln.Addr().String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "connect" {
+				// Without a route, the connect fails before it can be cancelled.
+				var netErr net.Error
+				conn, err := net.DialTimeout("tcp4", tt.addr, 200*time.Millisecond)
+				if err == nil {
+					conn.Close()
+					t.Skipf("%s accepted the connection", tt.addr)
+				}
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					t.Skipf("%s is unreachable: %v", tt.addr, err)
+				}
+			}
+			goroutines := runtime.NumGoroutine()
+			d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath},
+				getFipsDialOpts()...)
+			defer d.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(100*time.Millisecond, cancel)
+			start := time.Now()
+			conn, err := d.DialContext(ctx, "tcp4", tt.addr)
+			if err == nil {
+				conn.Close()
+				t.Fatal("DialContext() succeeded, want error")
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("DialContext() returned after %v, want cancellation", elapsed)
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("DialContext() err = %v, want %v", err, context.Canceled)
+			}
+			d.Close()
+			for i := 0; runtime.NumGoroutine() > goroutines && i < 100; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if n := runtime.NumGoroutine(); n > goroutines {
+				t.Errorf("%d goroutines leaked", n-goroutines)
+			}
+		})
+	}
+}
+
+func TestDialerTimeout(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ln := newSilentListener(t)
+	defer ln.Close()
+
+	// The timeout of the Dialer bounds the handshake as well as the connect.
+	d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath},
+		append(getFipsDialOpts(), fipstls.WithTimeout(100*time.Millisecond))...)
+	defer d.Close()
+	_, err := d.DialContext(context.Background(), "tcp4", ln.Addr().String())
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("DialContext() err = %v, want a timeout", err)
+	}
+}
+
+func TestDialerUnix(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	tests := []struct {
+		name string
+		addr string
+	}{
+		{name: "path", addr: filepath.Join(t.TempDir(), "fipstls.sock")},
+		{name: "abstract", addr: fmt.Sprintf("@fipstls-test-%d", os.Getpid())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.name == "abstract" && runtime.GOOS != "linux" {
+				t.Skip("Abstract Unix sockets require Linux")
+			}
+			ln := listenEcho(t, "unix", tt.addr, tls.VersionTLS13)
+			defer ln.Close()
+			d := fipstls.NewDialer(&fipstls.Config{
+				CaFile:     testutils.CertPath,
+				ServerName: "localhost",
+			}, getFipsDialOpts()...)
+			defer d.Close()
+			// The socket has no hostname to verify the certificate against.
+			noName := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath},
+				getFipsDialOpts()...)
+			defer noName.Close()
+			if _, err := noName.DialContext(context.Background(), "unix", tt.addr); !errors.Is(
+				err, fipstls.ErrMissingServerName) {
+				t.Errorf("DialContext() without ServerName err = %v, want %v", err,
+					fipstls.ErrMissingServerName)
+			}
+			conn, err := d.DialContext(context.Background(), "unix", tt.addr)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			if _, ok := conn.LocalAddr().(*net.UnixAddr); !ok {
+				t.Errorf("LocalAddr() = %T, want *net.UnixAddr", conn.LocalAddr())
+			}
+			raddr, ok := conn.RemoteAddr().(*net.UnixAddr)
+			if !ok || raddr.Name != tt.addr {
+				t.Errorf("RemoteAddr() = %#v, want *net.UnixAddr for %q", conn.RemoteAddr(),
+					tt.addr)
+			}
+			msg := []byte("ping")
+			if _, err := conn.Write(msg); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			got := make([]byte, len(msg))
+			if _, err := io.ReadFull(conn, got); err != nil || !bytes.Equal(got, msg) {
+				t.Fatalf("Read() = %q, %v, want %q", got, err, msg)
+			}
+		})
+	}
+}
+
+func TestNewDialContextRelease(t *testing.T) {
+	initTest(t)
+	// The context of the dial function is freed once it is garbage collected.
+	defer testutils.LeakCheck(t)
+	ln := newEchoListener(t, tls.VersionTLS13)
+	defer ln.Close()
+
+	dial := fipstls.NewDialContext(&fipstls.Config{CaFile: testutils.CertPath},
+		getFipsDialOpts()...)
+	addr := strings.Replace(ln.Addr().String(), "127.0.0.1", "localhost", 1)
+	conn, err := dial(context.Background(), addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+}
@@ -8,9 +8,10 @@
 
 // There are three structs that the caller may use in creating TLS connections:
 //   - The [Config] struct is used for configuring TLS options for the [Context].
-//   - The [Dialer] creates a [Context] for every new [Conn] connection. The [Conn] connection is
-//     responsible for freeing the C memory allocated by OpenSSL when
-//     it is closed.
+//   - The [Dialer] creates a [Context] on its first dial and shares it between its [Conn]
+//     connections. The C memory allocated by OpenSSL for the [Context] is freed once the
+//     [Dialer] and all of its connections are closed, or once the [Dialer] is garbage
+//     collected if it was never closed.
 //   - The [Transport] calls into the [Dialer] for creating a new TLS connection
-//     every roundtrip.
+//     every roundtrip. [Transport.CloseIdleConnections] releases the [Context] of the [Dialer].
 package fipstls
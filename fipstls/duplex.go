@@ -0,0 +1,164 @@
+package fipstls
+
+import (
+	"io"
+	"net"
+	"sync"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// OpenSSL does not allow an SSL object to be used by several threads at once, so every SSL
+// operation of a [Conn] runs while holding sslMu. Reads and writes still run concurrently, as
+// sslMu is never held while waiting for the peer:
+//
+//   - Sockets are non-blocking, and a read waiting for data waits in the netpoller.
+//   - A BIO over a net.Conn reads from the connection into a connReader without holding sslMu,
+//     and libssl is asked to retry while the connReader is empty.
+//   - libssl writes records into a memory BIO, the output BIO, which never asks libssl to
+//     retry. flushOutput sends them to the peer without holding sslMu.
+//
+// As libssl never has to retry a write, a read that sends a message, such as the response to a
+// TLS 1.3 KeyUpdate, never finds the record of a pending write in the way.
+//
+// kTLS requires libssl to write to the socket, so connections enabling it do not use an output
+// BIO, and a read that has to send a message while a write waits for the socket fails.
+
+// outputBufferSize is the size of the buffers the records of the output BIO are sent from.
+const outputBufferSize = 4 * maxFragmentLen
+
+var outputBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, outputBufferSize)
+		return &b
+	},
+}
+
+// withSSL calls f while holding the SSL object, unless it was freed.
+func (c *Conn) withSSL(f func() error) error {
+	c.sslMu.Lock()
+	defer c.sslMu.Unlock()
+	if c.sslFreed {
+		return net.ErrClosed
+	}
+	return f()
+}
+
+// outputPending returns true if libssl queued records in the output BIO, or if a failed
+// flushOutput left records to send, typically because the write deadline expired. The caller
+// must hold c.sslMu.
+func (c *Conn) outputPending() bool {
+	return c.wbio != nil && (c.unsent.Load() || !c.sslFreed && libssl.BIOPending(c.wbio) > 0)
+}
+
+// flushOutput sends the records queued in the output BIO to the peer, in order. It returns once
+// the records queued before the call are sent, possibly by a concurrent flushOutput.
+func (c *Conn) flushOutput() error {
+	if c.wbio == nil {
+		return nil
+	}
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+	// Data that could not be sent stays in flushPending, and is sent first by the next call so
+	// that the records stay in order.
+	for {
+		if len(c.flushPending) == 0 {
+			if c.flushBuf == nil {
+				c.flushBuf = outputBufferPool.Get().(*[]byte)
+			}
+			var n int
+			c.withSSL(func() error {
+				n = libssl.BIORead(c.wbio, *c.flushBuf)
+				return nil
+			})
+			if n == 0 {
+				outputBufferPool.Put(c.flushBuf)
+				c.flushBuf = nil
+				return nil
+			}
+			c.flushPending = (*c.flushBuf)[:n]
+		}
+		n, err := c.bio.write(c.flushPending)
+		c.flushPending = c.flushPending[n:]
+		c.unsent.Store(len(c.flushPending) > 0)
+		if err != nil {
+			c.l.Logf(LogLevelDebug, "Sending %d bytes of records failed: %v", len(c.flushPending),
+				err)
+			return err
+		}
+	}
+}
+
+// connReader buffers the data read from the net.Conn of a [BIO] created with [NewConnBIO].
+// libssl reads from it while holding the SSL object, and is asked to retry while it is empty.
+// fill reads from the connection without holding the SSL object.
+type connReader struct {
+	conn net.Conn
+	// fillMu serializes fill.
+	fillMu sync.Mutex
+	buf    []byte
+
+	// mu protects data and err.
+	mu   sync.Mutex
+	data []byte
+	// err is the error that ended the connection, returned once data is consumed.
+	err error
+}
+
+// connReadBufferSize fits a record of the maximum size.
+const connReadBufferSize = maxFragmentLen + 2048
+
+// Read implements io.Reader for libssl. It never blocks, and returns no data and no error to
+// ask libssl to retry.
+func (r *connReader) Read(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.data) > 0 {
+		n := copy(b, r.data)
+		r.data = r.data[n:]
+		return n, nil
+	}
+	err := r.err
+	if err != io.EOF {
+		r.err = nil
+	}
+	return 0, err
+}
+
+// Write implements io.Writer for libssl.
+func (r *connReader) Write(b []byte) (int, error) {
+	return r.conn.Write(b)
+}
+
+// fill reads from the connection until there is data or an error for libssl to read. Timeouts
+// are returned as the read can be retried after them.
+func (r *connReader) fill() error {
+	r.fillMu.Lock()
+	defer r.fillMu.Unlock()
+	r.mu.Lock()
+	ready := len(r.data) > 0 || r.err != nil
+	r.mu.Unlock()
+	if ready {
+		return nil
+	}
+	if r.buf == nil {
+		r.buf = make([]byte, connReadBufferSize)
+	}
+	for {
+		n, err := r.conn.Read(r.buf)
+		timeout := isTimeout(err)
+		if n == 0 && timeout {
+			return err
+		}
+		if n == 0 && err == nil {
+			continue
+		}
+		r.mu.Lock()
+		r.data = r.buf[:n]
+		if !timeout {
+			r.err = err
+		}
+		r.mu.Unlock()
+		return nil
+	}
+}
@@ -0,0 +1,191 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// newDuplexConns returns a client and a server Conn that completed their handshake. The client
+// uses a socket BIO if bio is "socket", and both use a BIO over a net.Conn otherwise.
+func newDuplexConns(t *testing.T, bio string) (*fipstls.Conn, *fipstls.Conn) {
+	t.Helper()
+	serverConfig := &fipstls.Config{CertFile: testutils.CertPath, KeyFile: testKeyPath}
+	clientConfig := &fipstls.Config{CaFile: testutils.CertPath, ServerName: "localhost"}
+	var client, server *fipstls.Conn
+	errCh := make(chan error, 1)
+	if bio == "socket" {
+		ln, err := net.Listen("tcp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		serverCh := make(chan *fipstls.Conn, 1)
+		go func() {
+			c, err := ln.Accept()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			s := fipstls.Server(c, serverConfig)
+			serverCh <- s
+			errCh <- s.Handshake(time.Time{})
+		}()
+		_, port, _ := net.SplitHostPort(ln.Addr().String())
+		d := fipstls.NewDialer(clientConfig, getFipsDialOpts()...)
+		defer d.Close()
+		c, err := d.DialContext(context.Background(), "tcp4", net.JoinHostPort("localhost", port))
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		client = c.(*fipstls.Conn)
+		server = <-serverCh
+	} else {
+		c1, c2 := newConnPair(t, "tcp")
+		client = fipstls.Client(c1, clientConfig)
+		server = fipstls.Server(c2, serverConfig)
+		go func() { errCh <- server.Handshake(time.Time{}) }()
+		if err := client.Handshake(time.Time{}); err != nil {
+			t.Fatalf("Handshake() failed: %v", err)
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server Handshake() failed: %v", err)
+	}
+	t.Cleanup(func() {
+		// Close waits for the close_notify of the peer, so both sides are closed concurrently.
+		done := make(chan struct{})
+		go func() {
+			server.Close()
+			close(done)
+		}()
+		client.Close()
+		<-done
+	})
+	return client, server
+}
+
+// TestConnFullDuplex writes to both sides of a connection at once without reading first, so that
+// the writes only complete if the reads proceed while writes wait for the socket. Both sides
+// keep asking for TLS 1.3 key updates, which the peer responds to from its reads while its
+// writes are in progress.
+func TestConnFullDuplex(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	const size = 4 << 20
+	for _, bio := range []string{"socket", "conn"} {
+		t.Run(bio, func(t *testing.T) {
+			client, server := newDuplexConns(t, bio)
+			deadline := time.Now().Add(time.Minute)
+			client.SetDeadline(deadline)
+			server.SetDeadline(deadline)
+
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			errCh := make(chan error, 6)
+			for i, conn := range []*fipstls.Conn{client, server} {
+				r := rand.New(rand.NewSource(int64(i)))
+				want := make([]byte, size)
+				r.Read(want)
+				peer := server
+				if conn == server {
+					peer = client
+				}
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					for b := want; len(b) > 0; {
+						n := min(len(b), 1+r.Intn(64<<10))
+						if _, err := conn.Write(b[:n]); err != nil {
+							errCh <- fmt.Errorf("Write() failed: %w", err)
+							return
+						}
+						b = b[n:]
+					}
+				}()
+				go func() {
+					defer wg.Done()
+					got := make([]byte, size)
+					if _, err := io.ReadFull(peer, got); err != nil {
+						errCh <- fmt.Errorf("Read() failed: %w", err)
+						return
+					}
+					if !bytes.Equal(got, want) {
+						errCh <- fmt.Errorf("received data differs from the data sent")
+					}
+				}()
+			}
+			var updaters sync.WaitGroup
+			for _, conn := range []*fipstls.Conn{client, server} {
+				updaters.Add(1)
+				go func() {
+					defer updaters.Done()
+					for {
+						select {
+						case <-done:
+							return
+						case <-time.After(time.Millisecond):
+						}
+						if err := conn.KeyUpdate(true); err != nil {
+							errCh <- fmt.Errorf("KeyUpdate() failed: %w", err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(done)
+			updaters.Wait()
+			close(errCh)
+			for err := range errCh {
+				t.Error(err)
+			}
+			if n := client.ConnectionState().KeyUpdates; n == 0 {
+				t.Errorf("Client sent no key updates")
+			}
+		})
+	}
+}
+
+// TestConnWriteDuringRead checks that a write is not held back by a read waiting for data.
+func TestConnWriteDuringRead(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	for _, bio := range []string{"socket", "conn"} {
+		t.Run(bio, func(t *testing.T) {
+			client, server := newDuplexConns(t, bio)
+			readCh := make(chan error, 1)
+			go func() {
+				b := make([]byte, 4)
+				_, err := io.ReadFull(client, b)
+				readCh <- err
+			}()
+			// Let the read block.
+			time.Sleep(50 * time.Millisecond)
+			client.SetWriteDeadline(time.Now().Add(5 * time.Second))
+			if _, err := client.Write([]byte("ping")); err != nil {
+				t.Fatalf("Write() during Read failed: %v", err)
+			}
+			b := make([]byte, 4)
+			server.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.ReadFull(server, b); err != nil || string(b) != "ping" {
+				t.Fatalf("Server Read() = %q, %v, want %q", b, err, "ping")
+			}
+			if _, err := server.Write([]byte("pong")); err != nil {
+				t.Fatalf("Server Write() failed: %v", err)
+			}
+			if err := <-readCh; err != nil {
+				t.Errorf("Read() failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+const testKeyPath = "internal/testutils/certs/key.pem"
+
+// newTLSListener returns a crypto/tls listener on localhost serving the test certificate.
+func newTLSListener(t *testing.T, version uint16) net.Listener {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(testutils.CertPath, testKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp4", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   version,
+		MaxVersion:   version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln
+}
+
+// serveTLS accepts a single connection on ln and writes the bytes returned by fn for the
+// connection state once the handshake completes.
+func serveTLS(ln net.Listener, fn func(tls.ConnectionState) ([]byte, error)) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			errCh <- err
+			return
+		}
+		b, err := fn(tlsConn.ConnectionState())
+		if err != nil {
+			errCh <- err
+			return
+		}
+		_, err = conn.Write(b)
+		errCh <- err
+	}()
+	return errCh
+}
+
+// dialListener dials a [fipstls.Conn] to ln as localhost with the dial options opts.
+func dialListener(t *testing.T, ln net.Listener, cfg *fipstls.Config,
+	opts ...fipstls.DialOption) *fipstls.Conn {
+	t.Helper()
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := fipstls.NewDialer(cfg, append(getFipsDialOpts(), opts...)...)
+	conn, err := d.DialContext(context.Background(), "tcp4", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	return conn.(*fipstls.Conn)
+}
+
+func TestExportKeyingMaterial(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	const label = "EXPORTER-fipstls-test"
+	testCases := []struct {
+		version uint16
+		context []byte
+	}{
+		{version: tls.VersionTLS12, context: nil},
+		{version: tls.VersionTLS12, context: []byte{}},
+		{version: tls.VersionTLS12, context: []byte("context")},
+		{version: tls.VersionTLS13, context: nil},
+		{version: tls.VersionTLS13, context: []byte("context")},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("version %x context %q nil %v", tc.version, tc.context, tc.context == nil),
+			func(t *testing.T) {
+				ln := newTLSListener(t, tc.version)
+				defer ln.Close()
+				errCh := serveTLS(ln, func(state tls.ConnectionState) ([]byte, error) {
+					return state.ExportKeyingMaterial(label, tc.context, 32)
+				})
+
+				conn := dialListener(t, ln, &fipstls.Config{CaFile: testutils.CertPath})
+				defer conn.Close()
+				got, err := conn.ExportKeyingMaterial(label, tc.context, 32)
+				if err != nil {
+					t.Fatalf("ExportKeyingMaterial() failed: %v", err)
+				}
+				want := make([]byte, 32)
+				if _, err := io.ReadFull(conn, want); err != nil {
+					t.Fatalf("Failed to read server keying material: %v", err)
+				}
+				if err := <-errCh; err != nil {
+					t.Fatalf("Server failed: %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("ExportKeyingMaterial() = %x, want %x", got, want)
+				}
+				if _, err := conn.ExportKeyingMaterial("master secret", nil, 32); err == nil {
+					t.Error("ExportKeyingMaterial() with reserved label succeeded")
+				}
+			})
+	}
+}
+
+// TestExportKeyingMaterialWithoutEMS checks that keying material is not exported from TLS 1.2
+// connections without Extended Master Secret. crypto/tls always negotiates it, so the server is
+// an OpenSSL server with it disabled, which requires OpenSSL 3.0 or later.
+func TestExportKeyingMaterialWithoutEMS(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	if strings.HasPrefix(libssl.VersionText(), "OpenSSL 1.") {
+		t.Skipf("Extended Master Secret cannot be disabled in %s", libssl.VersionText())
+	}
+
+	c1, c2 := newConnPair(t, "tcp")
+	defer c2.Close()
+	deadline := time.Now().Add(10 * time.Second)
+	c2.SetDeadline(deadline)
+	server := newLibsslPeer(t, c2, true, libssl.SSL_OP_NO_EXTENDED_MASTER_SECRET)
+	errCh := make(chan error, 1)
+	go func() { errCh <- libssl.SSLDoHandshake(server) }()
+
+	conn := fipstls.Client(c1, &fipstls.Config{
+		InsecureSkipVerify: true,
+		MaxTLSVersion:      fipstls.Version12,
+	})
+	defer conn.Close()
+	if err := conn.Handshake(deadline); err != nil {
+		t.Fatalf("Handshake() failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server handshake failed: %v", err)
+	}
+	if _, err := conn.ExportKeyingMaterial("EXPORTER-fipstls-test", nil, 32); !errors.Is(err,
+		fipstls.ErrEKMUnavailable) {
+		t.Errorf("ExportKeyingMaterial() err = %v, want %v", err, fipstls.ErrEKMUnavailable)
+	}
+	if _, err := conn.ChannelBinding(fipstls.ChannelBindingTLSExporter); !errors.Is(err,
+		fipstls.ErrChannelBindingUnsupported) {
+		t.Errorf("ChannelBinding(%s) err = %v, want %v", fipstls.ChannelBindingTLSExporter, err,
+			fipstls.ErrChannelBindingUnsupported)
+	}
+}
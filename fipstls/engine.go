@@ -0,0 +1,259 @@
+package fipstls
+
+import (
+	"io"
+	"net"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// Engine is a TLS connection that performs no I/O of its own. The caller carries the TLS
+// records between the Engine and the peer: records received from the peer are passed to
+// [Engine.FeedIncoming], and the records to send to the peer are taken from
+// [Engine.PendingOutgoing]. This allows TLS to be driven from an event loop, or carried in the
+// framing of another protocol such as EAP-TLS.
+//
+// Operations that cannot make progress until more records arrive from the peer return
+// [ErrWantIncoming]. Any operation may queue records for the peer, so PendingOutgoing should
+// be drained after each of them, including those that return an error, as the records may
+// carry an alert.
+//
+// An Engine starts no goroutines and is not safe for concurrent use. It does not support
+// [Config.GetClientCertificate], [Config.ClientSessionCache] or renegotiation.
+type Engine struct {
+	ctx *Context
+	ssl *libssl.SSL
+	// incoming and outgoing are the memory BIOs ssl reads records from and writes records to.
+	// They are owned by ssl.
+	incoming *libssl.BIO
+	outgoing *libssl.BIO
+
+	config    *Config
+	callbacks *libssl.Callbacks
+	l         Logger
+
+	handshakeComplete bool
+	// handshakeErr is the error of the failed handshake.
+	handshakeErr error
+	state        ConnectionState
+	closed       bool
+}
+
+// NewEngine creates an [Engine] for the client or the server side of a connection, depending on
+// [Config.Method]. A client verifies the certificate of the server against
+// [Config.ServerName], and its handshake fails with [ErrMissingServerName] if neither it nor
+// [Config.InsecureSkipVerify] is set. A nil config uses the default client configuration.
+func NewEngine(config *Config) (*Engine, error) {
+	if !libsslInit {
+		return nil, ErrNoLibSslInit
+	}
+	if config == nil {
+		config = newDefaultConfig()
+	}
+	tls := *config
+	tls.RenegotiationDisabled = true
+	ctx, err := NewCtx(&tls)
+	if err != nil {
+		ctx.Close()
+		return nil, err
+	}
+	e := &Engine{ctx: ctx, config: &tls, l: noopLogger{}}
+	if err := e.newSSL(); err != nil {
+		if e.ssl != nil {
+			libssl.SSLFree(e.ssl)
+		}
+		ctx.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// newSSL creates the SSL of the [Engine] and its memory BIOs.
+func (e *Engine) newSSL() error {
+	ssl, err := libssl.NewSSL(e.ctx.Ctx())
+	if err != nil {
+		return err
+	}
+	e.ssl = ssl
+	cb := &libssl.Callbacks{}
+	setPSKCallbacks(cb, e.config, e.l)
+	if err := libssl.SSLSetCallbacks(e.ssl, cb); err != nil {
+		return err
+	}
+	e.callbacks = cb
+	if len(e.config.PeerPublicKeys) > 0 {
+		if err := libssl.SSLAddExpectedRPKs(e.ssl, e.config.PeerPublicKeys); err != nil {
+			return err
+		}
+	}
+	server := e.config.Method == ServerMethod
+	if e.config.PostHandshakeAuth && !server {
+		if err := libssl.SSLSetPostHandshakeAuth(e.ssl); err != nil {
+			return err
+		}
+	}
+	incoming, err := libssl.NewMemBIO()
+	if err != nil {
+		return err
+	}
+	outgoing, err := libssl.NewMemBIO()
+	if err != nil {
+		libssl.BIOFree(incoming)
+		return err
+	}
+	if err := libssl.SSLConfigureMemBIOs(e.ssl, incoming, outgoing, e.config.ServerName,
+		server); err != nil {
+		return err
+	}
+	e.incoming, e.outgoing = incoming, outgoing
+	return nil
+}
+
+// FeedIncoming passes records received from the peer to the [Engine]. They are buffered until
+// they are processed by the next operation.
+func (e *Engine) FeedIncoming(b []byte) error {
+	if e.closed {
+		return net.ErrClosed
+	}
+	return libssl.BIOWrite(e.incoming, b)
+}
+
+// PendingOutgoing returns the records to send to the peer that were queued by the previous
+// operations, or nil if there are none.
+func (e *Engine) PendingOutgoing() []byte {
+	if e.closed {
+		return nil
+	}
+	return libssl.BIOReadPending(e.outgoing)
+}
+
+// Handshake advances the TLS handshake with the records fed so far. It returns nil once the
+// handshake has completed, and [ErrWantIncoming] until then. The error of a failed handshake
+// is returned by every later call. [Engine.ReadPlaintext] and [Engine.WritePlaintext] run the
+// handshake implicitly.
+func (e *Engine) Handshake() error {
+	if e.closed {
+		return net.ErrClosed
+	}
+	if e.handshakeComplete || e.handshakeErr != nil {
+		return e.handshakeErr
+	}
+	// Without a name, libssl neither sends SNI nor verifies the certificate name.
+	if e.config.Method != ServerMethod && e.config.ServerName == "" &&
+		!e.config.InsecureSkipVerify {
+		e.handshakeErr = ErrMissingServerName
+		return e.handshakeErr
+	}
+	libssl.SSLClearError()
+	if err := e.result(libssl.SSLDoHandshake(e.ssl)); err != nil {
+		if err != ErrWantIncoming {
+			e.handshakeErr = err
+		}
+		return err
+	}
+	e.handshakeComplete = true
+	e.state = newConnectionState(e.ssl, e.l)
+	return nil
+}
+
+// HandshakeComplete returns true once the handshake has completed.
+func (e *Engine) HandshakeComplete() bool {
+	return e.handshakeComplete
+}
+
+// ConnectionState returns basic TLS details about the connection.
+func (e *Engine) ConnectionState() ConnectionState {
+	return e.state
+}
+
+// ReadPlaintext reads application data decrypted from the records fed so far into b. It
+// returns [ErrWantIncoming] if no application data is available, and io.EOF once the peer has
+// sent a close_notify alert.
+func (e *Engine) ReadPlaintext(b []byte) (int, error) {
+	if err := e.Handshake(); err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	libssl.SSLClearError()
+	n, err := libssl.SSLReadEx(e.ssl, b)
+	return n, e.result(err)
+}
+
+// WritePlaintext encrypts b into records for the peer, which are returned by the next
+// [Engine.PendingOutgoing].
+func (e *Engine) WritePlaintext(b []byte) (int, error) {
+	if err := e.Handshake(); err != nil {
+		return 0, err
+	}
+	if len(b) == 0 {
+		return 0, nil
+	}
+	libssl.SSLClearError()
+	n, err := libssl.SSLWriteEx(e.ssl, b)
+	return n, e.result(err)
+}
+
+// ExportKeyingMaterial returns length bytes of exported key material as defined in RFC 5705 and
+// RFC 8446, Section 7.5, like [Conn.ExportKeyingMaterial].
+func (e *Engine) ExportKeyingMaterial(label string, context []byte, length int) ([]byte, error) {
+	if e.closed {
+		return nil, net.ErrClosed
+	}
+	if !e.handshakeComplete {
+		return nil, ErrHandshakeIncomplete
+	}
+	return exportKeyingMaterial(e.ssl, e.state.Version, label, context, length)
+}
+
+// Shutdown queues a close_notify alert for the peer, after which no more application data can
+// be written. It does not wait for the close_notify of the peer, which is reported as io.EOF by
+// [Engine.ReadPlaintext].
+func (e *Engine) Shutdown() error {
+	if e.closed {
+		return net.ErrClosed
+	}
+	if !e.handshakeComplete {
+		return ErrHandshakeIncomplete
+	}
+	libssl.SSLClearError()
+	if err := e.result(libssl.SSLShutdown(e.ssl)); err != nil && err != ErrWantIncoming {
+		return err
+	}
+	return nil
+}
+
+// Close frees the resources of the [Engine]. Records that were not taken with
+// [Engine.PendingOutgoing] are discarded.
+func (e *Engine) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	libssl.SSLFree(e.ssl)
+	return e.ctx.Close()
+}
+
+// result converts the error of an SSL operation of the [Engine].
+func (e *Engine) result(err error) error {
+	sslErr, ok := err.(*libssl.SSLError)
+	if !ok {
+		return err
+	}
+	switch sslErr.Code {
+	case libssl.SSL_ERROR_WANT_READ:
+		return ErrWantIncoming
+	case libssl.SSL_ERROR_ZERO_RETURN:
+		return io.EOF
+	case libssl.SSL_ERROR_WANT_X509_LOOKUP:
+		if err := e.callbacks.Err(); err != nil {
+			return err
+		}
+	case libssl.SSL_ERROR_SSL:
+		if verifyErr := libssl.SSLGetVerifyResult(e.ssl); verifyErr != nil {
+			return verifyErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,217 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// newEngine creates an Engine that is closed when the test ends.
+func newEngine(t *testing.T, config *fipstls.Config) *fipstls.Engine {
+	t.Helper()
+	e, err := fipstls.NewEngine(config)
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	t.Cleanup(func() { e.Close() })
+	return e
+}
+
+// newEngineServerConfig returns the configuration of a server Engine with the test certificate.
+func newEngineServerConfig(version uint16) *fipstls.Config {
+	return &fipstls.Config{
+		Method:        fipstls.ServerMethod,
+		CertFile:      testutils.CertPath,
+		KeyFile:       testKeyPath,
+		MinTLSVersion: version,
+		MaxTLSVersion: version,
+	}
+}
+
+// pump moves the records queued by from to to, and returns true if there were any.
+func pump(t *testing.T, from, to *fipstls.Engine) bool {
+	t.Helper()
+	records := from.PendingOutgoing()
+	if err := to.FeedIncoming(records); err != nil {
+		t.Fatalf("FeedIncoming() failed: %v", err)
+	}
+	return len(records) > 0
+}
+
+// handshakeEngines runs the handshake between client and server, and returns the first error
+// that is not ErrWantIncoming.
+func handshakeEngines(t *testing.T, client, server *fipstls.Engine) error {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		clientErr := client.Handshake()
+		if clientErr != nil && clientErr != fipstls.ErrWantIncoming {
+			return clientErr
+		}
+		pump(t, client, server)
+		serverErr := server.Handshake()
+		if serverErr != nil && serverErr != fipstls.ErrWantIncoming {
+			return serverErr
+		}
+		pump(t, server, client)
+		if clientErr == nil && serverErr == nil {
+			return nil
+		}
+	}
+	return errors.New("handshake did not complete")
+}
+
+// transfer writes b to the peer of from, and checks that it is read by to.
+func transfer(t *testing.T, from, to *fipstls.Engine, b []byte) {
+	t.Helper()
+	if n, err := from.WritePlaintext(b); err != nil || n != len(b) {
+		t.Fatalf("WritePlaintext() = %d, %v, want %d", n, err, len(b))
+	}
+	pump(t, from, to)
+	buf := make([]byte, len(b)+1)
+	n, err := to.ReadPlaintext(buf)
+	if err != nil {
+		t.Fatalf("ReadPlaintext() failed: %v", err)
+	}
+	if !bytes.Equal(buf[:n], b) {
+		t.Fatalf("ReadPlaintext() = %q, want %q", buf[:n], b)
+	}
+}
+
+func TestEngine(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	for _, version := range []uint16{fipstls.Version12, fipstls.Version13} {
+		t.Run(fmt.Sprintf("version %x", version), func(t *testing.T) {
+			client := newEngine(t, &fipstls.Config{
+				CaFile:     testutils.CertPath,
+				ServerName: "localhost",
+			})
+			server := newEngine(t, newEngineServerConfig(version))
+
+			if _, err := server.ReadPlaintext(make([]byte, 1)); err != fipstls.ErrWantIncoming {
+				t.Fatalf("ReadPlaintext() without records err = %v, want %v", err,
+					fipstls.ErrWantIncoming)
+			}
+			if err := client.Handshake(); err != fipstls.ErrWantIncoming {
+				t.Fatalf("Handshake() err = %v, want %v", err, fipstls.ErrWantIncoming)
+			}
+			if !pump(t, client, server) {
+				t.Fatal("Handshake() did not queue a ClientHello")
+			}
+			if err := handshakeEngines(t, client, server); err != nil {
+				t.Fatalf("Handshake failed: %v", err)
+			}
+			for _, e := range []*fipstls.Engine{client, server} {
+				if !e.HandshakeComplete() {
+					t.Error("HandshakeComplete() = false")
+				}
+				if got := e.ConnectionState().Version; got != version {
+					t.Errorf("Version = %x, want %x", got, version)
+				}
+			}
+
+			transfer(t, client, server, []byte("ping"))
+			transfer(t, server, client, []byte("pong"))
+			transfer(t, client, server, bytes.Repeat([]byte{'x'}, 16*1024))
+
+			clientEKM, err := client.ExportKeyingMaterial("EXPERIMENTAL test", nil, 32)
+			if err != nil {
+				t.Fatalf("ExportKeyingMaterial() failed: %v", err)
+			}
+			serverEKM, err := server.ExportKeyingMaterial("EXPERIMENTAL test", nil, 32)
+			if err != nil {
+				t.Fatalf("ExportKeyingMaterial() failed: %v", err)
+			}
+			if !bytes.Equal(clientEKM, serverEKM) {
+				t.Errorf("Client keying material = %x, server = %x", clientEKM, serverEKM)
+			}
+
+			if err := client.Shutdown(); err != nil {
+				t.Fatalf("Shutdown() failed: %v", err)
+			}
+			pump(t, client, server)
+			if _, err := server.ReadPlaintext(make([]byte, 1)); err != io.EOF {
+				t.Errorf("ReadPlaintext() after close_notify err = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestEnginePSK(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	client := newEngine(t, &fipstls.Config{
+		ServerName: "localhost",
+		PSK:        &fipstls.PSK{Identity: "client", Key: key},
+	})
+	server := newEngine(t, &fipstls.Config{
+		Method: fipstls.ServerMethod,
+		GetPSK: func(identity string) (*fipstls.PSK, error) {
+			return &fipstls.PSK{Identity: identity, Key: key}, nil
+		},
+	})
+	if err := handshakeEngines(t, client, server); err != nil {
+		t.Fatalf("Handshake failed: %v", err)
+	}
+	transfer(t, client, server, []byte("ping"))
+}
+
+func TestEngineHandshakeFailure(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	client := newEngine(t, &fipstls.Config{
+		CaFile:     testutils.CertPath,
+		ServerName: "example.com",
+	})
+	server := newEngine(t, newEngineServerConfig(fipstls.Version13))
+	err := handshakeEngines(t, client, server)
+	if err == nil {
+		t.Fatal("Handshake succeeded with a mismatched hostname")
+	}
+	// The failure is sticky, and the alert of the client fails the server.
+	if err := client.Handshake(); err == nil || err == fipstls.ErrWantIncoming {
+		t.Errorf("Handshake() after failure err = %v", err)
+	}
+	if _, err := client.WritePlaintext([]byte("ping")); err == nil {
+		t.Error("WritePlaintext() succeeded after a failed handshake")
+	}
+	pump(t, client, server)
+	if err := server.Handshake(); err == nil || err == fipstls.ErrWantIncoming {
+		t.Errorf("Server Handshake() after alert err = %v", err)
+	}
+}
+
+func TestEngineClosed(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	e, err := fipstls.NewEngine(nil)
+	if err != nil {
+		t.Fatalf("NewEngine() failed: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Errorf("Second Close() err = %v", err)
+	}
+	if err := e.FeedIncoming([]byte{0}); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("FeedIncoming() err = %v, want %v", err, net.ErrClosed)
+	}
+	if err := e.Handshake(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Handshake() err = %v, want %v", err, net.ErrClosed)
+	}
+	if b := e.PendingOutgoing(); b != nil {
+		t.Errorf("PendingOutgoing() = %x, want nil", b)
+	}
+}
@@ -2,6 +2,7 @@ package fipstls
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"os"
@@ -13,6 +14,59 @@ import (
 var (
 	ErrNoLibSslInit     = errors.New("fipstls: libssl was not initialized with fipstls.Init")
 	ErrLoadLibSslFailed = errors.New("fipstls: libssl failed to load")
+
+	// ErrEarlyDataUnavailable is returned when early data cannot be sent because there is no
+	// cached session for the peer that permits it, or the data exceeds the session's limit.
+	ErrEarlyDataUnavailable = errors.New("fipstls: early data unavailable for this session")
+	// ErrHandshakeComplete is returned by operations that are only valid before the handshake.
+	ErrHandshakeComplete = errors.New("fipstls: handshake already completed")
+	// ErrInvalidPSK is returned when a [PSK] has an empty identity, an invalid key length or an
+	// unsupported hash.
+	ErrInvalidPSK = errors.New("fipstls: invalid pre-shared key")
+	// ErrInvalidRecordSize is returned when a record layer limit in [Config] is out of range.
+	ErrInvalidRecordSize = errors.New("fipstls: invalid record size")
+	// ErrRawPublicKeyUnsupported is returned when raw public keys are configured but the
+	// loaded libssl is older than 3.2.
+	ErrRawPublicKeyUnsupported = errors.New("fipstls: raw public keys require OpenSSL 3.2 or later")
+	// ErrHandshakeIncomplete is returned when an operation requires a completed handshake.
+	ErrHandshakeIncomplete = errors.New("fipstls: handshake not complete")
+	// ErrEKMUnavailable is returned by [Conn.ExportKeyingMaterial] when neither TLS 1.3 nor the
+	// Extended Master Secret extension were negotiated.
+	ErrEKMUnavailable = errors.New("fipstls: ExportKeyingMaterial is unavailable when neither " +
+		"TLS 1.3 nor Extended Master Secret are negotiated")
+	// ErrChannelBindingUnsupported is returned by [Conn.ChannelBinding] when the channel binding
+	// type is not defined for the connection.
+	ErrChannelBindingUnsupported = errors.New("fipstls: channel binding unsupported")
+	// ErrKeyUpdateUnavailable is returned when a key update is attempted before the handshake
+	// completes or on a connection that did not negotiate TLS 1.3.
+	ErrKeyUpdateUnavailable = errors.New("fipstls: key update requires a completed TLS 1.3 handshake")
+	// ErrPostHandshakeAuthUnavailable is returned by [Conn.RequestClientCertificate] on a client
+	// connection, or before a TLS 1.3 handshake completes.
+	ErrPostHandshakeAuthUnavailable = errors.New("fipstls: post-handshake authentication " +
+		"requires a completed TLS 1.3 handshake on the server")
+	// ErrContextClosed is returned when creating a connection from a closed [Context] or with a
+	// closed [Dialer].
+	ErrContextClosed = errors.New("fipstls: context closed")
+	// ErrWantIncoming is returned by [Engine] operations that need more records from the peer
+	// to make progress.
+	ErrWantIncoming = errors.New("fipstls: engine needs incoming records")
+	// ErrTruncated is returned when the peer closes the transport without sending a close_notify
+	// alert, so the data read may have been truncated by an attacker. It wraps
+	// [io.ErrUnexpectedEOF], while a close_notify ends the data with [io.EOF].
+	ErrTruncated = fmt.Errorf("fipstls: connection closed without close_notify: %w",
+		io.ErrUnexpectedEOF)
+	// ErrCloseWriteBeforeHandshake is returned by [Conn.CloseWrite] before the handshake
+	// completes.
+	ErrCloseWriteBeforeHandshake = errors.New("fipstls: CloseWrite called before handshake " +
+		"complete")
+	// ErrMissingServerName is returned when creating a client connection without
+	// [Config.ServerName] or [Config.InsecureSkipVerify] if the peer has no hostname to verify
+	// its certificate against, such as the peer of a [net.Pipe].
+	ErrMissingServerName = errors.New("fipstls: either ServerName or InsecureSkipVerify must " +
+		"be specified in the Config")
+	// ErrNoSocket is returned by [NewSocketBIO] for connections that are not backed by a
+	// socket, such as the ones of [net.Pipe].
+	ErrNoSocket = errors.New("fipstls: connection is not backed by a socket")
 )
 
 // newConnError converts SSL errors to appropriate net.OpError with syscall errors
@@ -60,3 +114,19 @@ func newConnError(op string, addr net.Addr, err error) error {
 	}
 	return opErr
 }
+
+// newTimeoutError returns the error of an operation that timed out, a [net.Error] whose Timeout
+// method returns true, like the errors of the net package.
+func newTimeoutError(op string, laddr, raddr net.Addr) error {
+	opErr := &net.OpError{Op: op, Source: laddr, Addr: raddr, Err: os.ErrDeadlineExceeded}
+	if raddr != nil {
+		opErr.Net = raddr.Network()
+	}
+	return opErr
+}
+
+// isTimeout returns true if err is a [net.Error] reporting a timeout.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
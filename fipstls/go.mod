@@ -4,12 +4,12 @@ go 1.22
 
 require (
 	github.com/aristanetworks/glog v0.0.0-20250312002449-1e63d96ef38d
+	golang.org/x/net v0.30.0
 	google.golang.org/grpc v1.69.2
 	google.golang.org/protobuf v1.35.1
 )
 
 require (
-	golang.org/x/net v0.30.0 // indirect
 	golang.org/x/sys v0.26.0 // indirect
 	golang.org/x/text v0.19.0 // indirect
 	golang.org/x/time v0.0.0-20211116232009-f0f3c7e86c11 // indirect
@@ -0,0 +1,334 @@
+package libssl
+
+// #include "golibssl.h"
+import "C"
+import (
+	"errors"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// PSK is an external pre-shared key.
+type PSK struct {
+	Identity []byte
+	Key      []byte
+	// SHA384 selects SHA-384 as the hash associated with the key in TLS 1.3, otherwise
+	// SHA-256 is used.
+	SHA384 bool
+}
+
+// Callbacks are Go functions that libssl calls during the handshake of a connection. They are
+// attached to an [SSL] with [SSLSetCallbacks] and released by [SSLFree].
+type Callbacks struct {
+	// PSKClient returns the pre-shared key offered by a client, or nil to offer none.
+	PSKClient func() *PSK
+
+	// PSKServer returns the pre-shared key for the identity presented by a client, or nil if
+	// the identity is unknown. Returning an error aborts the handshake.
+	PSKServer func(identity []byte) (*PSK, error)
+
+	// ClientCert returns the certificate a client authenticates with when the server requests
+	// one, or nil to send none. Returning an error aborts the handshake.
+	ClientCert func(req *CertificateRequest) (*Certificate, error)
+
+	// HandshakeStart is called when a handshake starts, including renegotiations and TLS 1.3
+	// post-handshake message exchanges.
+	HandshakeStart func()
+
+	// err is the error that aborted the handshake in a callback.
+	err error
+
+	// pskIdentity is a C copy of the identity handed to libssl by the client callback.
+	pskIdentity unsafe.Pointer
+}
+
+// Err returns the error that aborted the handshake in a callback, if any.
+func (cb *Callbacks) Err() error {
+	if cb == nil {
+		return nil
+	}
+	return cb.err
+}
+
+// CertificateRequest describes a certificate request received from the server.
+type CertificateRequest struct {
+	// CANames are the DER-encoded distinguished names of the acceptable certificate
+	// authorities, or empty if the server did not send any.
+	CANames [][]byte
+	// SigAlgs are the TLS signature scheme code points accepted by the server.
+	SigAlgs []uint16
+}
+
+// Certificate is a certificate chain and private key.
+type Certificate struct {
+	// Chain is the DER-encoded certificate chain, leaf first.
+	Chain [][]byte
+	// Key is the DER-encoded private key of the leaf certificate.
+	Key []byte
+}
+
+// SSLSetCallbacks attaches the Go callbacks to ssl.
+func SSLSetCallbacks(ssl *SSL, cb *Callbacks) error {
+	if ssl == nil || cb == nil {
+		return NewOpenSSLError("libssl: SSL_set_ex_data: SSL or callbacks is nil")
+	}
+	if C.go_openssl_get_callbacks(ssl.inner) != 0 {
+		return NewOpenSSLError("libssl: SSL_set_ex_data: callbacks already set")
+	}
+	h := cgo.NewHandle(cb)
+	if C.go_openssl_set_callbacks(ssl.inner, C.uintptr_t(h)) != 1 {
+		h.Delete()
+		return NewOpenSSLError("libssl: SSL_set_ex_data")
+	}
+	return nil
+}
+
+// freeCallbacks releases the callbacks attached to ssl, if any.
+func freeCallbacks(ssl *SSL) {
+	h := C.go_openssl_get_callbacks(ssl.inner)
+	if h == 0 {
+		return
+	}
+	C.go_openssl_set_callbacks(ssl.inner, 0)
+	cb := cgo.Handle(h).Value().(*Callbacks)
+	if cb.pskIdentity != nil {
+		C.free(cb.pskIdentity)
+		cb.pskIdentity = nil
+	}
+	cgo.Handle(h).Delete()
+}
+
+// copyPSKKey copies the key of psk into the C buffer key of maxKeyLen bytes.
+func copyPSKKey(psk *PSK, key *C.uchar, maxKeyLen C.size_t, keyLen *C.size_t, sha384 *C.int) C.int {
+	if len(psk.Key) == 0 || len(psk.Key) > int(maxKeyLen) {
+		return 0
+	}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(key)), int(maxKeyLen)), psk.Key)
+	*keyLen = C.size_t(len(psk.Key))
+	*sha384 = 0
+	if psk.SHA384 {
+		*sha384 = 1
+	}
+	return 1
+}
+
+//export goPSKClient
+func goPSKClient(h C.uintptr_t, identity **C.uchar, identityLen *C.size_t, key *C.uchar,
+	maxKeyLen C.size_t, keyLen *C.size_t, sha384 *C.int) C.int {
+	cb := cgo.Handle(h).Value().(*Callbacks)
+	if cb.PSKClient == nil {
+		return 0
+	}
+	psk := cb.PSKClient()
+	if psk == nil || len(psk.Identity) == 0 {
+		return 0
+	}
+	if copyPSKKey(psk, key, maxKeyLen, keyLen, sha384) != 1 {
+		return 0
+	}
+	// libssl copies the identity before the next callback, so the previous copy can be freed.
+	if cb.pskIdentity != nil {
+		C.free(cb.pskIdentity)
+	}
+	cb.pskIdentity = C.CBytes(psk.Identity)
+	*identity = (*C.uchar)(cb.pskIdentity)
+	*identityLen = C.size_t(len(psk.Identity))
+	return 1
+}
+
+// goPSKServer returns 1 if the key for identity was found, 0 if the identity is unknown and -1 if
+// the handshake should be aborted.
+//
+//export goPSKServer
+func goPSKServer(h C.uintptr_t, identity *C.uchar, identityLen C.size_t, key *C.uchar,
+	maxKeyLen C.size_t, keyLen *C.size_t, sha384 *C.int) C.int {
+	cb := cgo.Handle(h).Value().(*Callbacks)
+	if cb.PSKServer == nil {
+		return 0
+	}
+	psk, err := cb.PSKServer(C.GoBytes(unsafe.Pointer(identity), C.int(identityLen)))
+	if err != nil {
+		return -1
+	}
+	if psk == nil {
+		return 0
+	}
+	if copyPSKKey(psk, key, maxKeyLen, keyLen, sha384) != 1 {
+		return -1
+	}
+	return 1
+}
+
+// goClientCert returns 1 if a certificate was selected, 0 to send no certificate and -1 if the
+// handshake should be aborted. The selected certificate and chain are set on ssl, and their
+// references are handed to libssl in x509 and pkey.
+//
+//export goClientCert
+func goClientCert(h C.uintptr_t, ssl C.GO_SSL_PTR, x509 *C.GO_X509_PTR,
+	pkey *C.GO_EVP_PKEY_PTR) C.int {
+	cb := cgo.Handle(h).Value().(*Callbacks)
+	if cb.ClientCert == nil {
+		return 0
+	}
+	// Returning -1 suspends the handshake with SSL_ERROR_WANT_X509_LOOKUP, the caller then
+	// reports cb.err instead of retrying.
+	if cb.err != nil {
+		return -1
+	}
+	s := &SSL{inner: ssl}
+	cert, err := cb.ClientCert(&CertificateRequest{
+		CANames: sslPeerCANames(s),
+		SigAlgs: sslPeerSigAlgs(s),
+	})
+	if err == nil && cert != nil {
+		err = sslUseCertificate(s, cert, x509, pkey)
+	}
+	if err != nil {
+		cb.err = err
+		return -1
+	}
+	if cert == nil {
+		return 0
+	}
+	return 1
+}
+
+// sslPeerCANames returns the CA names the peer sent in its certificate request.
+func sslPeerCANames(ssl *SSL) [][]byte {
+	names := C.go_openssl_SSL_get_client_CA_list(ssl.inner)
+	if names == nil {
+		return nil
+	}
+	n := int(C.go_openssl_OPENSSL_sk_num(names))
+	der := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		name := C.GO_X509_NAME_PTR(C.go_openssl_OPENSSL_sk_value(names, C.int(i)))
+		l := C.go_openssl_i2d_X509_NAME(name, nil)
+		if l <= 0 {
+			continue
+		}
+		cBuf := C.malloc(C.size_t(l))
+		p := (*C.uchar)(cBuf)
+		if C.go_openssl_i2d_X509_NAME(name, &p) == l {
+			der = append(der, C.GoBytes(cBuf, l))
+		}
+		C.free(cBuf)
+	}
+	return der
+}
+
+// sslPeerSigAlgs returns the signature algorithms the peer accepts.
+func sslPeerSigAlgs(ssl *SSL) []uint16 {
+	n := int(C.go_openssl_SSL_get_sigalgs(ssl.inner, -1, nil, nil, nil, nil, nil))
+	algs := make([]uint16, 0, n)
+	for i := 0; i < n; i++ {
+		var rsig, rhash C.uchar
+		if C.go_openssl_SSL_get_sigalgs(ssl.inner, C.int(i), nil, nil, nil, &rsig, &rhash) == 0 {
+			break
+		}
+		algs = append(algs, uint16(rhash)<<8|uint16(rsig))
+	}
+	return algs
+}
+
+// sslUseCertificate parses cert and sets it on ssl. On success a reference to the leaf and the
+// key is returned in x509 and pkey.
+func sslUseCertificate(ssl *SSL, cert *Certificate, x509 *C.GO_X509_PTR,
+	pkey *C.GO_EVP_PKEY_PTR) error {
+	if len(cert.Chain) == 0 || len(cert.Key) == 0 {
+		return errors.New("libssl: client certificate or private key is empty")
+	}
+	leaf, err := d2iX509(cert.Chain[0])
+	if err != nil {
+		return err
+	}
+	cKey := C.CBytes(cert.Key)
+	p := (*C.uchar)(cKey)
+	key := C.go_openssl_d2i_AutoPrivateKey(nil, &p, C.long(len(cert.Key)))
+	C.free(cKey)
+	if key == nil {
+		C.go_openssl_X509_free(leaf)
+		return NewOpenSSLError("libssl: d2i_AutoPrivateKey")
+	}
+	if err := sslUseChain(ssl, leaf, key, cert.Chain[1:]); err != nil {
+		C.go_openssl_X509_free(leaf)
+		C.go_openssl_EVP_PKEY_free(key)
+		return err
+	}
+	*x509, *pkey = leaf, key
+	return nil
+}
+
+// sslUseChain sets leaf, key and the intermediate certificates in chain on ssl.
+func sslUseChain(ssl *SSL, leaf C.GO_X509_PTR, key C.GO_EVP_PKEY_PTR, chain [][]byte) error {
+	if C.go_openssl_SSL_use_certificate(ssl.inner, leaf) != 1 {
+		return NewOpenSSLError("libssl: SSL_use_certificate")
+	}
+	if C.go_openssl_SSL_use_PrivateKey(ssl.inner, key) != 1 {
+		return NewOpenSSLError("libssl: SSL_use_PrivateKey")
+	}
+	// Clear the chain of a previously used certificate of the same type.
+	if C.go_openssl_SSL_ctrl(ssl.inner, SSL_CTRL_CHAIN, 0, nil) != 1 {
+		return NewOpenSSLError("libssl: SSL_clear_chain_certs")
+	}
+	for _, der := range chain {
+		x, err := d2iX509(der)
+		if err != nil {
+			return err
+		}
+		// SSL_add0_chain_cert takes ownership of x on success.
+		if C.go_openssl_SSL_ctrl(ssl.inner, SSL_CTRL_CHAIN_CERT, 0, unsafe.Pointer(x)) != 1 {
+			C.go_openssl_X509_free(x)
+			return NewOpenSSLError("libssl: SSL_add0_chain_cert")
+		}
+	}
+	return nil
+}
+
+// d2iX509 parses a DER-encoded certificate. It must be freed with X509_free.
+func d2iX509(der []byte) (C.GO_X509_PTR, error) {
+	if len(der) == 0 {
+		return nil, NewOpenSSLError("libssl: d2i_X509: empty certificate")
+	}
+	cBytes := C.CBytes(der)
+	defer C.free(cBytes)
+	p := (*C.uchar)(cBytes)
+	x := C.go_openssl_d2i_X509(nil, &p, C.long(len(der)))
+	if x == nil {
+		return nil, NewOpenSSLError("libssl: d2i_X509")
+	}
+	return x, nil
+}
+
+//export goHandshakeStart
+func goHandshakeStart(h C.uintptr_t) {
+	cb := cgo.Handle(h).Value().(*Callbacks)
+	if cb.HandshakeStart != nil {
+		cb.HandshakeStart()
+	}
+}
+
+// goBIOWrite writes the buffer of a BIO created by NewGoBIO to its Go writer.
+//
+//export goBIOWrite
+func goBIOWrite(h C.uintptr_t, buf *C.char, n C.int, retry *C.int) C.int {
+	b := cgo.Handle(h).Value().(*goBIO)
+	w, err := b.rw.Write(unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n)))
+	return gobioResult(w, err, &b.writeErr, retry)
+}
+
+// goBIORead reads from the Go reader of a BIO created by NewGoBIO into its buffer.
+//
+//export goBIORead
+func goBIORead(h C.uintptr_t, buf *C.char, n C.int, retry *C.int) C.int {
+	b := cgo.Handle(h).Value().(*goBIO)
+	r, err := b.rw.Read(unsafe.Slice((*byte)(unsafe.Pointer(buf)), int(n)))
+	return gobioResult(r, err, &b.readErr, retry)
+}
+
+// goBIOFree releases the Go side of a BIO created by NewGoBIO when libssl frees it.
+//
+//export goBIOFree
+func goBIOFree(h C.uintptr_t) {
+	cgo.Handle(h).Delete()
+}
@@ -11,4 +11,57 @@ type CtxConfig struct {
 	CaPath     string
 	CertFile   string
 	KeyFile    string
+
+	// CipherList is the TLS 1.2 and below cipher list, or empty for the library default.
+	CipherList string
+	// CipherSuites is the TLS 1.3 cipher suite list, or empty for the library default.
+	CipherSuites string
+	// SessionIDContext is the session ID context of a server, which must be set for a server
+	// verifying client certificates to resume sessions.
+	SessionIDContext string
+	// PSKClient and PSKServer install the pre-shared key callbacks, which look up keys with
+	// the [Callbacks] set on each connection.
+	PSKClient bool
+	PSKServer bool
+	// ClientCert installs the client certificate callback, which selects the certificate with
+	// the [Callbacks] set on each connection.
+	ClientCert bool
+	// HandshakeStart installs the info callback, which reports the start of every handshake to
+	// the [Callbacks] set on each connection.
+	HandshakeStart bool
+
+	// ClientCertTypes and ServerCertTypes are the RFC 7250 certificate types in order of
+	// preference, or empty for X.509 only. They require OpenSSL 3.2 or later.
+	ClientCertTypes []byte
+	ServerCertTypes []byte
+	// CertComp are the RFC 8879 certificate compression algorithms in order of preference. They
+	// are ignored before OpenSSL 3.2.
+	CertComp []int
+	// MaxFragmentLength is the RFC 6066 max_fragment_length in bytes requested by a client, or
+	// zero to not request one. It must be 512, 1024, 2048 or 4096.
+	MaxFragmentLength int
+	// MaxSendFragment and SplitSendFragment are the maximum plaintext length of sent records
+	// and of the records sent by each pipeline, or zero for the library default.
+	MaxSendFragment   int
+	SplitSendFragment int
+	// BlockPadding pads TLS 1.3 records to a multiple of BlockPadding bytes, or zero to not
+	// pad records.
+	BlockPadding int
+	// KTLS lets libssl offload record encryption and decryption to the kernel on sockets that
+	// support it. It is ignored before OpenSSL 3.0.
+	KTLS bool
+	// Mode is a combination of the SSL_MODE_* flags added to the modes of the context, such as
+	// SSL_MODE_RELEASE_BUFFERS.
+	Mode int64
+	// ReadAhead lets libssl read as much data as is available from the transport instead of a
+	// record at a time.
+	ReadAhead bool
+	// ReadBufferLen is the default length of the read buffer, or zero for the library default.
+	// It requires OpenSSL 1.1.0 or later.
+	ReadBufferLen int
+	// MaxEarlyData is the amount of TLS 1.3 early data a server accepts on resumed sessions, or
+	// zero to reject early data. It requires OpenSSL 1.1.1 or later.
+	MaxEarlyData uint32
+	// ExpectedRPKs enables matching peers against the keys added with [SSLAddExpectedRPKs].
+	ExpectedRPKs bool
 }
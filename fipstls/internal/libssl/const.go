@@ -70,6 +70,9 @@ const (
 	SSL_OP_NO_COMPRESSION                         = C.GO_SSL_OP_NO_COMPRESSION
 	SSL_OP_CIPHER_SERVER_PREFERENCE               = C.GO_SSL_OP_CIPHER_SERVER_PREFERENCE
 	SSL_OP_TLS_ROLLBACK_BUG                       = C.GO_SSL_OP_TLS_ROLLBACK_BUG
+	SSL_OP_NO_RENEGOTIATION                       = C.GO_SSL_OP_NO_RENEGOTIATION
+	SSL_OP_ENABLE_KTLS                            = C.GO_SSL_OP_ENABLE_KTLS
+	SSL_OP_NO_EXTENDED_MASTER_SECRET              = C.GO_SSL_OP_NO_EXTENDED_MASTER_SECRET
 )
 
 // SSL verify modes
@@ -170,10 +173,17 @@ const (
 
 // SSL and SSL_CTX ctrl constants
 const (
-	SSL_CTRL_OPTIONS               = C.GO_SSL_CTRL_OPTIONS
-	SSL_CTRL_SET_TLSEXT_HOSTNAME   = C.GO_SSL_CTRL_SET_TLSEXT_HOSTNAME
-	SSL_CTRL_SET_MIN_PROTO_VERSION = C.GO_SSL_CTRL_SET_MIN_PROTO_VERSION
-	SSL_CTRL_SET_MAX_PROTO_VERSION = C.GO_SSL_CTRL_SET_MAX_PROTO_VERSION
+	SSL_CTRL_OPTIONS                 = C.GO_SSL_CTRL_OPTIONS
+	SSL_CTRL_MODE                    = C.GO_SSL_CTRL_MODE
+	SSL_CTRL_SET_READ_AHEAD          = C.GO_SSL_CTRL_SET_READ_AHEAD
+	SSL_CTRL_SET_TLSEXT_HOSTNAME     = C.GO_SSL_CTRL_SET_TLSEXT_HOSTNAME
+	SSL_CTRL_CHAIN                   = C.GO_SSL_CTRL_CHAIN
+	SSL_CTRL_CHAIN_CERT              = C.GO_SSL_CTRL_CHAIN_CERT
+	SSL_CTRL_GET_EXTMS_SUPPORT       = C.GO_SSL_CTRL_GET_EXTMS_SUPPORT
+	SSL_CTRL_SET_MIN_PROTO_VERSION   = C.GO_SSL_CTRL_SET_MIN_PROTO_VERSION
+	SSL_CTRL_SET_MAX_PROTO_VERSION   = C.GO_SSL_CTRL_SET_MAX_PROTO_VERSION
+	SSL_CTRL_SET_MAX_SEND_FRAGMENT   = C.GO_SSL_CTRL_SET_MAX_SEND_FRAGMENT
+	SSL_CTRL_SET_SPLIT_SEND_FRAGMENT = C.GO_SSL_CTRL_SET_SPLIT_SEND_FRAGMENT
 )
 
 const (
@@ -192,3 +202,38 @@ const (
 	BIO_LOOKUP_CLIENT = C.GO_BIO_LOOKUP_CLIENT
 	BIO_LOOKUP_SERVER = C.GO_BIO_LOOKUP_SERVER
 )
+
+// TLS 1.3 early data status
+const (
+	SSL_EARLY_DATA_NOT_SENT = C.GO_SSL_EARLY_DATA_NOT_SENT
+	SSL_EARLY_DATA_REJECTED = C.GO_SSL_EARLY_DATA_REJECTED
+	SSL_EARLY_DATA_ACCEPTED = C.GO_SSL_EARLY_DATA_ACCEPTED
+)
+
+// TLS 1.3 early data read results
+const (
+	SSL_READ_EARLY_DATA_ERROR   = C.GO_SSL_READ_EARLY_DATA_ERROR
+	SSL_READ_EARLY_DATA_SUCCESS = C.GO_SSL_READ_EARLY_DATA_SUCCESS
+	SSL_READ_EARLY_DATA_FINISH  = C.GO_SSL_READ_EARLY_DATA_FINISH
+)
+
+// TLS 1.3 key update types
+const (
+	SSL_KEY_UPDATE_NONE          = C.GO_SSL_KEY_UPDATE_NONE
+	SSL_KEY_UPDATE_NOT_REQUESTED = C.GO_SSL_KEY_UPDATE_NOT_REQUESTED
+	SSL_KEY_UPDATE_REQUESTED     = C.GO_SSL_KEY_UPDATE_REQUESTED
+)
+
+// RFC 7250 certificate types
+const (
+	TLSEXT_cert_type_x509 = C.GO_TLSEXT_cert_type_x509
+	TLSEXT_cert_type_rpk  = C.GO_TLSEXT_cert_type_rpk
+)
+
+// RFC 8879 certificate compression algorithms
+const (
+	TLSEXT_comp_cert_none   = C.GO_TLSEXT_comp_cert_none
+	TLSEXT_comp_cert_zlib   = C.GO_TLSEXT_comp_cert_zlib
+	TLSEXT_comp_cert_brotli = C.GO_TLSEXT_comp_cert_brotli
+	TLSEXT_comp_cert_zstd   = C.GO_TLSEXT_comp_cert_zstd
+)
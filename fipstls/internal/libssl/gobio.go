@@ -0,0 +1,101 @@
+package libssl
+
+// #include "golibssl.h"
+import "C"
+import (
+	"errors"
+	"io"
+	"net"
+	"runtime/cgo"
+	"sync"
+)
+
+var (
+	gobioMethodOnce sync.Once
+	gobioMethod     C.GO_BIO_METHOD_PTR
+)
+
+// goBIO is the Go side of a BIO created by [NewGoBIO].
+type goBIO struct {
+	rw io.ReadWriter
+	// readErr and writeErr are the errors that failed the last read and write. They are kept
+	// separately as reads and writes may run concurrently.
+	readErr  error
+	writeErr error
+}
+
+// NewGoBIO creates a [BIO] that reads from and writes to rw, which is typically a [net.Conn].
+// Reads and writes block libssl until rw returns. If rw times out, libssl reports
+// SSL_ERROR_WANT_READ or SSL_ERROR_WANT_WRITE and the operation can be retried. The error
+// returned by rw is available from [BIOTakeReadError] and [BIOTakeWriteError].
+func NewGoBIO(rw io.ReadWriter) (*BIO, error) {
+	if !versionAtOrAbove(1, 1, 0) {
+		return nil, errUnsupportedVersion()
+	}
+	gobioMethodOnce.Do(func() {
+		gobioMethod = C.go_openssl_gobio_method_new(C.int(int(debugLogging)))
+	})
+	if gobioMethod == nil {
+		return nil, NewOpenSSLError("libssl: BIO_meth_new")
+	}
+	b := &goBIO{rw: rw}
+	h := cgo.NewHandle(b)
+	bio := C.go_openssl_gobio_new(gobioMethod, C.uintptr_t(h))
+	if bio == nil {
+		h.Delete()
+		return nil, NewOpenSSLError("libssl: BIO_new")
+	}
+	return &BIO{inner: bio, gobio: b}, nil
+}
+
+// BIOTakeReadError returns and clears the error of the last failed read of a [BIO] created by
+// [NewGoBIO].
+func BIOTakeReadError(bio *BIO) error {
+	if bio == nil || bio.gobio == nil {
+		return nil
+	}
+	err := bio.gobio.readErr
+	bio.gobio.readErr = nil
+	return err
+}
+
+// BIOTakeWriteError returns and clears the error of the last failed write of a [BIO] created by
+// [NewGoBIO].
+func BIOTakeWriteError(bio *BIO) error {
+	if bio == nil || bio.gobio == nil {
+		return nil
+	}
+	err := bio.gobio.writeErr
+	bio.gobio.writeErr = nil
+	return err
+}
+
+// SSLConfigureServerBIO sets bio on ssl and prepares ssl to accept a handshake as a server.
+func SSLConfigureServerBIO(ssl *SSL, bio *BIO) error {
+	if ssl == nil || bio == nil {
+		return NewOpenSSLError("libssl: SSL_set_bio: SSL or BIO is nil")
+	}
+	C.go_openssl_ssl_configure_server_bio(ssl.inner, bio.inner)
+	return nil
+}
+
+// isTimeout returns true if err is a timeout after which the operation can be retried.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// gobioResult converts the result of a Go read or write to the BIO return value.
+func gobioResult(n int, err error, lastErr *error, retry *C.int) C.int {
+	if n > 0 {
+		return C.int(n)
+	}
+	switch {
+	case err == io.EOF:
+		return 0
+	case err == nil || isTimeout(err):
+		*retry = 1
+	}
+	*lastErr = err
+	return -1
+}
@@ -17,6 +17,7 @@
 #define DEFINEFUNC_1_1(ret, func, args, argscall) DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_1_1_1(ret, func, args, argscall) DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_3_0(ret, func, args, argscall) DEFINEFUNC(ret, func, args, argscall)
+#define DEFINEFUNC_3_2(ret, func, args, argscall) DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_RENAMED_1_1(ret, func, oldfunc, args, argscall) DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_RENAMED_3_0(ret, func, oldfunc, args, argscall) DEFINEFUNC(ret, func, args, argscall)
 
@@ -29,6 +30,7 @@ FOR_ALL_LIBSSL_FUNCTIONS
 #undef DEFINEFUNC_1_1
 #undef DEFINEFUNC_1_1_1
 #undef DEFINEFUNC_3_0
+#undef DEFINEFUNC_3_2
 #undef DEFINEFUNC_RENAMED_1_1
 #undef DEFINEFUNC_RENAMED_3_0
 
@@ -96,6 +98,11 @@ void go_openssl_load_functions(void *handle, unsigned int major, unsigned int mi
     {                                             \
         DEFINEFUNC_INTERNAL(func, #func)          \
     }
+#define DEFINEFUNC_3_2(ret, func, args, argscall) \
+    if (major == 3 && minor >= 2)                 \
+    {                                             \
+        DEFINEFUNC_INTERNAL(func, #func)          \
+    }
 #define DEFINEFUNC_RENAMED_1_1(ret, func, oldfunc, args, argscall) \
     if (major == 1 && minor == 0)                                  \
     {                                                              \
@@ -124,6 +131,7 @@ void go_openssl_load_functions(void *handle, unsigned int major, unsigned int mi
 #undef DEFINEFUNC_1_1
 #undef DEFINEFUNC_1_1_1
 #undef DEFINEFUNC_3_0
+#undef DEFINEFUNC_3_2
 #undef DEFINEFUNC_RENAMED_1_1
 #undef DEFINEFUNC_RENAMED_3_0
 }
@@ -320,6 +328,13 @@ int go_openssl_ssl_configure(GO_SSL_PTR ssl, const char *hostname, int trace)
     GO_OPENSSL_DEBUGLOG(trace, "[INFO] SSL_set_connect_state with 'host=%s'...\n", hostname);
     int r;
     go_openssl_SSL_set_connect_state(ssl);
+    if (hostname == NULL || hostname[0] == '\0')
+    {
+        // No name to send in the SNI extension or to verify the certificate against. Callers
+        // only allow this when the certificate is not verified.
+        GO_OPENSSL_DEBUGLOG(trace, "[INFO] No hostname, skipping SNI and hostname verification...\n");
+        return 0;
+    }
     // TODO: since we know the hostname during ssl creation, we should make this a configuration
     // option
     // SSL_set_tlsext_hostname sets the SNI hostname
@@ -443,6 +458,32 @@ go_openssl_create_bio(const char *hostname, const char *port, int family, int mo
     return bio;
 }
 
+/* Helper function to create a BIO for a socket connected by the caller. The BIO closes the
+ * socket when it is freed, unless creating it fails. */
+GO_BIO_PTR
+go_openssl_socket_bio_new(int sock, int mode, int trace)
+{
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_socket_bio_new with 'sock=%d'...\n", sock);
+    GO_BIO_PTR bio;
+
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] BIO_socket_nbio with 'mode=%d'...\n", mode);
+    if (!go_openssl_BIO_socket_nbio(sock, mode))
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[ERROR] BIO_socket_nbio failed!\n");
+        return NULL;
+    }
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] BIO_new...\n");
+    bio = go_openssl_BIO_new(go_openssl_BIO_s_socket());
+    if (bio == NULL)
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[ERROR] BIO_new failed!\n");
+        return NULL;
+    }
+    go_openssl_BIO_int_ctrl(bio, GO_BIO_C_SET_FD, GO_BIO_CLOSE, sock);
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_socket_bio_new succeeded!\n");
+    return bio;
+}
+
 static const unsigned char h2_proto[] = {2, 'h', '2', '\0'};
 
 int go_openssl_set_h2_alpn(GO_SSL_CTX_PTR ctx, int trace)
@@ -528,4 +569,332 @@ int go_openssl_get_fips_provider_info(char *buf, size_t size)
     ret = go_openssl_get_provider_params(provider, &info);
     go_openssl_OSSL_PROVIDER_unload(provider);
     return ret;
+}
+// go_openssl_set_callbacks stores the cgo.Handle of the Go callbacks for ssl in its app data.
+int go_openssl_set_callbacks(GO_SSL_PTR ssl, uintptr_t handle)
+{
+    return go_openssl_SSL_set_ex_data(ssl, 0, (void *)handle);
+}
+
+// go_openssl_get_callbacks returns the cgo.Handle of the Go callbacks for ssl, or 0 if none is set.
+uintptr_t go_openssl_get_callbacks(GO_SSL_PTR ssl)
+{
+    return (uintptr_t)go_openssl_SSL_get_ex_data(ssl, 0);
+}
+
+static unsigned int go_openssl_psk_client_cb(GO_SSL_PTR ssl, const char *hint, char *identity,
+                                             unsigned int max_identity_len, unsigned char *psk,
+                                             unsigned int max_psk_len)
+{
+    UNUSED(hint);
+    uintptr_t handle = go_openssl_get_callbacks(ssl);
+    unsigned char *id;
+    size_t id_len, key_len;
+    int sha384;
+    if (handle == 0 || !goPSKClient(handle, &id, &id_len, psk, max_psk_len, &key_len, &sha384))
+        return 0;
+    // The TLS 1.2 identity is a NUL-terminated string
+    if (id_len + 1 > max_identity_len)
+    {
+        go_openssl_OPENSSL_cleanse(psk, key_len);
+        return 0;
+    }
+    memcpy(identity, id, id_len);
+    identity[id_len] = '\0';
+    return (unsigned int)key_len;
+}
+
+static unsigned int go_openssl_psk_server_cb(GO_SSL_PTR ssl, const char *identity,
+                                             unsigned char *psk, unsigned int max_psk_len)
+{
+    uintptr_t handle = go_openssl_get_callbacks(ssl);
+    size_t key_len;
+    int sha384;
+    if (handle == 0 || identity == NULL ||
+        goPSKServer(handle, (unsigned char *)identity, strlen(identity), psk, max_psk_len,
+                    &key_len, &sha384) != 1)
+        return 0;
+    return (unsigned int)key_len;
+}
+
+static const unsigned char tls13_aes128gcmsha256_id[] = {0x13, 0x01};
+static const unsigned char tls13_aes256gcmsha384_id[] = {0x13, 0x02};
+
+// go_openssl_new_psk_session creates a TLS 1.3 session for an external PSK. The cipher suite is
+// the AES-GCM suite matching the hash associated with the key.
+static GO_SSL_SESSION_PTR go_openssl_new_psk_session(GO_SSL_PTR ssl, const unsigned char *key,
+                                                     size_t key_len, int sha384)
+{
+    GO_SSL_CIPHER_PTR cipher = go_openssl_SSL_CIPHER_find(
+        ssl, sha384 ? tls13_aes256gcmsha384_id : tls13_aes128gcmsha256_id);
+    if (cipher == NULL)
+        return NULL;
+    GO_SSL_SESSION_PTR sess = go_openssl_SSL_SESSION_new();
+    if (sess == NULL)
+        return NULL;
+    if (!go_openssl_SSL_SESSION_set1_master_key(sess, key, key_len) ||
+        !go_openssl_SSL_SESSION_set_cipher(sess, cipher) ||
+        !go_openssl_SSL_SESSION_set_protocol_version(sess, GO_TLS1_3_VERSION))
+    {
+        go_openssl_SSL_SESSION_free(sess);
+        return NULL;
+    }
+    return sess;
+}
+
+static int go_openssl_psk_use_session_cb(GO_SSL_PTR ssl, const GO_EVP_MD_PTR md,
+                                         const unsigned char **id, size_t *idlen,
+                                         GO_SSL_SESSION_PTR *sess)
+{
+    *id = NULL;
+    *idlen = 0;
+    *sess = NULL;
+    uintptr_t handle = go_openssl_get_callbacks(ssl);
+    unsigned char key[GO_OPENSSL_PSK_MAX_LEN];
+    unsigned char *identity;
+    size_t identity_len, key_len;
+    int sha384;
+    if (handle == 0 ||
+        !goPSKClient(handle, &identity, &identity_len, key, sizeof(key), &key_len, &sha384))
+        // No PSK, continue the handshake without one
+        return 1;
+    GO_SSL_SESSION_PTR s = go_openssl_new_psk_session(ssl, key, key_len, sha384);
+    go_openssl_OPENSSL_cleanse(key, key_len);
+    if (s == NULL)
+        return 0;
+    // After a HelloRetryRequest the PSK is only usable if its hash matches the cipher suite
+    // chosen by the server.
+    if (md != NULL)
+    {
+        GO_EVP_MD_PTR smd = go_openssl_SSL_CIPHER_get_handshake_digest(go_openssl_SSL_CIPHER_find(
+            ssl, sha384 ? tls13_aes256gcmsha384_id : tls13_aes128gcmsha256_id));
+        if (smd == NULL || go_openssl_EVP_MD_get_type(smd) != go_openssl_EVP_MD_get_type(md))
+        {
+            go_openssl_SSL_SESSION_free(s);
+            return 1;
+        }
+    }
+    *id = identity;
+    *idlen = identity_len;
+    *sess = s;
+    return 1;
+}
+
+static int go_openssl_psk_find_session_cb(GO_SSL_PTR ssl, const unsigned char *identity,
+                                          size_t identity_len, GO_SSL_SESSION_PTR *sess)
+{
+    *sess = NULL;
+    uintptr_t handle = go_openssl_get_callbacks(ssl);
+    unsigned char key[GO_OPENSSL_PSK_MAX_LEN];
+    size_t key_len;
+    int sha384;
+    if (handle == 0)
+        return 1;
+    int r = goPSKServer(handle, (unsigned char *)identity, identity_len, key, sizeof(key),
+                        &key_len, &sha384);
+    if (r < 0)
+        return 0;
+    if (r == 0)
+        // Unknown identity, continue the handshake without a PSK
+        return 1;
+    *sess = go_openssl_new_psk_session(ssl, key, key_len, sha384);
+    go_openssl_OPENSSL_cleanse(key, key_len);
+    return *sess != NULL;
+}
+
+// go_openssl_ctx_configure_psk installs the PSK callbacks on ctx. The callbacks look up the key
+// from the Go callbacks set on each connection with go_openssl_set_callbacks.
+int go_openssl_ctx_configure_psk(GO_SSL_CTX_PTR ctx, int client, int server, int tls13, int trace)
+{
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_ctx_configure_psk with 'client=%d' 'server=%d'...\n",
+                        client, server);
+    if (client)
+    {
+        go_openssl_SSL_CTX_set_psk_client_callback(ctx, go_openssl_psk_client_cb);
+        if (tls13)
+            go_openssl_SSL_CTX_set_psk_use_session_callback(ctx, go_openssl_psk_use_session_cb);
+    }
+    if (server)
+    {
+        go_openssl_SSL_CTX_set_psk_server_callback(ctx, go_openssl_psk_server_cb);
+        if (tls13)
+            go_openssl_SSL_CTX_set_psk_find_session_callback(ctx, go_openssl_psk_find_session_cb);
+    }
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_ctx_configure_psk succeeded!\n");
+    return 0;
+}
+
+static int go_openssl_client_cert_cb(GO_SSL_PTR ssl, GO_X509_PTR *x509, GO_EVP_PKEY_PTR *pkey)
+{
+    uintptr_t handle = go_openssl_get_callbacks(ssl);
+    if (handle == 0)
+        return 0;
+    return goClientCert(handle, ssl, x509, pkey);
+}
+
+// go_openssl_ctx_configure_client_cert installs the client certificate callback on ctx. The
+// callback selects the certificate with the Go callbacks set on each connection.
+int go_openssl_ctx_configure_client_cert(GO_SSL_CTX_PTR ctx, int trace)
+{
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_ctx_configure_client_cert...\n");
+    go_openssl_SSL_CTX_set_client_cert_cb(ctx, go_openssl_client_cert_cb);
+    return 0;
+}
+
+static void go_openssl_info_cb(const GO_SSL_PTR ssl, int where, int ret)
+{
+    UNUSED(ret);
+    if (!(where & GO_SSL_CB_HANDSHAKE_START))
+        return;
+    uintptr_t handle = go_openssl_get_callbacks((GO_SSL_PTR)ssl);
+    if (handle != 0)
+        goHandshakeStart(handle);
+}
+
+// go_openssl_ctx_configure_info_cb installs the info callback on ctx, which reports the start of
+// every handshake to the Go callbacks set on each connection.
+int go_openssl_ctx_configure_info_cb(GO_SSL_CTX_PTR ctx, int trace)
+{
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_ctx_configure_info_cb...\n");
+    go_openssl_SSL_CTX_set_info_callback(ctx, go_openssl_info_cb);
+    return 0;
+}
+
+// go_openssl_ctx_configure_cert_comp sets the certificate compression algorithms in order of
+// preference. It is a no-op if the library doesn't support certificate compression.
+int go_openssl_ctx_configure_cert_comp(GO_SSL_CTX_PTR ctx, int *algs, size_t len, int supported, int trace)
+{
+    if (!supported)
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[INFO] Certificate compression requires OpenSSL 3.2 or later, skipping...\n");
+        return 0;
+    }
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] SSL_CTX_set1_cert_comp_preference with %zu algorithms...\n", len);
+    if (go_openssl_SSL_CTX_set1_cert_comp_preference(ctx, algs, len) != 1)
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[ERROR] SSL_CTX_set1_cert_comp_preference failed!\n");
+        return 1;
+    }
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] SSL_CTX_set1_cert_comp_preference succeeded!\n");
+    return 0;
+}
+
+
+// go_openssl_gobio_write writes to the Go connection of the BIO, asking libssl to retry if the
+// write timed out.
+static int go_openssl_gobio_write(GO_BIO_PTR b, const char *buf, int len)
+{
+    int retry = 0;
+    go_openssl_BIO_clear_flags(b, GO_BIO_FLAGS_RWS | GO_BIO_FLAGS_SHOULD_RETRY);
+    int n = goBIOWrite((uintptr_t)go_openssl_BIO_get_data(b), (char *)buf, len, &retry);
+    if (retry)
+        go_openssl_BIO_set_flags(b, GO_BIO_FLAGS_WRITE | GO_BIO_FLAGS_SHOULD_RETRY);
+    return n;
+}
+
+// go_openssl_gobio_read reads from the Go connection of the BIO, asking libssl to retry if the
+// read timed out.
+static int go_openssl_gobio_read(GO_BIO_PTR b, char *buf, int len)
+{
+    int retry = 0;
+    go_openssl_BIO_clear_flags(b, GO_BIO_FLAGS_RWS | GO_BIO_FLAGS_SHOULD_RETRY);
+    int n = goBIORead((uintptr_t)go_openssl_BIO_get_data(b), buf, len, &retry);
+    if (retry)
+        go_openssl_BIO_set_flags(b, GO_BIO_FLAGS_READ | GO_BIO_FLAGS_SHOULD_RETRY);
+    return n;
+}
+
+static long go_openssl_gobio_ctrl(GO_BIO_PTR b, int cmd, long num, void *ptr)
+{
+    UNUSED(b);
+    UNUSED(num);
+    UNUSED(ptr);
+    // Writes are not buffered, so there is never anything to flush
+    return cmd == GO_BIO_CTRL_FLUSH ? 1 : 0;
+}
+
+static int go_openssl_gobio_destroy(GO_BIO_PTR b)
+{
+    uintptr_t handle = (uintptr_t)go_openssl_BIO_get_data(b);
+    if (handle != 0)
+        goBIOFree(handle);
+    go_openssl_BIO_set_data(b, NULL);
+    go_openssl_BIO_set_init(b, 0);
+    return 1;
+}
+
+// go_openssl_gobio_method_new creates the BIO_METHOD of BIOs backed by a Go connection.
+GO_BIO_METHOD_PTR go_openssl_gobio_method_new(int trace)
+{
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_gobio_method_new...\n");
+    int index = go_openssl_BIO_get_new_index();
+    if (index == -1)
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[ERROR] BIO_get_new_index failed!\n");
+        return NULL;
+    }
+    GO_BIO_METHOD_PTR method = go_openssl_BIO_meth_new(index | GO_BIO_TYPE_SOURCE_SINK, "Go connection");
+    if (method == NULL)
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[ERROR] BIO_meth_new failed!\n");
+        return NULL;
+    }
+    if (go_openssl_BIO_meth_set_write(method, go_openssl_gobio_write) != 1 ||
+        go_openssl_BIO_meth_set_read(method, go_openssl_gobio_read) != 1 ||
+        go_openssl_BIO_meth_set_ctrl(method, go_openssl_gobio_ctrl) != 1 ||
+        go_openssl_BIO_meth_set_destroy(method, go_openssl_gobio_destroy) != 1)
+    {
+        GO_OPENSSL_DEBUGLOG(trace, "[ERROR] BIO_meth_set failed!\n");
+        go_openssl_BIO_meth_free(method);
+        return NULL;
+    }
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_gobio_method_new succeeded!\n");
+    return method;
+}
+
+// go_openssl_gobio_new creates a BIO that reads from and writes to the Go connection referenced
+// by handle. The handle is released when the BIO is freed.
+GO_BIO_PTR go_openssl_gobio_new(GO_BIO_METHOD_PTR method, uintptr_t handle)
+{
+    GO_BIO_PTR b = go_openssl_BIO_new(method);
+    if (b == NULL)
+        return NULL;
+    go_openssl_BIO_set_data(b, (void *)handle);
+    go_openssl_BIO_set_init(b, 1);
+    return b;
+}
+
+// go_openssl_ssl_configure_server_bio configures ssl as the server side of a connection over bio.
+void go_openssl_ssl_configure_server_bio(GO_SSL_PTR ssl, GO_BIO_PTR bio)
+{
+    go_openssl_SSL_set_bio(ssl, bio, bio);
+    go_openssl_SSL_set_accept_state(ssl);
+}
+
+// go_openssl_mem_bio_new creates a memory BIO. Reading from it while it is empty asks the
+// caller to retry, like a non-blocking socket would.
+GO_BIO_PTR go_openssl_mem_bio_new(void)
+{
+    GO_BIO_PTR b = go_openssl_BIO_new(go_openssl_BIO_s_mem());
+    if (b == NULL)
+        return NULL;
+    go_openssl_BIO_ctrl(b, GO_BIO_C_SET_BUF_MEM_EOF_RETURN, -1, NULL);
+    return b;
+}
+
+// go_openssl_ssl_configure_mem_bios configures ssl to read from rbio and write to wbio, as the
+// server side of a connection if server is set, and as a client verifying hostname otherwise.
+int go_openssl_ssl_configure_mem_bios(GO_SSL_PTR ssl, GO_BIO_PTR rbio, GO_BIO_PTR wbio,
+                                      const char *hostname, int server, int trace)
+{
+    GO_OPENSSL_DEBUGLOG(trace, "[INFO] go_openssl_ssl_configure_mem_bios with 'server=%d'...\n",
+                        server);
+    go_openssl_ERR_clear_error();
+    go_openssl_SSL_set_bio(ssl, rbio, wbio);
+    if (server)
+    {
+        go_openssl_SSL_set_accept_state(ssl);
+        return 0;
+    }
+    return go_openssl_ssl_configure(ssl, hostname, trace);
 }
\ No newline at end of file
@@ -23,6 +23,18 @@ go_openssl_do_leak_check(void)
 
 #define GO_OPENSSL_SOCK_STREAM 1
 
+// GO_OPENSSL_PSK_MAX_LEN is the maximum length of a pre-shared key.
+#define GO_OPENSSL_PSK_MAX_LEN 256
+
+// Go callbacks exported from callbacks.go
+extern int goPSKClient(uintptr_t handle, unsigned char **identity, size_t *identity_len, unsigned char *key, size_t max_key_len, size_t *key_len, int *sha384);
+extern int goPSKServer(uintptr_t handle, unsigned char *identity, size_t identity_len, unsigned char *key, size_t max_key_len, size_t *key_len, int *sha384);
+extern int goClientCert(uintptr_t handle, GO_SSL_PTR ssl, GO_X509_PTR *x509, GO_EVP_PKEY_PTR *pkey);
+extern void goHandshakeStart(uintptr_t handle);
+extern int goBIOWrite(uintptr_t handle, char *buf, int len, int *retry);
+extern int goBIORead(uintptr_t handle, char *buf, int len, int *retry);
+extern void goBIOFree(uintptr_t handle);
+
 // GO_OPENSSL_DEBUGLOG traces go_openssl_ helper function calls to stderr
 #define GO_OPENSSL_DEBUGLOG(enabled, ...) \
     do                                    \
@@ -40,9 +52,21 @@ int go_openssl_version_patch(void *handle);
 int go_openssl_thread_setup(void);
 void go_openssl_load_functions(void *handle, unsigned int major, unsigned int minor, unsigned int patch);
 GO_BIO_PTR go_openssl_create_bio(const char *hostname, const char *port, int family, int mode, int trace);
+GO_BIO_PTR go_openssl_socket_bio_new(int sock, int mode, int trace);
 int go_openssl_ctx_configure(GO_SSL_CTX_PTR ctx, long minTLS, long maxTLS, long options, int verifyMode, const char *nextProto, const char *caPath, const char *caFile, const char *certFile, const char *keyFile, int trace);
 int go_openssl_ssl_configure(GO_SSL_PTR ssl, const char *hostname, int trace);
 int go_openssl_ssl_configure_bio(GO_SSL_PTR ssl, GO_BIO_PTR bio, const char *hostname, int trace);
 int go_openssl_set_h2_alpn(GO_SSL_CTX_PTR ctx, int trace);
 int go_openssl_check_alpn_status(GO_SSL_PTR ssl, char *selected_proto, int *selected_len, int trace);
-int go_openssl_get_fips_provider_info(char *buf, size_t size);
\ No newline at end of file
+int go_openssl_get_fips_provider_info(char *buf, size_t size);
+int go_openssl_set_callbacks(GO_SSL_PTR ssl, uintptr_t handle);
+uintptr_t go_openssl_get_callbacks(GO_SSL_PTR ssl);
+int go_openssl_ctx_configure_psk(GO_SSL_CTX_PTR ctx, int client, int server, int tls13, int trace);
+int go_openssl_ctx_configure_client_cert(GO_SSL_CTX_PTR ctx, int trace);
+int go_openssl_ctx_configure_info_cb(GO_SSL_CTX_PTR ctx, int trace);
+int go_openssl_ctx_configure_cert_comp(GO_SSL_CTX_PTR ctx, int *algs, size_t len, int supported, int trace);
+GO_BIO_METHOD_PTR go_openssl_gobio_method_new(int trace);
+GO_BIO_PTR go_openssl_gobio_new(GO_BIO_METHOD_PTR method, uintptr_t handle);
+void go_openssl_ssl_configure_server_bio(GO_SSL_PTR ssl, GO_BIO_PTR bio);
+GO_BIO_PTR go_openssl_mem_bio_new(void);
+int go_openssl_ssl_configure_mem_bios(GO_SSL_PTR ssl, GO_BIO_PTR rbio, GO_BIO_PTR wbio, const char *hostname, int server, int trace);
\ No newline at end of file
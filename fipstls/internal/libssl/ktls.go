@@ -0,0 +1,42 @@
+package libssl
+
+// #include "golibssl.h"
+import "C"
+
+// SSLKTLSSend returns true if the kernel encrypts the records sent by ssl.
+func SSLKTLSSend(ssl *SSL) bool {
+	if ssl == nil || !versionAtOrAbove(3, 0, 0) {
+		return false
+	}
+	return bioCtrlKTLS(C.go_openssl_SSL_get_wbio(ssl.inner), C.GO_BIO_CTRL_GET_KTLS_SEND)
+}
+
+// SSLKTLSRecv returns true if the kernel decrypts the records received by ssl.
+func SSLKTLSRecv(ssl *SSL) bool {
+	if ssl == nil || !versionAtOrAbove(3, 0, 0) {
+		return false
+	}
+	return bioCtrlKTLS(C.go_openssl_SSL_get_rbio(ssl.inner), C.GO_BIO_CTRL_GET_KTLS_RECV)
+}
+
+// bioCtrlKTLS returns the kTLS state queried with cmd. BIOs other than sockets, such as Go and
+// memory BIOs, ignore the query.
+func bioCtrlKTLS(bio C.GO_BIO_PTR, cmd C.int) bool {
+	return bio != nil && C.go_openssl_BIO_ctrl(bio, cmd, 0, nil) > 0
+}
+
+// SSLSendfile sends up to size bytes of the file fd starting at offset with ssl, without
+// copying them to user space. It requires kTLS to be enabled for sending, see [SSLKTLSSend].
+func SSLSendfile(ssl *SSL, fd int, offset int64, size int) (int, error) {
+	if ssl == nil {
+		return 0, NewOpenSSLError("libssl: SSL_sendfile: SSL is nil")
+	}
+	if !versionAtOrAbove(3, 0, 0) {
+		return 0, errUnsupportedVersion()
+	}
+	r := C.go_openssl_SSL_sendfile(ssl.inner, C.int(fd), C.off_t(offset), C.size_t(size), 0)
+	if r < 0 {
+		return 0, newSSLError("libssl: SSL_sendfile", SSLGetError(ssl, int(r)))
+	}
+	return int(r), nil
+}
@@ -96,12 +96,13 @@ func TestSSL_connect(t *testing.T) {
 	}
 
 	// Receive the response over TLS
-	resp, _, err := libssl.SSLReadEx(ssl, 1024)
+	resp := make([]byte, 1024)
+	n, err := libssl.SSLReadEx(ssl, resp)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	t.Logf("Response was %v bytes.\n", len(resp))
+	t.Logf("Response was %v bytes.\n", n)
 }
 
 func TestBlockingClient(t *testing.T) {
@@ -163,10 +164,10 @@ func TestBlockingClient(t *testing.T) {
 	}
 
 	// Read the response
-	var resp []byte
+	resp := make([]byte, 4096)
 	var n int
 	for {
-		resp, n, err = libssl.SSLReadEx(ssl, 4096)
+		n, err = libssl.SSLReadEx(ssl, resp)
 		if err != nil {
 			if libssl.SSLGetError(ssl, 0) == libssl.SSL_ERROR_ZERO_RETURN {
 				break
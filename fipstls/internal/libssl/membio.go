@@ -0,0 +1,90 @@
+package libssl
+
+// #include "golibssl.h"
+import "C"
+import (
+	"unsafe"
+)
+
+// NewMemBIO creates a memory [BIO] that buffers the data written to it until it is read.
+// Reading from an empty memory BIO reports SSL_ERROR_WANT_READ to libssl, like a non-blocking
+// socket would.
+func NewMemBIO() (*BIO, error) {
+	if !versionAtOrAbove(1, 1, 0) {
+		return nil, errUnsupportedVersion()
+	}
+	bio := C.go_openssl_mem_bio_new()
+	if bio == nil {
+		return nil, NewOpenSSLError("libssl: BIO_new")
+	}
+	return &BIO{inner: bio}, nil
+}
+
+// BIOWrite appends b to the data buffered in a memory [BIO].
+func BIOWrite(bio *BIO, b []byte) error {
+	if bio == nil {
+		return NewOpenSSLError("libssl: BIO_write: BIO is nil")
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	if C.go_openssl_BIO_write(bio.inner, unsafe.Pointer(&b[0]), C.int(len(b))) != C.int(len(b)) {
+		return NewOpenSSLError("libssl: BIO_write")
+	}
+	return nil
+}
+
+// BIOReadPending reads all the data buffered in a memory [BIO], or returns nil if there is
+// none.
+func BIOReadPending(bio *BIO) []byte {
+	if bio == nil {
+		return nil
+	}
+	n := C.go_openssl_BIO_ctrl(bio.inner, C.GO_BIO_CTRL_PENDING, 0, nil)
+	if n <= 0 {
+		return nil
+	}
+	b := make([]byte, int(n))
+	r := C.go_openssl_BIO_read(bio.inner, unsafe.Pointer(&b[0]), C.int(len(b)))
+	if r <= 0 {
+		return nil
+	}
+	return b[:r]
+}
+
+// BIOPending returns the number of bytes buffered in a memory [BIO].
+func BIOPending(bio *BIO) int {
+	if bio == nil {
+		return 0
+	}
+	return int(C.go_openssl_BIO_ctrl(bio.inner, C.GO_BIO_CTRL_PENDING, 0, nil))
+}
+
+// BIORead reads up to len(b) bytes buffered in a memory [BIO] into b, and returns the number
+// of bytes read, which is zero if there are none.
+func BIORead(bio *BIO, b []byte) int {
+	if bio == nil || len(b) == 0 {
+		return 0
+	}
+	r := C.go_openssl_BIO_read(bio.inner, unsafe.Pointer(&b[0]), C.int(len(b)))
+	if r <= 0 {
+		return 0
+	}
+	return int(r)
+}
+
+// SSLConfigureMemBIOs sets rbio and wbio on ssl, which takes ownership of them. They are
+// typically memory BIOs, but any BIO may be used, such as a socket BIO to read from. The ssl is
+// prepared to accept a handshake if server is true, and to connect to hostname otherwise.
+func SSLConfigureMemBIOs(ssl *SSL, rbio, wbio *BIO, hostname string, server bool) error {
+	if ssl == nil || rbio == nil || wbio == nil {
+		return NewOpenSSLError("libssl: SSL_set_bio: SSL or BIO is nil")
+	}
+	cHost := C.CString(hostname)
+	defer C.free(unsafe.Pointer(cHost))
+	if r := C.go_openssl_ssl_configure_mem_bios(ssl.inner, rbio.inner, wbio.inner, cHost,
+		boolToInt(server), C.int(int(debugLogging))); r != 0 {
+		return newSSLError("libssl: ssl_configure_mem_bios", SSLGetError(ssl, int(r)))
+	}
+	return nil
+}
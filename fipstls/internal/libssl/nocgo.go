@@ -2,7 +2,10 @@
 
 package libssl
 
-import "errors"
+import (
+	"errors"
+	"io"
+)
 
 // OpenSSL initialization options
 const (
@@ -26,6 +29,9 @@ const (
 	SSL_OP_NO_COMPRESSION                         = iota
 	SSL_OP_CIPHER_SERVER_PREFERENCE               = iota
 	SSL_OP_TLS_ROLLBACK_BUG                       = iota
+	SSL_OP_NO_RENEGOTIATION                       = iota
+	SSL_OP_ENABLE_KTLS                            = iota
+	SSL_OP_NO_EXTENDED_MASTER_SECRET              = iota
 )
 
 // SSL verify modes
@@ -126,10 +132,17 @@ const (
 
 // SSL and SSL_CTX ctrl constants
 const (
-	SSL_CTRL_OPTIONS               = iota
-	SSL_CTRL_SET_TLSEXT_HOSTNAME   = iota
-	SSL_CTRL_SET_MIN_PROTO_VERSION = iota
-	SSL_CTRL_SET_MAX_PROTO_VERSION = iota
+	SSL_CTRL_OPTIONS                 = iota
+	SSL_CTRL_MODE                    = iota
+	SSL_CTRL_SET_READ_AHEAD          = iota
+	SSL_CTRL_SET_TLSEXT_HOSTNAME     = iota
+	SSL_CTRL_CHAIN                   = iota
+	SSL_CTRL_CHAIN_CERT              = iota
+	SSL_CTRL_GET_EXTMS_SUPPORT       = iota
+	SSL_CTRL_SET_MIN_PROTO_VERSION   = iota
+	SSL_CTRL_SET_MAX_PROTO_VERSION   = iota
+	SSL_CTRL_SET_MAX_SEND_FRAGMENT   = iota
+	SSL_CTRL_SET_SPLIT_SEND_FRAGMENT = iota
 )
 
 const (
@@ -149,51 +162,166 @@ const (
 	BIO_LOOKUP_SERVER = iota
 )
 
+// TLS 1.3 early data status
+const (
+	SSL_EARLY_DATA_NOT_SENT = iota
+	SSL_EARLY_DATA_REJECTED = iota
+	SSL_EARLY_DATA_ACCEPTED = iota
+)
+
+// TLS 1.3 early data read results
+const (
+	SSL_READ_EARLY_DATA_ERROR   = iota
+	SSL_READ_EARLY_DATA_SUCCESS = iota
+	SSL_READ_EARLY_DATA_FINISH  = iota
+)
+
+// TLS 1.3 key update types
+const (
+	SSL_KEY_UPDATE_NONE          = iota
+	SSL_KEY_UPDATE_NOT_REQUESTED = iota
+	SSL_KEY_UPDATE_REQUESTED     = iota
+)
+
+// RFC 7250 certificate types
+const (
+	TLSEXT_cert_type_x509 = iota
+	TLSEXT_cert_type_rpk  = iota
+)
+
+// RFC 8879 certificate compression algorithms
+const (
+	TLSEXT_comp_cert_none   = iota
+	TLSEXT_comp_cert_zlib   = iota
+	TLSEXT_comp_cert_brotli = iota
+	TLSEXT_comp_cert_zstd   = iota
+)
+
 var ErrMethodUnimplemented = errors.New("method unimplemented")
 
 type BIO struct{}
 type SSLCtx struct{}
 type SSL struct{}
 type SSLMethod struct{}
+type SSLSession struct{}
 type DebugMode int
+type PSK struct {
+	Identity []byte
+	Key      []byte
+	SHA384   bool
+}
+type Callbacks struct {
+	PSKClient      func() *PSK
+	PSKServer      func(identity []byte) (*PSK, error)
+	ClientCert     func(req *CertificateRequest) (*Certificate, error)
+	HandshakeStart func()
+}
+type CertificateRequest struct {
+	CANames [][]byte
+	SigAlgs []uint16
+}
+type Certificate struct {
+	Chain [][]byte
+	Key   []byte
+}
+
+func (cb *Callbacks) Err() error { return nil }
 
 const DebugDisabled DebugMode = iota
 
 func BIOFree(bio *BIO) error                          { return ErrMethodUnimplemented }
+func BIOReadPending(bio *BIO) []byte                  { return nil }
+func BIOPending(bio *BIO) int                         { return 0 }
+func BIORead(bio *BIO, b []byte) int                  { return 0 }
+func BIOTakeReadError(bio *BIO) error                 { return nil }
+func BIOTakeWriteError(bio *BIO) error                { return nil }
+func BIOWrite(bio *BIO, b []byte) error               { return ErrMethodUnimplemented }
 func CheckLeaks()                                     {}
 func CheckVersion(version string) (exists, fips bool) { return false, false }
+func D2ISSLSession(der []byte) (*SSLSession, error)   { return nil, ErrMethodUnimplemented }
 func CreateBIO(hostname, port string, family, mode int) (*BIO, int, error) {
 	return nil, 0, ErrMethodUnimplemented
 }
-func EnableDebugLogging()                                       {}
-func FIPS() bool                                                { return false }
-func FIPSCapable() bool                                         { return false }
-func CheckFIPS() error                                          { return nil }
-func GetFipsProviderInfo() (string, error)                      { return "", nil }
-func GetVersion() string                                        { return "" }
-func Init(file string) error                                    { return ErrMethodUnimplemented }
-func NewOpenSSLError(msg string) error                          { return ErrMethodUnimplemented }
-func NewSSL(sslCtx *SSLCtx) (*SSL, error)                       { return nil, ErrMethodUnimplemented }
-func NewSSLCtx(tlsMethod *SSLMethod) (*SSLCtx, error)           { return nil, ErrMethodUnimplemented }
-func NewTLSClientMethod() (*SSLMethod, error)                   { return nil, ErrMethodUnimplemented }
-func NewTLSMethod() (*SSLMethod, error)                         { return nil, ErrMethodUnimplemented }
-func NewTLSServerMethod() (*SSLMethod, error)                   { return nil, ErrMethodUnimplemented }
-func Reset()                                                    {}
+func EnableDebugLogging()                               {}
+func FIPS() bool                                        { return false }
+func FIPSCapable() bool                                 { return false }
+func CheckFIPS() error                                  { return nil }
+func GetFipsProviderInfo() (string, error)              { return "", nil }
+func GetVersion() string                                { return "" }
+func I2DSSLSession(session *SSLSession) ([]byte, error) { return nil, ErrMethodUnimplemented }
+func Init(file string) error                            { return ErrMethodUnimplemented }
+func NewGoBIO(rw io.ReadWriter) (*BIO, error)           { return nil, ErrMethodUnimplemented }
+func SSLKTLSSend(ssl *SSL) bool                         { return false }
+func SSLKTLSRecv(ssl *SSL) bool                         { return false }
+func SSLSendfile(ssl *SSL, fd int, offset int64, size int) (int, error) {
+	return 0, ErrMethodUnimplemented
+}
+func NewMemBIO() (*BIO, error)                         { return nil, ErrMethodUnimplemented }
+func NewOpenSSLError(msg string) error                 { return ErrMethodUnimplemented }
+func NewSSL(sslCtx *SSLCtx) (*SSL, error)              { return nil, ErrMethodUnimplemented }
+func NewSSLCtx(tlsMethod *SSLMethod) (*SSLCtx, error)  { return nil, ErrMethodUnimplemented }
+func NewSocketBIO(sock, mode int) (*BIO, error)        { return nil, ErrMethodUnimplemented }
+func NewTLSClientMethod() (*SSLMethod, error)          { return nil, ErrMethodUnimplemented }
+func NewTLSMethod() (*SSLMethod, error)                { return nil, ErrMethodUnimplemented }
+func NewTLSServerMethod() (*SSLMethod, error)          { return nil, ErrMethodUnimplemented }
+func Reset()                                           {}
+func SSLAddExpectedRPKs(ssl *SSL, keys [][]byte) error { return ErrMethodUnimplemented }
+func SSLConfigureServerBIO(ssl *SSL, bio *BIO) error   { return ErrMethodUnimplemented }
+func SSLConfigureMemBIOs(ssl *SSL, rbio, wbio *BIO, hostname string, server bool) error {
+	return ErrMethodUnimplemented
+}
 func SSLClearError()                                            {}
 func SSLConfigureBIO(ssl *SSL, bio *BIO, hostname string) error { return ErrMethodUnimplemented }
 func SSLConnect(ssl *SSL) error                                 { return ErrMethodUnimplemented }
 func SSLCtxConfigure(ctx *SSLCtx, config *CtxConfig) error      { return ErrMethodUnimplemented }
+func SSLDoHandshake(ssl *SSL) error                             { return ErrMethodUnimplemented }
 func SSLCtxFree(sslCtx *SSLCtx) error                           { return ErrMethodUnimplemented }
+func SSLCtxUpRef(sslCtx *SSLCtx) error                          { return ErrMethodUnimplemented }
 func SSLCtxSetH2Proto(sslCtx *SSLCtx) error                     { return ErrMethodUnimplemented }
+func SSLExtmsSupport(ssl *SSL) bool                             { return false }
 func SSLFree(ssl *SSL) error                                    { return ErrMethodUnimplemented }
+func SSLGet1Session(ssl *SSL) (*SSLSession, error)              { return nil, ErrMethodUnimplemented }
+func SSLGetALPNSelected(ssl *SSL) string                        { return "" }
+func SSLGetEarlyDataStatus(ssl *SSL) int                        { return 0 }
 func SSLGetError(ssl *SSL, ret int) int                         { return 0 }
+func SSLGetMaxFragmentLength(ssl *SSL) int                      { return 0 }
+func SSLGetPeerCertComp(ssl *SSL) int                           { return 0 }
+func SSLGetPeerRPK(ssl *SSL) ([]byte, error)                    { return nil, ErrMethodUnimplemented }
+func SSLGetCertificate(ssl *SSL) ([]byte, error)                { return nil, ErrMethodUnimplemented }
+func SSLGetFinished(ssl *SSL) []byte                            { return nil }
+func SSLGetPeerCertificate(ssl *SSL) ([]byte, error)            { return nil, ErrMethodUnimplemented }
+func SSLGetPeerFinished(ssl *SSL) []byte                        { return nil }
 func SSLGetShutdown(ssl *SSL) int                               { return 0 }
 func SSLGetVerifyResult(ssl *SSL) error                         { return ErrMethodUnimplemented }
-func SSLReadEx(ssl *SSL, size int64) ([]byte, int, error)       { return nil, 0, ErrMethodUnimplemented }
+func SSLIsServer(ssl *SSL) bool                                 { return false }
+func SSLKeyUpdate(ssl *SSL, requestPeer bool) error             { return ErrMethodUnimplemented }
+func SSLPeek(ssl *SSL) (int, error)                             { return 0, ErrMethodUnimplemented }
+func SSLReadEarlyData(ssl *SSL, b []byte) (int, bool, error)    { return 0, false, ErrMethodUnimplemented }
+func SSLReadEx(ssl *SSL, b []byte) (int, error)                 { return 0, ErrMethodUnimplemented }
+func SSLRenegotiate(ssl *SSL) error                             { return ErrMethodUnimplemented }
+func SSLSessionFree(session *SSLSession) error                  { return ErrMethodUnimplemented }
+func SSLSessionGetMaxEarlyData(session *SSLSession) uint32      { return 0 }
+func SSLSessionIsResumable(session *SSLSession) bool            { return false }
+func SSLSessionReused(ssl *SSL) bool                            { return false }
+func SSLSetCallbacks(ssl *SSL, cb *Callbacks) error             { return ErrMethodUnimplemented }
+func SSLSetMode(ssl *SSL, mode int64) error                     { return ErrMethodUnimplemented }
+func SSLSetOptions(ssl *SSL, options int64) error               { return ErrMethodUnimplemented }
+func SSLSetPostHandshakeAuth(ssl *SSL) error                    { return ErrMethodUnimplemented }
+func SSLSetSession(ssl *SSL, session *SSLSession) error         { return ErrMethodUnimplemented }
 func SSLSetShutdown(ssl *SSL, mode int) error                   { return ErrMethodUnimplemented }
 func SSLShutdown(ssl *SSL) error                                { return ErrMethodUnimplemented }
 func SSLStatusALPN(ssl *SSL) string                             { return "" }
-func SSLWriteEx(ssl *SSL, req []byte) (int, error)              { return 0, ErrMethodUnimplemented }
+func SSLUnexpectedEOF(code int) bool                            { return false }
+func SSLVerifyClientPostHandshake(ssl *SSL) error               { return ErrMethodUnimplemented }
+func SSLVersion(ssl *SSL) int                                   { return 0 }
+func SSLWriteEarlyData(ssl *SSL, req []byte) (int, error)       { return 0, ErrMethodUnimplemented }
+func SSLWriteEx(ssl *SSL, b []byte) (int, error)                { return 0, ErrMethodUnimplemented }
 func SetFIPS(enabled bool) error                                { return ErrMethodUnimplemented }
+func SupportsCertCompression() bool                             { return false }
+func SupportsRPK() bool                                         { return false }
 func VersionText() string                                       { return "" }
 func X509VerifyCertErrorString(n int64) string                  { return "" }
+
+func SSLExportKeyingMaterial(ssl *SSL, label string, context []byte, length int) ([]byte, error) {
+	return nil, ErrMethodUnimplemented
+}
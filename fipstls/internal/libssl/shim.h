@@ -1,5 +1,6 @@
 #include <stdlib.h> // size_t
 #include <stdint.h> // uint64_t
+#include <sys/types.h> // ssize_t, off_t
 
 // OpenSSL initialization options
 enum
@@ -24,7 +25,10 @@ enum
     GO_SSL_OP_NO_SESSION_RESUMPTION_ON_RENEGOTIATION = 0x00010000L,
     GO_SSL_OP_NO_COMPRESSION = 0x00020000L,
     GO_SSL_OP_CIPHER_SERVER_PREFERENCE = 0x00400000L,
-    GO_SSL_OP_TLS_ROLLBACK_BUG = 0x00000400L
+    GO_SSL_OP_TLS_ROLLBACK_BUG = 0x00000400L,
+    GO_SSL_OP_NO_RENEGOTIATION = 0x40000000L,
+    GO_SSL_OP_ENABLE_KTLS = 0x00000008L,
+    GO_SSL_OP_NO_EXTENDED_MASTER_SECRET = 0x00000001L
 };
 
 // SSL verify modes
@@ -50,6 +54,12 @@ enum
     GO_SSL_MODE_RELEASE_BUFFERS = 0x00000010L
 };
 
+// Info callback events
+enum
+{
+    GO_SSL_CB_HANDSHAKE_START = 0x10
+};
+
 // TLS version options
 enum
 {
@@ -148,9 +158,14 @@ enum
     GO_SSL_CTRL_MODE = 33,
     GO_SSL_CTRL_GET_READ_AHEAD = 40,
     GO_SSL_CTRL_SET_READ_AHEAD = 41,
+    GO_SSL_CTRL_SET_MAX_SEND_FRAGMENT = 52,
     GO_SSL_CTRL_SET_TLSEXT_HOSTNAME = 55,
+    GO_SSL_CTRL_CHAIN = 88,
+    GO_SSL_CTRL_CHAIN_CERT = 89,
+    GO_SSL_CTRL_GET_EXTMS_SUPPORT = 122,
     GO_SSL_CTRL_SET_MIN_PROTO_VERSION = 123,
-    GO_SSL_CTRL_SET_MAX_PROTO_VERSION = 124
+    GO_SSL_CTRL_SET_MAX_PROTO_VERSION = 124,
+    GO_SSL_CTRL_SET_SPLIT_SEND_FRAGMENT = 125
 };
 
 enum
@@ -158,6 +173,16 @@ enum
     GO_TLSEXT_NAMETYPE_host_name = 0,
 };
 
+// RFC 6066 max_fragment_length modes
+enum
+{
+    GO_TLSEXT_max_fragment_length_DISABLED = 0,
+    GO_TLSEXT_max_fragment_length_512 = 1,
+    GO_TLSEXT_max_fragment_length_1024 = 2,
+    GO_TLSEXT_max_fragment_length_2048 = 3,
+    GO_TLSEXT_max_fragment_length_4096 = 4
+};
+
 // SSL shutdown modes
 enum
 {
@@ -166,6 +191,13 @@ enum
     GO_SSL_RECEIVED_SHUTDOWN = 2,
 };
 
+// Error codes of the SSL library
+enum
+{
+    GO_ERR_LIB_SSL = 20,
+    GO_SSL_R_UNEXPECTED_EOF_WHILE_READING = 294,
+};
+
 // BIO ctrl options
 enum
 {
@@ -176,6 +208,27 @@ enum
     GO_BIO_C_SET_FILENAME = 108,
     GO_BIO_C_SET_SSL = 109,
     GO_BIO_C_GET_SSL = 110,
+    GO_BIO_CTRL_FLUSH = 11,
+    GO_BIO_CTRL_PENDING = 10,
+    GO_BIO_C_SET_BUF_MEM_EOF_RETURN = 130,
+    GO_BIO_CTRL_GET_KTLS_SEND = 73,
+    GO_BIO_CTRL_GET_KTLS_RECV = 76,
+};
+
+// BIO flags
+enum
+{
+    GO_BIO_FLAGS_READ = 0x01,
+    GO_BIO_FLAGS_WRITE = 0x02,
+    GO_BIO_FLAGS_IO_SPECIAL = 0x04,
+    GO_BIO_FLAGS_RWS = 0x07,
+    GO_BIO_FLAGS_SHOULD_RETRY = 0x08,
+};
+
+// BIO types
+enum
+{
+    GO_BIO_TYPE_SOURCE_SINK = 0x0400,
 };
 
 // BIO lookup type
@@ -215,6 +268,46 @@ enum
     GO_SSL_TLSEXT_ERR_NOACK = 3,
 };
 
+// TLS 1.3 early data status
+enum
+{
+    GO_SSL_EARLY_DATA_NOT_SENT = 0,
+    GO_SSL_EARLY_DATA_REJECTED = 1,
+    GO_SSL_EARLY_DATA_ACCEPTED = 2,
+};
+
+// TLS 1.3 early data read results
+enum
+{
+    GO_SSL_READ_EARLY_DATA_ERROR = 0,
+    GO_SSL_READ_EARLY_DATA_SUCCESS = 1,
+    GO_SSL_READ_EARLY_DATA_FINISH = 2,
+};
+
+// TLS 1.3 key update types
+enum
+{
+    GO_SSL_KEY_UPDATE_NONE = -1,
+    GO_SSL_KEY_UPDATE_NOT_REQUESTED = 0,
+    GO_SSL_KEY_UPDATE_REQUESTED = 1,
+};
+
+// RFC 7250 certificate types
+enum
+{
+    GO_TLSEXT_cert_type_x509 = 0,
+    GO_TLSEXT_cert_type_rpk = 2,
+};
+
+// RFC 8879 certificate compression algorithms
+enum
+{
+    GO_TLSEXT_comp_cert_none = 0,
+    GO_TLSEXT_comp_cert_zlib = 1,
+    GO_TLSEXT_comp_cert_brotli = 2,
+    GO_TLSEXT_comp_cert_zstd = 3,
+};
+
 // NPN errors
 enum
 {
@@ -267,6 +360,29 @@ typedef void *GO_BIO_ADDRINFO_PTR;
 typedef void *GO_BIO_ADDR_PTR;
 typedef void *GO_BIO_PTR;
 typedef void *GO_BIO_METHOD_PTR;
+typedef void *GO_SSL_CIPHER_PTR;
+typedef void *GO_EVP_PKEY_PTR;
+typedef void *GO_X509_PTR;
+typedef void *GO_X509_NAME_PTR;
+typedef void *GO_OPENSSL_STACK_PTR;
+
+// PSK callback types
+typedef unsigned int (*GO_SSL_psk_client_cb_func)(GO_SSL_PTR ssl, const char *hint, char *identity, unsigned int max_identity_len, unsigned char *psk, unsigned int max_psk_len);
+typedef unsigned int (*GO_SSL_psk_server_cb_func)(GO_SSL_PTR ssl, const char *identity, unsigned char *psk, unsigned int max_psk_len);
+typedef int (*GO_SSL_psk_use_session_cb_func)(GO_SSL_PTR ssl, const GO_EVP_MD_PTR md, const unsigned char **id, size_t *idlen, GO_SSL_SESSION_PTR *sess);
+typedef int (*GO_SSL_psk_find_session_cb_func)(GO_SSL_PTR ssl, const unsigned char *identity, size_t identity_len, GO_SSL_SESSION_PTR *sess);
+
+// Client certificate callback type
+typedef int (*GO_SSL_client_cert_cb_func)(GO_SSL_PTR ssl, GO_X509_PTR *x509, GO_EVP_PKEY_PTR *pkey);
+
+// Info callback type
+typedef void (*GO_SSL_info_cb_func)(const GO_SSL_PTR ssl, int where, int ret);
+
+// BIO method callback types
+typedef int (*GO_BIO_write_func)(GO_BIO_PTR b, const char *buf, int len);
+typedef int (*GO_BIO_read_func)(GO_BIO_PTR b, char *buf, int len);
+typedef long (*GO_BIO_ctrl_func)(GO_BIO_PTR b, int cmd, long num, void *ptr);
+typedef int (*GO_BIO_destroy_func)(GO_BIO_PTR b);
 
 // FOR_ALL_LIBSSL_FUNCTIONS is the list of all functions from libcrypto that are used in this package.
 // Forgetting to add a function here results in build failure with message reporting the function
@@ -304,6 +420,9 @@ typedef void *GO_BIO_METHOD_PTR;
 // DEFINEFUNC_3_0 acts like DEFINEFUNC but only aborts the process if function can't be loaded
 // when using 3.0.0 or higher.
 //
+// DEFINEFUNC_3_2 acts like DEFINEFUNC but only aborts the process if function can't be loaded
+// when using 3.2.0 or higher.
+//
 // DEFINEFUNC_RENAMED_1_1 acts like DEFINEFUNC but tries to load the function using the new name when using >= 1.1.x
 // and the old name when using 1.0.2. In both cases the function will have the new name.
 //
@@ -312,6 +431,7 @@ typedef void *GO_BIO_METHOD_PTR;
 //
 #define FOR_ALL_LIBSSL_FUNCTIONS                                                                                                                                                                                                                            \
     DEFINEFUNC(void, ERR_error_string_n, (unsigned long e, char *buf, size_t len), (e, buf, len))                                                                                                                                                           \
+    DEFINEFUNC(unsigned long, ERR_peek_last_error, (void), ())                                                                                                                                                                                              \
     DEFINEFUNC_LEGACY_1(unsigned long, ERR_get_error_line, (const char **file, int *line), (file, line))                                                                                                                                                    \
     DEFINEFUNC_3_0(unsigned long, ERR_get_error_all, (const char **file, int *line, const char **func, const char **data, int *flags), (file, line, func, data, flags))                                                                                     \
     DEFINEFUNC_RENAMED_1_1(const char *, OpenSSL_version, SSLeay_version, (int type), (type))                                                                                                                                                               \
@@ -343,11 +463,13 @@ typedef void *GO_BIO_METHOD_PTR;
     DEFINEFUNC_RENAMED_1_1(GO_SSL_METHOD_PTR, TLS_server_method, SSLv23_server_method, (void), ())                                                                                                                                                          \
     DEFINEFUNC(GO_SSL_CTX_PTR, SSL_CTX_new, (GO_SSL_METHOD_PTR method), (method))                                                                                                                                                                           \
     DEFINEFUNC(void, SSL_CTX_free, (GO_SSL_CTX_PTR ctx), (ctx))                                                                                                                                                                                             \
+    DEFINEFUNC_1_1(int, SSL_CTX_up_ref, (GO_SSL_CTX_PTR ctx), (ctx))                                                                                                                                                                                        \
     DEFINEFUNC(GO_SSL_PTR, SSL_new, (GO_SSL_CTX_PTR ctx), (ctx))                                                                                                                                                                                            \
     DEFINEFUNC(void, SSL_free, (GO_SSL_PTR ctx), (ctx))                                                                                                                                                                                                     \
     DEFINEFUNC(void, SSL_clear, (GO_SSL_PTR ctx), (ctx))                                                                                                                                                                                                    \
     DEFINEFUNC(int, SSL_connect, (GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                                   \
     DEFINEFUNC_1_1(int, SSL_write_ex, (GO_SSL_PTR s, const void *buf, size_t num, size_t *written), (s, buf, num, written))                                                                                                                                 \
+    DEFINEFUNC_3_0(ssize_t, SSL_sendfile, (GO_SSL_PTR s, int fd, off_t offset, size_t size, int flags), (s, fd, offset, size, flags))                                                                                                                       \
     DEFINEFUNC_1_1(int, SSL_read_ex, (GO_SSL_PTR s, void *buf, size_t num, size_t *readbytes), (s, buf, num, readbytes)) /* SSL_CTX_ctrl is needed for SSL_CTX_set_min_proto_version */                                                                     \
     DEFINEFUNC(long, SSL_CTX_ctrl, (GO_SSL_CTX_PTR ctx, int cmd, long larg, void *parg), (ctx, cmd, larg, parg))                                                                                                                                            \
     DEFINEFUNC(int, SSL_CTX_set_alpn_protos, (GO_SSL_CTX_PTR ctx, const unsigned char *protos, unsigned protos_len), (ctx, protos, protos_len))                                                                                                             \
@@ -371,9 +493,86 @@ typedef void *GO_BIO_METHOD_PTR;
     DEFINEFUNC(int, SSL_get_shutdown, (GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                              \
     DEFINEFUNC(void, SSL_set_shutdown, (GO_SSL_PTR ssl, int mode), (ssl, mode))                                                                                                                                                                             \
     DEFINEFUNC(void, SSL_set_connect_state, (GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                        \
+    DEFINEFUNC(void, SSL_set_accept_state, (GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                         \
     DEFINEFUNC(int, SSL_do_handshake, (GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                              \
     DEFINEFUNC(int, SSL_set_session, (GO_SSL_PTR ssl, GO_SSL_SESSION_PTR session), (ssl, session))                                                                                                                                                          \
     DEFINEFUNC(void, SSL_set_bio, (GO_SSL_PTR s, GO_BIO_PTR rbio, GO_BIO_PTR wbio), (s, rbio, wbio))                                                                                                                                                        \
+    DEFINEFUNC(GO_BIO_PTR, SSL_get_rbio, (const GO_SSL_PTR s), (s))                                                                                                                                                                                         \
+    DEFINEFUNC(GO_BIO_PTR, SSL_get_wbio, (const GO_SSL_PTR s), (s))                                                                                                                                                                                         \
+    DEFINEFUNC(GO_SSL_SESSION_PTR, SSL_get1_session, (GO_SSL_PTR ssl), (ssl))                                                                                                                                                                               \
+    DEFINEFUNC(void, SSL_SESSION_free, (GO_SSL_SESSION_PTR session), (session))                                                                                                                                                                             \
+    DEFINEFUNC(int, i2d_SSL_SESSION, (GO_SSL_SESSION_PTR in, unsigned char **pp), (in, pp))                                                                                                                                                                 \
+    DEFINEFUNC(GO_SSL_SESSION_PTR, d2i_SSL_SESSION, (GO_SSL_SESSION_PTR *a, const unsigned char **pp, long length), (a, pp, length))                                                                                                                        \
+    DEFINEFUNC_1_1(int, SSL_session_reused, (const GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                  \
+    DEFINEFUNC(int, SSL_version, (const GO_SSL_PTR ssl), (ssl))                                                                                                                                                                                             \
+    DEFINEFUNC_1_1_1(int, SSL_SESSION_is_resumable, (const GO_SSL_SESSION_PTR s), (s))                                                                                                                                                                      \
+    DEFINEFUNC_1_1_1(uint32_t, SSL_SESSION_get_max_early_data, (const GO_SSL_SESSION_PTR s), (s))                                                                                                                                                           \
+    DEFINEFUNC_1_1_1(int, SSL_write_early_data, (GO_SSL_PTR s, const void *buf, size_t num, size_t *written), (s, buf, num, written))                                                                                                                       \
+    DEFINEFUNC_1_1_1(int, SSL_get_early_data_status, (const GO_SSL_PTR s), (s))                                                                                                                                                                             \
+    DEFINEFUNC_1_1_1(int, SSL_read_early_data, (GO_SSL_PTR s, void *buf, size_t num, size_t *readbytes), (s, buf, num, readbytes))                                                                                                                          \
+    DEFINEFUNC_1_1_1(int, SSL_CTX_set_max_early_data, (GO_SSL_CTX_PTR ctx, uint32_t max_early_data), (ctx, max_early_data))                                                                                                                                 \
+    DEFINEFUNC_1_1_1(int, SSL_CTX_set_recv_max_early_data, (GO_SSL_CTX_PTR ctx, uint32_t recv_max_early_data), (ctx, recv_max_early_data))                                                                                                                  \
+    DEFINEFUNC_1_1_1(int, SSL_key_update, (GO_SSL_PTR s, int updatetype), (s, updatetype))                                                                                                                                                                  \
+    DEFINEFUNC(int, SSL_renegotiate, (GO_SSL_PTR s), (s))                                                                                                                                                                                                   \
+    DEFINEFUNC(int, SSL_export_keying_material, (GO_SSL_PTR s, unsigned char *out, size_t olen, const char *label, size_t llen, const unsigned char *context, size_t contextlen, int use_context), (s, out, olen, label, llen, context, contextlen, use_context))\
+    DEFINEFUNC_RENAMED_3_0(GO_X509_PTR, SSL_get1_peer_certificate, SSL_get_peer_certificate, (const GO_SSL_PTR s), (s))                                                                                                                                     \
+    DEFINEFUNC(GO_X509_PTR, SSL_get_certificate, (const GO_SSL_PTR s), (s))                                                                                                                                                                                 \
+    DEFINEFUNC(int, i2d_X509, (GO_X509_PTR a, unsigned char **pp), (a, pp))                                                                                                                                                                                 \
+    DEFINEFUNC(void, X509_free, (GO_X509_PTR a), (a))                                                                                                                                                                                                       \
+    DEFINEFUNC(size_t, SSL_get_finished, (const GO_SSL_PTR s, void *buf, size_t count), (s, buf, count))                                                                                                                                                    \
+    DEFINEFUNC(size_t, SSL_get_peer_finished, (const GO_SSL_PTR s, void *buf, size_t count), (s, buf, count))                                                                                                                                               \
+    DEFINEFUNC(void, SSL_set_verify, (GO_SSL_PTR s, int mode, GO_SSL_verify_cb_PTR callback), (s, mode, callback))                                                                                                                                          \
+    DEFINEFUNC(int, SSL_get_verify_mode, (const GO_SSL_PTR s), (s))                                                                                                                                                                                         \
+    DEFINEFUNC_1_1(int, SSL_peek_ex, (GO_SSL_PTR s, void *buf, size_t num, size_t *readbytes), (s, buf, num, readbytes))                                                                                                                                    \
+    DEFINEFUNC_1_1_1(void, SSL_set_post_handshake_auth, (GO_SSL_PTR s, int val), (s, val))                                                                                                                                                                  \
+    DEFINEFUNC_1_1_1(int, SSL_verify_client_post_handshake, (GO_SSL_PTR s), (s))                                                                                                                                                                            \
+    DEFINEFUNC(void, SSL_CTX_set_client_cert_cb, (GO_SSL_CTX_PTR ctx, GO_SSL_client_cert_cb_func cb), (ctx, cb))                                                                                                                                            \
+    DEFINEFUNC(GO_OPENSSL_STACK_PTR, SSL_get_client_CA_list, (const GO_SSL_PTR s), (s))                                                                                                                                                                     \
+    DEFINEFUNC_RENAMED_1_1(int, OPENSSL_sk_num, sk_num, (const GO_OPENSSL_STACK_PTR st), (st))                                                                                                                                                              \
+    DEFINEFUNC_RENAMED_1_1(void *, OPENSSL_sk_value, sk_value, (const GO_OPENSSL_STACK_PTR st, int i), (st, i))                                                                                                                                             \
+    DEFINEFUNC(int, i2d_X509_NAME, (GO_X509_NAME_PTR a, unsigned char **pp), (a, pp))                                                                                                                                                                       \
+    DEFINEFUNC(int, SSL_get_sigalgs, (GO_SSL_PTR s, int idx, int *psign, int *phash, int *psignhash, unsigned char *rsig, unsigned char *rhash), (s, idx, psign, phash, psignhash, rsig, rhash))                                                            \
+    DEFINEFUNC(GO_X509_PTR, d2i_X509, (GO_X509_PTR *a, const unsigned char **pp, long length), (a, pp, length))                                                                                                                                             \
+    DEFINEFUNC(GO_EVP_PKEY_PTR, d2i_AutoPrivateKey, (GO_EVP_PKEY_PTR *a, const unsigned char **pp, long length), (a, pp, length))                                                                                                                           \
+    DEFINEFUNC(int, SSL_use_certificate, (GO_SSL_PTR s, GO_X509_PTR x), (s, x))                                                                                                                                                                             \
+    DEFINEFUNC(int, SSL_use_PrivateKey, (GO_SSL_PTR s, GO_EVP_PKEY_PTR pkey), (s, pkey))                                                                                                                                                                    \
+    DEFINEFUNC(void, SSL_CTX_set_info_callback, (GO_SSL_CTX_PTR ctx, GO_SSL_info_cb_func cb), (ctx, cb))                                                                                                                                                    \
+    DEFINEFUNC_1_1(uint64_t, SSL_set_options, (GO_SSL_PTR s, uint64_t op), (s, op))                                                                                                                                                                         \
+    DEFINEFUNC_1_1_1(int, SSL_CTX_set_tlsext_max_fragment_length, (GO_SSL_CTX_PTR ctx, uint8_t mode), (ctx, mode))                                                                                                                                          \
+    DEFINEFUNC_1_1_1(uint8_t, SSL_SESSION_get_max_fragment_length, (const GO_SSL_SESSION_PTR s), (s))                                                                                                                                                       \
+    DEFINEFUNC(GO_SSL_SESSION_PTR, SSL_get_session, (const GO_SSL_PTR ssl), (ssl))                                                                                                                                                                          \
+    DEFINEFUNC_1_1_1(int, SSL_CTX_set_block_padding, (GO_SSL_CTX_PTR ctx, size_t block_size), (ctx, block_size))                                                                                                                                            \
+    DEFINEFUNC_1_1(void, SSL_CTX_set_default_read_buffer_len, (GO_SSL_CTX_PTR ctx, size_t len), (ctx, len))                                                                                                                                                 \
+    DEFINEFUNC(int, SSL_set_ex_data, (GO_SSL_PTR ssl, int idx, void *data), (ssl, idx, data))                                                                                                                                                               \
+    DEFINEFUNC(void *, SSL_get_ex_data, (const GO_SSL_PTR ssl, int idx), (ssl, idx))                                                                                                                                                                        \
+    DEFINEFUNC(int, SSL_CTX_set_cipher_list, (GO_SSL_CTX_PTR ctx, const char *str), (ctx, str))                                                                                                                                                             \
+    DEFINEFUNC(int, SSL_CTX_set_session_id_context, (GO_SSL_CTX_PTR ctx, const unsigned char *sid_ctx, unsigned int sid_ctx_len), (ctx, sid_ctx, sid_ctx_len))                                                                                              \
+    DEFINEFUNC_1_1_1(int, SSL_CTX_set_ciphersuites, (GO_SSL_CTX_PTR ctx, const char *str), (ctx, str))                                                                                                                                                      \
+    DEFINEFUNC(void, SSL_CTX_set_psk_client_callback, (GO_SSL_CTX_PTR ctx, GO_SSL_psk_client_cb_func cb), (ctx, cb))                                                                                                                                        \
+    DEFINEFUNC(void, SSL_CTX_set_psk_server_callback, (GO_SSL_CTX_PTR ctx, GO_SSL_psk_server_cb_func cb), (ctx, cb))                                                                                                                                        \
+    DEFINEFUNC_1_1_1(void, SSL_CTX_set_psk_use_session_callback, (GO_SSL_CTX_PTR ctx, GO_SSL_psk_use_session_cb_func cb), (ctx, cb))                                                                                                                        \
+    DEFINEFUNC_1_1_1(void, SSL_CTX_set_psk_find_session_callback, (GO_SSL_CTX_PTR ctx, GO_SSL_psk_find_session_cb_func cb), (ctx, cb))                                                                                                                      \
+    DEFINEFUNC(GO_SSL_SESSION_PTR, SSL_SESSION_new, (void), ())                                                                                                                                                                                             \
+    DEFINEFUNC_1_1_1(int, SSL_SESSION_set1_master_key, (GO_SSL_SESSION_PTR sess, const unsigned char *in, size_t len), (sess, in, len))                                                                                                                     \
+    DEFINEFUNC_1_1_1(int, SSL_SESSION_set_cipher, (GO_SSL_SESSION_PTR s, const GO_SSL_CIPHER_PTR cipher), (s, cipher))                                                                                                                                      \
+    DEFINEFUNC_1_1_1(int, SSL_SESSION_set_protocol_version, (GO_SSL_SESSION_PTR s, int version), (s, version))                                                                                                                                              \
+    DEFINEFUNC(GO_SSL_CIPHER_PTR, SSL_CIPHER_find, (GO_SSL_PTR ssl, const unsigned char *ptr), (ssl, ptr))                                                                                                                                                  \
+    DEFINEFUNC_1_1_1(GO_EVP_MD_PTR, SSL_CIPHER_get_handshake_digest, (const GO_SSL_CIPHER_PTR c), (c))                                                                                                                                                      \
+    DEFINEFUNC_RENAMED_3_0(int, EVP_MD_get_type, EVP_MD_type, (const GO_EVP_MD_PTR md), (md))                                                                                                                                                               \
+    DEFINEFUNC(void, OPENSSL_cleanse, (void *ptr, size_t len), (ptr, len))                                                                                                                                                                                  \
+    DEFINEFUNC_1_1(int, SSL_CTX_dane_enable, (GO_SSL_CTX_PTR ctx), (ctx))                                                                                                                                                                                   \
+    DEFINEFUNC_1_1(int, SSL_dane_enable, (GO_SSL_PTR s, const char *basedomain), (s, basedomain))                                                                                                                                                           \
+    DEFINEFUNC(GO_EVP_PKEY_PTR, d2i_PUBKEY, (GO_EVP_PKEY_PTR *a, const unsigned char **pp, long length), (a, pp, length))                                                                                                                                   \
+    DEFINEFUNC(int, i2d_PUBKEY, (const GO_EVP_PKEY_PTR a, unsigned char **pp), (a, pp))                                                                                                                                                                     \
+    DEFINEFUNC(void, EVP_PKEY_free, (GO_EVP_PKEY_PTR pkey), (pkey))                                                                                                                                                                                         \
+    DEFINEFUNC_3_2(int, SSL_CTX_set1_client_cert_type, (GO_SSL_CTX_PTR ctx, const unsigned char *val, size_t len), (ctx, val, len))                                                                                                                         \
+    DEFINEFUNC_3_2(int, SSL_CTX_set1_server_cert_type, (GO_SSL_CTX_PTR ctx, const unsigned char *val, size_t len), (ctx, val, len))                                                                                                                         \
+    DEFINEFUNC_3_2(int, SSL_add_expected_rpk, (GO_SSL_PTR s, GO_EVP_PKEY_PTR rpk), (s, rpk))                                                                                                                                                                \
+    DEFINEFUNC_3_2(GO_EVP_PKEY_PTR, SSL_get0_peer_rpk, (const GO_SSL_PTR s), (s))                                                                                                                                                                           \
+    DEFINEFUNC(int, SSL_is_server, (const GO_SSL_PTR s), (s))                                                                                                                                                                                               \
+    DEFINEFUNC_3_2(int, SSL_CTX_set1_cert_comp_preference, (GO_SSL_CTX_PTR ctx, int *algs, size_t len), (ctx, algs, len))                                                                                                                                   \
+    DEFINEFUNC_3_2(int, SSL_get_negotiated_client_cert_comp, (GO_SSL_PTR s), (s))                                                                                                                                                                           \
+    DEFINEFUNC_3_2(int, SSL_get_negotiated_server_cert_comp, (GO_SSL_PTR s), (s))                                                                                                                                                                           \
     DEFINEFUNC_1_1(int, BIO_lookup_ex, (const char *host, const char *service, int lookup_type, int family, int socktype, int protocol, GO_BIO_ADDRINFO_PTR res), (host, service, lookup_type, family, socktype, protocol, res))                            \
     DEFINEFUNC_1_1(GO_BIO_ADDRINFO_PTR, BIO_ADDRINFO_next, (const GO_BIO_ADDRINFO_PTR ai), (ai))                                                                                                                                                            \
     DEFINEFUNC_1_1(int, BIO_socket, (int family, int socktype, int protocol, int options), (family, socktype, protocol, options))                                                                                                                           \
@@ -385,6 +584,21 @@ typedef void *GO_BIO_METHOD_PTR;
     DEFINEFUNC_1_1(void, BIO_ADDRINFO_free, (GO_BIO_ADDRINFO_PTR ai), (ai))                                                                                                                                                                                 \
     DEFINEFUNC_1_1(GO_BIO_PTR, BIO_new, (const GO_BIO_METHOD_PTR type), (type))                                                                                                                                                                             \
     DEFINEFUNC_1_1(GO_BIO_METHOD_PTR, BIO_s_socket, (void), ())                                                                                                                                                                                             \
+    DEFINEFUNC_1_1(GO_BIO_METHOD_PTR, BIO_s_mem, (void), ())                                                                                                                                                                                                \
+    DEFINEFUNC(int, BIO_read, (GO_BIO_PTR b, void *data, int dlen), (b, data, dlen))                                                                                                                                                                        \
+    DEFINEFUNC(int, BIO_write, (GO_BIO_PTR b, const void *data, int dlen), (b, data, dlen))                                                                                                                                                                 \
+    DEFINEFUNC_1_1(int, BIO_get_new_index, (void), ())                                                                                                                                                                                                      \
+    DEFINEFUNC_1_1(GO_BIO_METHOD_PTR, BIO_meth_new, (int type, const char *name), (type, name))                                                                                                                                                             \
+    DEFINEFUNC_1_1(void, BIO_meth_free, (GO_BIO_METHOD_PTR biom), (biom))                                                                                                                                                                                   \
+    DEFINEFUNC_1_1(int, BIO_meth_set_write, (GO_BIO_METHOD_PTR biom, GO_BIO_write_func write), (biom, write))                                                                                                                                               \
+    DEFINEFUNC_1_1(int, BIO_meth_set_read, (GO_BIO_METHOD_PTR biom, GO_BIO_read_func read), (biom, read))                                                                                                                                                   \
+    DEFINEFUNC_1_1(int, BIO_meth_set_ctrl, (GO_BIO_METHOD_PTR biom, GO_BIO_ctrl_func ctrl), (biom, ctrl))                                                                                                                                                   \
+    DEFINEFUNC_1_1(int, BIO_meth_set_destroy, (GO_BIO_METHOD_PTR biom, GO_BIO_destroy_func destroy), (biom, destroy))                                                                                                                                       \
+    DEFINEFUNC_1_1(void, BIO_set_data, (GO_BIO_PTR a, void *ptr), (a, ptr))                                                                                                                                                                                 \
+    DEFINEFUNC_1_1(void *, BIO_get_data, (GO_BIO_PTR a), (a))                                                                                                                                                                                               \
+    DEFINEFUNC_1_1(void, BIO_set_init, (GO_BIO_PTR a, int init), (a, init))                                                                                                                                                                                 \
+    DEFINEFUNC_1_1(void, BIO_set_flags, (GO_BIO_PTR b, int flags), (b, flags))                                                                                                                                                                              \
+    DEFINEFUNC_1_1(void, BIO_clear_flags, (GO_BIO_PTR b, int flags), (b, flags))                                                                                                                                                                            \
     DEFINEFUNC(long, BIO_int_ctrl, (GO_BIO_PTR bp, int cmd, long larg, int iarg), (bp, cmd, larg, iarg))                                                                                                                                                    \
     DEFINEFUNC(long, BIO_ctrl, (GO_BIO_PTR bp, int cmd, long larg, void *parg), (bp, cmd, larg, parg))                                                                                                                                                      \
     DEFINEFUNC(void, BIO_free_all, (GO_BIO_PTR a), (a))
@@ -411,6 +625,8 @@ typedef void *GO_BIO_METHOD_PTR;
     DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_3_0(ret, func, args, argscall) \
     DEFINEFUNC(ret, func, args, argscall)
+#define DEFINEFUNC_3_2(ret, func, args, argscall) \
+    DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_RENAMED_1_1(ret, func, oldfunc, args, argscall) \
     DEFINEFUNC(ret, func, args, argscall)
 #define DEFINEFUNC_RENAMED_3_0(ret, func, oldfunc, args, argscall) \
@@ -425,5 +641,6 @@ FOR_ALL_LIBSSL_FUNCTIONS
 #undef DEFINEFUNC_1_1
 #undef DEFINEFUNC_1_1_1
 #undef DEFINEFUNC_3_0
+#undef DEFINEFUNC_3_2
 #undef DEFINEFUNC_RENAMED_1_1
 #undef DEFINEFUNC_RENAMED_3_0
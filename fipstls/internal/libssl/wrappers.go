@@ -4,6 +4,7 @@ package libssl
 import "C"
 import (
 	"fmt"
+	"runtime"
 	"unsafe"
 )
 
@@ -99,6 +100,17 @@ func SSLCtxFree(sslCtx *SSLCtx) error {
 	return nil
 }
 
+// SSLCtxUpRef takes a reference to sslCtx, which is released by [SSLCtxFree].
+func SSLCtxUpRef(sslCtx *SSLCtx) error {
+	if sslCtx == nil {
+		return NewOpenSSLError("libssl: SSL_CTX_up_ref: SSL_CTX is nil")
+	}
+	if C.go_openssl_SSL_CTX_up_ref(sslCtx.inner) != 1 {
+		return NewOpenSSLError("libssl: SSL_CTX_up_ref")
+	}
+	return nil
+}
+
 func SSLCtxConfigure(ctx *SSLCtx, config *CtxConfig) error {
 	cNextProto := C.CString(config.NextProto)
 	cCaPath := C.CString(config.CaPath)
@@ -116,13 +128,198 @@ func SSLCtxConfigure(ctx *SSLCtx, config *CtxConfig) error {
 	); r != 0 {
 		return NewOpenSSLError("libssl: ctx_configure failed")
 	}
+	// SSL_CTRL_OPTIONS is ignored since OpenSSL 1.1.0, where SSL_CTX_set_options is a function.
+	if config.Options != 0 && versionAtOrAbove(1, 1, 0) {
+		C.go_openssl_SSL_CTX_set_options(ctx.inner, C.uint64_t(config.Options))
+	}
+	if config.KTLS && versionAtOrAbove(3, 0, 0) {
+		C.go_openssl_SSL_CTX_set_options(ctx.inner, C.GO_SSL_OP_ENABLE_KTLS)
+	}
+	if config.CipherList != "" {
+		cCipherList := C.CString(config.CipherList)
+		defer C.free(unsafe.Pointer(cCipherList))
+		if C.go_openssl_SSL_CTX_set_cipher_list(ctx.inner, cCipherList) != 1 {
+			return NewOpenSSLError("libssl: SSL_CTX_set_cipher_list")
+		}
+	}
+	if config.CipherSuites != "" && versionAtOrAbove(1, 1, 1) {
+		cCipherSuites := C.CString(config.CipherSuites)
+		defer C.free(unsafe.Pointer(cCipherSuites))
+		if C.go_openssl_SSL_CTX_set_ciphersuites(ctx.inner, cCipherSuites) != 1 {
+			return NewOpenSSLError("libssl: SSL_CTX_set_ciphersuites")
+		}
+	}
+	if config.SessionIDContext != "" {
+		sidCtx := []byte(config.SessionIDContext)
+		if C.go_openssl_SSL_CTX_set_session_id_context(ctx.inner,
+			(*C.uchar)(unsafe.Pointer(&sidCtx[0])), C.uint(len(sidCtx))) != 1 {
+			return NewOpenSSLError("libssl: SSL_CTX_set_session_id_context")
+		}
+	}
+	if len(config.ClientCertTypes) > 0 || len(config.ServerCertTypes) > 0 || config.ExpectedRPKs {
+		if err := ctxConfigureRPK(ctx, config); err != nil {
+			return err
+		}
+	}
+	if len(config.CertComp) > 0 {
+		algs := make([]C.int, len(config.CertComp))
+		for i, alg := range config.CertComp {
+			algs[i] = C.int(alg)
+		}
+		if C.go_openssl_ctx_configure_cert_comp(ctx.inner, &algs[0], C.size_t(len(algs)),
+			boolToInt(SupportsCertCompression()), C.int(int(debugLogging))) != 0 {
+			return NewOpenSSLError("libssl: SSL_CTX_set1_cert_comp_preference")
+		}
+	}
+	if err := ctxConfigureRecordLayer(ctx, config); err != nil {
+		return err
+	}
+	if err := ctxConfigureBuffers(ctx, config); err != nil {
+		return err
+	}
+	if config.ClientCert {
+		C.go_openssl_ctx_configure_client_cert(ctx.inner, C.int(int(debugLogging)))
+	}
+	if config.HandshakeStart {
+		C.go_openssl_ctx_configure_info_cb(ctx.inner, C.int(int(debugLogging)))
+	}
+	if config.PSKClient || config.PSKServer {
+		C.go_openssl_ctx_configure_psk(ctx.inner, boolToInt(config.PSKClient),
+			boolToInt(config.PSKServer), boolToInt(versionAtOrAbove(1, 1, 1)),
+			C.int(int(debugLogging)))
+	}
 	return nil
 }
 
+// maxFragmentLengthModes maps the RFC 6066 max_fragment_length sizes to their code points.
+var maxFragmentLengthModes = map[int]C.uint8_t{
+	512:  C.GO_TLSEXT_max_fragment_length_512,
+	1024: C.GO_TLSEXT_max_fragment_length_1024,
+	2048: C.GO_TLSEXT_max_fragment_length_2048,
+	4096: C.GO_TLSEXT_max_fragment_length_4096,
+}
+
+func ctxConfigureBuffers(ctx *SSLCtx, config *CtxConfig) error {
+	if config.Mode != 0 {
+		C.go_openssl_SSL_CTX_ctrl(ctx.inner, SSL_CTRL_MODE, C.long(config.Mode), nil)
+	}
+	if config.ReadAhead {
+		C.go_openssl_SSL_CTX_ctrl(ctx.inner, SSL_CTRL_SET_READ_AHEAD, 1, nil)
+	}
+	if config.ReadBufferLen != 0 {
+		if !versionAtOrAbove(1, 1, 0) {
+			return errUnsupportedVersion()
+		}
+		C.go_openssl_SSL_CTX_set_default_read_buffer_len(ctx.inner, C.size_t(config.ReadBufferLen))
+	}
+	if config.MaxEarlyData != 0 {
+		if !versionAtOrAbove(1, 1, 1) {
+			return errUnsupportedVersion()
+		}
+		// The limit advertised in session tickets and the one enforced on receipt must match,
+		// the latter defaults to 16384 bytes.
+		if C.go_openssl_SSL_CTX_set_max_early_data(ctx.inner, C.uint32_t(config.MaxEarlyData)) != 1 ||
+			C.go_openssl_SSL_CTX_set_recv_max_early_data(ctx.inner,
+				C.uint32_t(config.MaxEarlyData)) != 1 {
+			return NewOpenSSLError("libssl: SSL_CTX_set_max_early_data")
+		}
+	}
+	return nil
+}
+
+func ctxConfigureRecordLayer(ctx *SSLCtx, config *CtxConfig) error {
+	if config.MaxSendFragment != 0 && C.go_openssl_SSL_CTX_ctrl(ctx.inner,
+		SSL_CTRL_SET_MAX_SEND_FRAGMENT, C.long(config.MaxSendFragment), nil) != 1 {
+		return NewOpenSSLError("libssl: SSL_CTX_set_max_send_fragment")
+	}
+	if config.SplitSendFragment != 0 && C.go_openssl_SSL_CTX_ctrl(ctx.inner,
+		SSL_CTRL_SET_SPLIT_SEND_FRAGMENT, C.long(config.SplitSendFragment), nil) != 1 {
+		return NewOpenSSLError("libssl: SSL_CTX_set_split_send_fragment")
+	}
+	if config.MaxFragmentLength == 0 && config.BlockPadding == 0 {
+		return nil
+	}
+	if !versionAtOrAbove(1, 1, 1) {
+		return errUnsupportedVersion()
+	}
+	if config.MaxFragmentLength != 0 {
+		mode, ok := maxFragmentLengthModes[config.MaxFragmentLength]
+		if !ok || C.go_openssl_SSL_CTX_set_tlsext_max_fragment_length(ctx.inner, mode) != 1 {
+			return NewOpenSSLError("libssl: SSL_CTX_set_tlsext_max_fragment_length")
+		}
+	}
+	if config.BlockPadding != 0 &&
+		C.go_openssl_SSL_CTX_set_block_padding(ctx.inner, C.size_t(config.BlockPadding)) != 1 {
+		return NewOpenSSLError("libssl: SSL_CTX_set_block_padding")
+	}
+	return nil
+}
+
+// SSLGetMaxFragmentLength returns the RFC 6066 max_fragment_length in bytes negotiated on ssl,
+// or zero if none was negotiated.
+func SSLGetMaxFragmentLength(ssl *SSL) int {
+	if ssl == nil || !versionAtOrAbove(1, 1, 1) {
+		return 0
+	}
+	sess := C.go_openssl_SSL_get_session(ssl.inner)
+	if sess == nil {
+		return 0
+	}
+	mode := C.go_openssl_SSL_SESSION_get_max_fragment_length(sess)
+	for size, m := range maxFragmentLengthModes {
+		if m == mode {
+			return size
+		}
+	}
+	return 0
+}
+
+// SupportsRPK returns true if the loaded libssl supports RFC 7250 raw public keys.
+func SupportsRPK() bool {
+	return versionAtOrAbove(3, 2, 0)
+}
+
+// SupportsCertCompression returns true if the loaded libssl supports RFC 8879 certificate
+// compression.
+func SupportsCertCompression() bool {
+	return versionAtOrAbove(3, 2, 0)
+}
+
+func ctxConfigureRPK(ctx *SSLCtx, config *CtxConfig) error {
+	if !SupportsRPK() {
+		return errUnsupportedVersion()
+	}
+	clientTypes, serverTypes := config.ClientCertTypes, config.ServerCertTypes
+	if len(clientTypes) > 0 && C.go_openssl_SSL_CTX_set1_client_cert_type(ctx.inner,
+		(*C.uchar)(unsafe.Pointer(&clientTypes[0])), C.size_t(len(clientTypes))) != 1 {
+		return NewOpenSSLError("libssl: SSL_CTX_set1_client_cert_type")
+	}
+	if len(serverTypes) > 0 && C.go_openssl_SSL_CTX_set1_server_cert_type(ctx.inner,
+		(*C.uchar)(unsafe.Pointer(&serverTypes[0])), C.size_t(len(serverTypes))) != 1 {
+		return NewOpenSSLError("libssl: SSL_CTX_set1_server_cert_type")
+	}
+	// Expected raw public keys are matched as DANE-EE TLSA records
+	if config.ExpectedRPKs && C.go_openssl_SSL_CTX_dane_enable(ctx.inner) <= 0 {
+		return NewOpenSSLError("libssl: SSL_CTX_dane_enable")
+	}
+	return nil
+}
+
+func boolToInt(b bool) C.int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
 // SSL holds data for a TLS connection. It inherits the settings of the underlying context ctx:
 // connection method, options, verification settings, timeout settings.
 type SSL struct {
 	inner C.GO_SSL_PTR
+	// readPinner and writePinner pin the Go buffers passed to libssl by reads and writes. They
+	// are reused so that pinning does not allocate.
+	readPinner  runtime.Pinner
+	writePinner runtime.Pinner
 }
 
 func NewSSL(sslCtx *SSLCtx) (*SSL, error) {
@@ -140,6 +337,7 @@ func SSLFree(ssl *SSL) error {
 	if ssl == nil {
 		return NewOpenSSLError("libssl: SSL_clear: SSL is nil")
 	}
+	freeCallbacks(ssl)
 	C.go_openssl_SSL_free(ssl.inner)
 	return nil
 }
@@ -154,25 +352,64 @@ func SSLConnect(ssl *SSL) error {
 	return nil
 }
 
+// SSLDoHandshake continues the handshake, or sends pending post-handshake messages such as a
+// KeyUpdate once the handshake is complete.
+func SSLDoHandshake(ssl *SSL) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_do_handshake: SSL is nil")
+	}
+	if r := C.go_openssl_SSL_do_handshake(ssl.inner); r != 1 {
+		return newSSLError("libssl: SSL_do_handshake", SSLGetError(ssl, int(r)))
+	}
+	return nil
+}
+
+// SSLKeyUpdate schedules a TLS 1.3 KeyUpdate message updating the sending keys of ssl, which is
+// sent on the next write or [SSLDoHandshake]. If requestPeer is true the peer is asked to update
+// its sending keys too.
+func SSLKeyUpdate(ssl *SSL, requestPeer bool) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_key_update: SSL is nil")
+	}
+	if !versionAtOrAbove(1, 1, 1) {
+		return errUnsupportedVersion()
+	}
+	updateType := C.int(SSL_KEY_UPDATE_NOT_REQUESTED)
+	if requestPeer {
+		updateType = SSL_KEY_UPDATE_REQUESTED
+	}
+	if C.go_openssl_SSL_key_update(ssl.inner, updateType) != 1 {
+		return NewOpenSSLError("libssl: SSL_key_update")
+	}
+	return nil
+}
+
+// SSLRenegotiate schedules a TLS 1.2 renegotiation of ssl, which starts on the next
+// [SSLDoHandshake]. A server sends a HelloRequest, and the handshake runs when the client
+// answers it.
+func SSLRenegotiate(ssl *SSL) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_renegotiate: SSL is nil")
+	}
+	if C.go_openssl_SSL_renegotiate(ssl.inner) != 1 {
+		return NewOpenSSLError("libssl: SSL_renegotiate")
+	}
+	return nil
+}
+
 // SSLShutdown closes an active TLS/SSL connection. It sends the "close notify" shutdown alert to
-// the peer.
+// the peer, without waiting for the peer's. Calling SSL_shutdown again to wait for it would
+// fail on application data still to be read, so the peer's alert is read with SSL_read, which
+// fails with SSL_ERROR_ZERO_RETURN once it arrives.
 func SSLShutdown(ssl *SSL) error {
 	if ssl == nil {
 		return NewOpenSSLError("libssl: SSL_shutdown: SSL is nil")
 	}
 	r := int(C.go_openssl_SSL_shutdown(ssl.inner))
-	switch r {
-	case 1:
-		return nil
-	case 0:
-		// Bidirectional shutdown must be performed. SSL_shutdown will need to be called again.
-		if r := int(C.go_openssl_SSL_shutdown(ssl.inner)); r < 0 {
-			return newSSLError("libssl: SSL_shutdown", SSLGetError(ssl, r))
-		}
-		return nil
-	default:
+	if r < 0 {
 		return newSSLError("libssl: SSL_shutdown", SSLGetError(ssl, r))
 	}
+	return nil
 }
 
 // SSLGetShutdown returns the shutdown mode of [Conn].
@@ -183,6 +420,22 @@ func SSLGetShutdown(ssl *SSL) int {
 	return int(C.go_openssl_SSL_get_shutdown(ssl.inner))
 }
 
+// SSLUnexpectedEOF reports whether an SSL operation that failed with the SSL_get_error code
+// failed because the transport was closed without a close_notify alert. It inspects the error
+// queue, so it must be called before the queue is drained or cleared. OpenSSL 1.x reports the
+// EOF as SSL_ERROR_SYSCALL with no error queued, so the caller must rule out system call errors.
+func SSLUnexpectedEOF(code int) bool {
+	e := uint64(C.go_openssl_ERR_peek_last_error())
+	if vMajor == 1 {
+		return code == SSL_ERROR_SYSCALL && e == 0
+	}
+	// OpenSSL 3 packs system errors with the top bit set, and the library in the next 8 bits.
+	if code != SSL_ERROR_SSL || e&0x80000000 != 0 {
+		return false
+	}
+	return (e>>23)&0xff == C.GO_ERR_LIB_SSL && e&0x7fffff == C.GO_SSL_R_UNEXPECTED_EOF_WHILE_READING
+}
+
 // SSLSetShutdown sets the shutdown state of [Conn] to mode.
 func SSLSetShutdown(ssl *SSL, mode int) error {
 	if ssl == nil {
@@ -192,17 +445,19 @@ func SSLSetShutdown(ssl *SSL, mode int) error {
 	return nil
 }
 
-func SSLWriteEx(ssl *SSL, req []byte) (int, error) {
+// SSLWriteEx writes b to ssl. The buffer of b is passed to libssl without copying, and stays
+// pinned while libssl may call back into Go to write records to a Go BIO.
+func SSLWriteEx(ssl *SSL, b []byte) (int, error) {
 	if ssl == nil {
 		return 0, NewOpenSSLError("libssl: SSL_write_ex: SSL is nil")
 	}
-	cBytes := C.CBytes(req)
-	defer C.free(cBytes)
+	buf := pinBuffer(&ssl.writePinner, b)
+	defer ssl.writePinner.Unpin()
 	var written C.size_t
 	r := C.go_openssl_SSL_write_ex(
 		ssl.inner,
-		cBytes,
-		C.size_t(len(req)),
+		buf,
+		C.size_t(len(b)),
 		&written)
 	if r != 1 {
 		return 0, newSSLError("libssl: SSL_write_ex", SSLGetError(ssl, int(r)))
@@ -210,22 +465,458 @@ func SSLWriteEx(ssl *SSL, req []byte) (int, error) {
 	return int(written), nil
 }
 
-func SSLReadEx(ssl *SSL, size int64) ([]byte, int, error) {
+// SSLReadEx reads decrypted application data from ssl into b. Like [SSLWriteEx], the buffer of
+// b is passed to libssl without copying.
+func SSLReadEx(ssl *SSL, b []byte) (int, error) {
 	if ssl == nil {
-		return nil, 0, NewOpenSSLError("libssl: SSL_read_ex: SSL is nil")
+		return 0, NewOpenSSLError("libssl: SSL_read_ex: SSL is nil")
 	}
-	cBuf := C.malloc(C.size_t(size))
-	defer C.free(cBuf)
+	buf := pinBuffer(&ssl.readPinner, b)
+	defer ssl.readPinner.Unpin()
 	var readBytes C.size_t
 	r := C.go_openssl_SSL_read_ex(
 		ssl.inner,
-		cBuf,
-		C.size_t(size),
+		buf,
+		C.size_t(len(b)),
 		&readBytes)
 	if r != 1 {
-		return nil, 0, newSSLError("libssl: SSL_read_ex", SSLGetError(ssl, int(r)))
+		return 0, newSSLError("libssl: SSL_read_ex", SSLGetError(ssl, int(r)))
+	}
+	return int(readBytes), nil
+}
+
+// pinBuffer pins the buffer of b with pinner, and returns it.
+func pinBuffer(pinner *runtime.Pinner, b []byte) unsafe.Pointer {
+	if len(b) == 0 {
+		return nil
 	}
-	return C.GoBytes(cBuf, C.int(readBytes)), int(readBytes), nil
+	buf := unsafe.Pointer(&b[0])
+	pinner.Pin(buf)
+	return buf
+}
+
+// SSLPeek processes incoming records without consuming application data. It returns the number
+// of application data bytes that are ready to be read, up to 1.
+func SSLPeek(ssl *SSL) (int, error) {
+	if ssl == nil {
+		return 0, NewOpenSSLError("libssl: SSL_peek_ex: SSL is nil")
+	}
+	var b [1]byte
+	var readBytes C.size_t
+	if r := C.go_openssl_SSL_peek_ex(ssl.inner, unsafe.Pointer(&b[0]), 1, &readBytes); r != 1 {
+		return 0, newSSLError("libssl: SSL_peek_ex", SSLGetError(ssl, int(r)))
+	}
+	return int(readBytes), nil
+}
+
+// SSLSetOptions adds options to ssl.
+func SSLSetOptions(ssl *SSL, options int64) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_set_options: SSL is nil")
+	}
+	if !versionAtOrAbove(1, 1, 0) {
+		return errUnsupportedVersion()
+	}
+	C.go_openssl_SSL_set_options(ssl.inner, C.uint64_t(options))
+	return nil
+}
+
+// SSLSetMode adds mode, a combination of the SSL_MODE_* flags, to the modes of ssl.
+func SSLSetMode(ssl *SSL, mode int64) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_set_mode: SSL is nil")
+	}
+	C.go_openssl_SSL_ctrl(ssl.inner, SSL_CTRL_MODE, C.long(mode), nil)
+	return nil
+}
+
+// SSLSetPostHandshakeAuth lets a TLS 1.3 client answer CertificateRequests sent by the server
+// after the handshake. It must be called before the handshake.
+func SSLSetPostHandshakeAuth(ssl *SSL) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_set_post_handshake_auth: SSL is nil")
+	}
+	if !versionAtOrAbove(1, 1, 1) {
+		return errUnsupportedVersion()
+	}
+	C.go_openssl_SSL_set_post_handshake_auth(ssl.inner, 1)
+	return nil
+}
+
+// SSLVerifyClientPostHandshake schedules a TLS 1.3 CertificateRequest to the client, which is
+// sent on the next write or [SSLDoHandshake]. The client certificate is required and verified
+// when the client answers. It fails if the client did not enable post-handshake authentication.
+func SSLVerifyClientPostHandshake(ssl *SSL) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_verify_client_post_handshake: SSL is nil")
+	}
+	if !versionAtOrAbove(1, 1, 1) {
+		return errUnsupportedVersion()
+	}
+	mode := C.go_openssl_SSL_get_verify_mode(ssl.inner)
+	C.go_openssl_SSL_set_verify(ssl.inner, mode|SSL_VERIFY_PEER|SSL_VERIFY_FAIL_IF_NO_PEER_CERT,
+		nil)
+	if C.go_openssl_SSL_verify_client_post_handshake(ssl.inner) != 1 {
+		return NewOpenSSLError("libssl: SSL_verify_client_post_handshake")
+	}
+	return nil
+}
+
+// SSLWriteEarlyData writes req as TLS 1.3 early data. It must be called on a client before the
+// handshake completes and with a session set that permits early data.
+func SSLWriteEarlyData(ssl *SSL, req []byte) (int, error) {
+	if ssl == nil {
+		return 0, NewOpenSSLError("libssl: SSL_write_early_data: SSL is nil")
+	}
+	if !versionAtOrAbove(1, 1, 1) {
+		return 0, errUnsupportedVersion()
+	}
+	buf := pinBuffer(&ssl.writePinner, req)
+	defer ssl.writePinner.Unpin()
+	var written C.size_t
+	r := C.go_openssl_SSL_write_early_data(
+		ssl.inner,
+		buf,
+		C.size_t(len(req)),
+		&written)
+	if r != 1 {
+		return 0, newSSLError("libssl: SSL_write_early_data", SSLGetError(ssl, int(r)))
+	}
+	return int(written), nil
+}
+
+// SSLReadEarlyData reads TLS 1.3 early data into b. It must be called on a server before the
+// handshake starts, until it returns finished, after which the handshake continues with
+// [SSLDoHandshake]. Early data that is rejected is skipped, and finished is returned without
+// reading any.
+func SSLReadEarlyData(ssl *SSL, b []byte) (n int, finished bool, err error) {
+	if ssl == nil {
+		return 0, false, NewOpenSSLError("libssl: SSL_read_early_data: SSL is nil")
+	}
+	if !versionAtOrAbove(1, 1, 1) {
+		return 0, false, errUnsupportedVersion()
+	}
+	buf := pinBuffer(&ssl.readPinner, b)
+	defer ssl.readPinner.Unpin()
+	var readBytes C.size_t
+	r := C.go_openssl_SSL_read_early_data(
+		ssl.inner,
+		buf,
+		C.size_t(len(b)),
+		&readBytes)
+	switch r {
+	case SSL_READ_EARLY_DATA_SUCCESS:
+		return int(readBytes), false, nil
+	case SSL_READ_EARLY_DATA_FINISH:
+		return int(readBytes), true, nil
+	}
+	return 0, false, newSSLError("libssl: SSL_read_early_data", SSLGetError(ssl, int(r)))
+}
+
+// SSLGetEarlyDataStatus returns whether early data was accepted by the server. It returns one
+// of SSL_EARLY_DATA_NOT_SENT, SSL_EARLY_DATA_REJECTED or SSL_EARLY_DATA_ACCEPTED.
+func SSLGetEarlyDataStatus(ssl *SSL) int {
+	if ssl == nil || !versionAtOrAbove(1, 1, 1) {
+		return SSL_EARLY_DATA_NOT_SENT
+	}
+	return int(C.go_openssl_SSL_get_early_data_status(ssl.inner))
+}
+
+// SSLVersion returns the protocol version used by the connection, e.g. TLS1_3_VERSION.
+func SSLVersion(ssl *SSL) int {
+	if ssl == nil {
+		return 0
+	}
+	return int(C.go_openssl_SSL_version(ssl.inner))
+}
+
+// SSLSessionReused returns true if a session was successfully reused during the handshake.
+func SSLSessionReused(ssl *SSL) bool {
+	if ssl == nil {
+		return false
+	}
+	return C.go_openssl_SSL_session_reused(ssl.inner) == 1
+}
+
+// SSLGetALPNSelected returns the protocol selected by ALPN, or an empty string if none was
+// selected.
+func SSLGetALPNSelected(ssl *SSL) string {
+	if ssl == nil {
+		return ""
+	}
+	var proto *C.uchar
+	var length C.uint
+	C.go_openssl_SSL_get0_alpn_selected(ssl.inner, &proto, &length)
+	if proto == nil || length == 0 {
+		return ""
+	}
+	return string(C.GoBytes(unsafe.Pointer(proto), C.int(length)))
+}
+
+// SSLSession holds the negotiated parameters of a TLS connection that can be used to resume it.
+type SSLSession struct {
+	inner C.GO_SSL_SESSION_PTR
+}
+
+// SSLGet1Session returns the session currently used by ssl. The reference count is incremented
+// and the session must be freed with [SSLSessionFree].
+func SSLGet1Session(ssl *SSL) (*SSLSession, error) {
+	if ssl == nil {
+		return nil, NewOpenSSLError("libssl: SSL_get1_session: SSL is nil")
+	}
+	r := C.go_openssl_SSL_get1_session(ssl.inner)
+	if r == nil {
+		return nil, NewOpenSSLError("libssl: SSL_get1_session: no session available")
+	}
+	return &SSLSession{inner: r}, nil
+}
+
+// SSLSetSession sets the session to be used when the connection is established.
+func SSLSetSession(ssl *SSL, session *SSLSession) error {
+	if ssl == nil || session == nil {
+		return NewOpenSSLError("libssl: SSL_set_session: SSL or SSL_SESSION is nil")
+	}
+	if C.go_openssl_SSL_set_session(ssl.inner, session.inner) != 1 {
+		return NewOpenSSLError("libssl: SSL_set_session")
+	}
+	return nil
+}
+
+func SSLSessionFree(session *SSLSession) error {
+	if session == nil {
+		return NewOpenSSLError("libssl: SSL_SESSION_free: SSL_SESSION is nil")
+	}
+	C.go_openssl_SSL_SESSION_free(session.inner)
+	return nil
+}
+
+// SSLSessionIsResumable returns true if the session can be used to resume a connection.
+func SSLSessionIsResumable(session *SSLSession) bool {
+	if session == nil || !versionAtOrAbove(1, 1, 1) {
+		return false
+	}
+	return C.go_openssl_SSL_SESSION_is_resumable(session.inner) == 1
+}
+
+// SSLSessionGetMaxEarlyData returns the maximum amount of early data that may be sent when
+// resuming the session. Zero means early data is not permitted.
+func SSLSessionGetMaxEarlyData(session *SSLSession) uint32 {
+	if session == nil || !versionAtOrAbove(1, 1, 1) {
+		return 0
+	}
+	return uint32(C.go_openssl_SSL_SESSION_get_max_early_data(session.inner))
+}
+
+// I2DSSLSession serializes the session to its ASN.1 DER representation.
+func I2DSSLSession(session *SSLSession) ([]byte, error) {
+	if session == nil {
+		return nil, NewOpenSSLError("libssl: i2d_SSL_SESSION: SSL_SESSION is nil")
+	}
+	n := C.go_openssl_i2d_SSL_SESSION(session.inner, nil)
+	if n <= 0 {
+		return nil, NewOpenSSLError("libssl: i2d_SSL_SESSION")
+	}
+	cBuf := C.malloc(C.size_t(n))
+	defer C.free(cBuf)
+	p := (*C.uchar)(cBuf)
+	if C.go_openssl_i2d_SSL_SESSION(session.inner, &p) != n {
+		return nil, NewOpenSSLError("libssl: i2d_SSL_SESSION")
+	}
+	return C.GoBytes(cBuf, n), nil
+}
+
+// D2ISSLSession parses a session from its ASN.1 DER representation. The session must be freed
+// with [SSLSessionFree].
+func D2ISSLSession(der []byte) (*SSLSession, error) {
+	if len(der) == 0 {
+		return nil, NewOpenSSLError("libssl: d2i_SSL_SESSION: empty session")
+	}
+	cBytes := C.CBytes(der)
+	defer C.free(cBytes)
+	p := (*C.uchar)(cBytes)
+	r := C.go_openssl_d2i_SSL_SESSION(nil, &p, C.long(len(der)))
+	if r == nil {
+		return nil, NewOpenSSLError("libssl: d2i_SSL_SESSION")
+	}
+	return &SSLSession{inner: r}, nil
+}
+
+// SSLAddExpectedRPKs requires the peer of ssl to authenticate with one of keys, given as DER
+// encoded SubjectPublicKeyInfo. The peer may send the key as a raw public key or in an X.509
+// certificate.
+func SSLAddExpectedRPKs(ssl *SSL, keys [][]byte) error {
+	if ssl == nil {
+		return NewOpenSSLError("libssl: SSL_add_expected_rpk: SSL is nil")
+	}
+	if !SupportsRPK() {
+		return errUnsupportedVersion()
+	}
+	// The basedomain is left empty as the reference identifiers are set by the BIO hostname.
+	if C.go_openssl_SSL_dane_enable(ssl.inner, nil) <= 0 {
+		return NewOpenSSLError("libssl: SSL_dane_enable")
+	}
+	for _, der := range keys {
+		if len(der) == 0 {
+			return NewOpenSSLError("libssl: d2i_PUBKEY: empty public key")
+		}
+		cBytes := C.CBytes(der)
+		p := (*C.uchar)(cBytes)
+		pkey := C.go_openssl_d2i_PUBKEY(nil, &p, C.long(len(der)))
+		C.free(cBytes)
+		if pkey == nil {
+			return NewOpenSSLError("libssl: d2i_PUBKEY")
+		}
+		r := C.go_openssl_SSL_add_expected_rpk(ssl.inner, pkey)
+		C.go_openssl_EVP_PKEY_free(pkey)
+		if r != 1 {
+			return NewOpenSSLError("libssl: SSL_add_expected_rpk")
+		}
+	}
+	return nil
+}
+
+// SSLGetPeerRPK returns the raw public key sent by the peer as DER encoded SubjectPublicKeyInfo,
+// or nil if the peer did not send a raw public key.
+func SSLGetPeerRPK(ssl *SSL) ([]byte, error) {
+	if ssl == nil {
+		return nil, NewOpenSSLError("libssl: SSL_get0_peer_rpk: SSL is nil")
+	}
+	if !SupportsRPK() {
+		return nil, nil
+	}
+	pkey := C.go_openssl_SSL_get0_peer_rpk(ssl.inner)
+	if pkey == nil {
+		return nil, nil
+	}
+	n := C.go_openssl_i2d_PUBKEY(pkey, nil)
+	if n <= 0 {
+		return nil, NewOpenSSLError("libssl: i2d_PUBKEY")
+	}
+	cBuf := C.malloc(C.size_t(n))
+	defer C.free(cBuf)
+	p := (*C.uchar)(cBuf)
+	if C.go_openssl_i2d_PUBKEY(pkey, &p) != n {
+		return nil, NewOpenSSLError("libssl: i2d_PUBKEY")
+	}
+	return C.GoBytes(cBuf, n), nil
+}
+
+// SSLExtmsSupport returns true if the Extended Master Secret extension (RFC 7627) was negotiated
+// by the handshake of ssl.
+func SSLExtmsSupport(ssl *SSL) bool {
+	if ssl == nil {
+		return false
+	}
+	return C.go_openssl_SSL_ctrl(ssl.inner, SSL_CTRL_GET_EXTMS_SUPPORT, 0, nil) == 1
+}
+
+// SSLExportKeyingMaterial derives length bytes of keying material from the master secret of ssl
+// as defined in RFC 5705 and RFC 8446, Section 7.5. A nil context is omitted in TLS 1.2.
+func SSLExportKeyingMaterial(ssl *SSL, label string, context []byte, length int) ([]byte, error) {
+	if ssl == nil {
+		return nil, NewOpenSSLError("libssl: SSL_export_keying_material: SSL is nil")
+	}
+	if length < 0 {
+		return nil, NewOpenSSLError("libssl: SSL_export_keying_material: negative length")
+	}
+	out := make([]byte, length)
+	var cOut *C.uchar
+	if length > 0 {
+		cOut = (*C.uchar)(unsafe.Pointer(&out[0]))
+	}
+	cLabel := C.CString(label)
+	defer C.free(unsafe.Pointer(cLabel))
+	var cContext *C.uchar
+	if len(context) > 0 {
+		cContext = (*C.uchar)(unsafe.Pointer(&context[0]))
+	}
+	if C.go_openssl_SSL_export_keying_material(ssl.inner, cOut, C.size_t(length), cLabel,
+		C.size_t(len(label)), cContext, C.size_t(len(context)), boolToInt(context != nil)) != 1 {
+		return nil, NewOpenSSLError("libssl: SSL_export_keying_material")
+	}
+	return out, nil
+}
+
+// SSLIsServer returns true if ssl is the server side of the connection.
+func SSLIsServer(ssl *SSL) bool {
+	return ssl != nil && C.go_openssl_SSL_is_server(ssl.inner) == 1
+}
+
+// i2dX509 returns the ASN.1 DER encoding of cert.
+func i2dX509(cert C.GO_X509_PTR, fn string) ([]byte, error) {
+	n := C.go_openssl_i2d_X509(cert, nil)
+	if n <= 0 {
+		return nil, NewOpenSSLError("libssl: " + fn + ": i2d_X509")
+	}
+	cBuf := C.malloc(C.size_t(n))
+	defer C.free(cBuf)
+	p := (*C.uchar)(cBuf)
+	if C.go_openssl_i2d_X509(cert, &p) != n {
+		return nil, NewOpenSSLError("libssl: " + fn + ": i2d_X509")
+	}
+	return C.GoBytes(cBuf, n), nil
+}
+
+// SSLGetPeerCertificate returns the DER encoded certificate of the peer, or nil if the peer
+// did not send a certificate.
+func SSLGetPeerCertificate(ssl *SSL) ([]byte, error) {
+	if ssl == nil {
+		return nil, NewOpenSSLError("libssl: SSL_get1_peer_certificate: SSL is nil")
+	}
+	cert := C.go_openssl_SSL_get1_peer_certificate(ssl.inner)
+	if cert == nil {
+		return nil, nil
+	}
+	defer C.go_openssl_X509_free(cert)
+	return i2dX509(cert, "SSL_get1_peer_certificate")
+}
+
+// SSLGetCertificate returns the DER encoded certificate sent to the peer, or nil if none was
+// sent.
+func SSLGetCertificate(ssl *SSL) ([]byte, error) {
+	if ssl == nil {
+		return nil, NewOpenSSLError("libssl: SSL_get_certificate: SSL is nil")
+	}
+	cert := C.go_openssl_SSL_get_certificate(ssl.inner)
+	if cert == nil {
+		return nil, nil
+	}
+	return i2dX509(cert, "SSL_get_certificate")
+}
+
+// maxFinishedLen is larger than the Finished verify_data of any TLS 1.2 cipher suite.
+const maxFinishedLen = 64
+
+// SSLGetFinished returns the latest Finished message sent to the peer.
+func SSLGetFinished(ssl *SSL) []byte {
+	if ssl == nil {
+		return nil
+	}
+	buf := make([]byte, maxFinishedLen)
+	n := C.go_openssl_SSL_get_finished(ssl.inner, unsafe.Pointer(&buf[0]), C.size_t(len(buf)))
+	return buf[:min(int(n), len(buf))]
+}
+
+// SSLGetPeerFinished returns the latest Finished message received from the peer.
+func SSLGetPeerFinished(ssl *SSL) []byte {
+	if ssl == nil {
+		return nil
+	}
+	buf := make([]byte, maxFinishedLen)
+	n := C.go_openssl_SSL_get_peer_finished(ssl.inner, unsafe.Pointer(&buf[0]), C.size_t(len(buf)))
+	return buf[:min(int(n), len(buf))]
+}
+
+// SSLGetPeerCertComp returns the algorithm the peer's certificate was compressed with, or
+// TLSEXT_comp_cert_none if it wasn't compressed.
+func SSLGetPeerCertComp(ssl *SSL) int {
+	if ssl == nil || !versionAtOrAbove(3, 2, 0) {
+		return TLSEXT_comp_cert_none
+	}
+	if SSLIsServer(ssl) {
+		return int(C.go_openssl_SSL_get_negotiated_client_cert_comp(ssl.inner))
+	}
+	return int(C.go_openssl_SSL_get_negotiated_server_cert_comp(ssl.inner))
 }
 
 func SSLGetVerifyResult(ssl *SSL) error {
@@ -254,6 +945,8 @@ func SSLClearError() {
 
 type BIO struct {
 	inner C.GO_BIO_PTR
+	// gobio is set if the BIO was created by NewGoBIO.
+	gobio *goBIO
 }
 
 func CreateBIO(hostname, port string, family, mode int) (*BIO, int, error) {
@@ -276,6 +969,20 @@ func CreateBIO(hostname, port string, family, mode int) (*BIO, int, error) {
 	return &BIO{inner: bio}, int(sockfd), nil
 }
 
+// NewSocketBIO creates a socket [BIO] for the connected socket sock, in blocking (mode=0) or
+// non-blocking (mode=1) mode. The [BIO] closes sock when it is freed. If creating it fails,
+// sock is left open.
+func NewSocketBIO(sock, mode int) (*BIO, error) {
+	if !versionAtOrAbove(1, 1, 0) {
+		return nil, errUnsupportedVersion()
+	}
+	bio := C.go_openssl_socket_bio_new(C.int(sock), C.int(mode), C.int(int(debugLogging)))
+	if bio == nil {
+		return nil, NewOpenSSLError("libssl: socket_bio_new")
+	}
+	return &BIO{inner: bio}, nil
+}
+
 func SSLConfigureBIO(ssl *SSL, bio *BIO, hostname string) error {
 	cHost := C.CString(hostname)
 	defer C.free(unsafe.Pointer(cHost))
@@ -0,0 +1,46 @@
+//go:build linux && cgo
+// +build linux,cgo
+
+package testutils
+
+// #include <malloc.h>
+//
+// #ifdef __GLIBC__
+// static int limit_arenas(void) {
+// 	return mallopt(M_ARENA_MAX, 1);
+// }
+//
+// #pragma GCC diagnostic ignored "-Wdeprecated-declarations"
+// // mallinfo2 requires glibc 2.33, and the fields of mallinfo are large enough for tests.
+// static int heap_in_use(size_t *n) {
+// 	struct mallinfo mi = mallinfo();
+// 	*n = (size_t)(unsigned int)mi.uordblks + (size_t)(unsigned int)mi.hblkhd;
+// 	return 1;
+// }
+// #else
+// // Other C libraries, such as musl, have neither arenas nor mallinfo.
+// static int limit_arenas(void) {
+// 	return 0;
+// }
+//
+// static int heap_in_use(size_t *n) {
+// 	return 0;
+// }
+// #endif
+import "C"
+
+// LimitCHeapArenas limits the C heap to a single arena, since [CHeapInUse] only reports the
+// main arena. It must be called before other threads allocate from the C heap for the
+// measurements to account for them, and returns false if the C heap cannot be measured.
+func LimitCHeapArenas() bool {
+	return C.limit_arenas() == 1
+}
+
+// CHeapInUse returns the bytes of C heap memory in use, and false if it cannot be measured.
+func CHeapInUse() (uint64, bool) {
+	var n C.size_t
+	if C.heap_in_use(&n) == 0 {
+		return 0, false
+	}
+	return uint64(n), true
+}
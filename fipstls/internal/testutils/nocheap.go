@@ -0,0 +1,14 @@
+//go:build !linux || !cgo
+// +build !linux !cgo
+
+package testutils
+
+// LimitCHeapArenas returns false as the C heap can only be measured on Linux with cgo.
+func LimitCHeapArenas() bool {
+	return false
+}
+
+// CHeapInUse returns false as the C heap can only be measured on Linux with cgo.
+func CHeapInUse() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,167 @@
+package fipstls
+
+import (
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// KeyUpdatePolicy controls automatic TLS 1.3 key updates on long-lived connections. A key
+// update is sent when either limit is reached, and both limits restart after every key update.
+// The policy is ignored on connections that did not negotiate TLS 1.3.
+type KeyUpdatePolicy struct {
+	// Bytes is the amount of application data written before the keys are updated.
+	// Zero means no byte limit.
+	Bytes uint64
+
+	// Interval is the time after the handshake or the previous key update after which the keys
+	// are updated. Zero means no time limit.
+	Interval time.Duration
+
+	// RequestPeer asks the peer to update its sending keys as well.
+	RequestPeer bool
+}
+
+func (p KeyUpdatePolicy) enabled() bool {
+	return p.Bytes > 0 || p.Interval > 0
+}
+
+// KeyUpdate updates the traffic keys used to send data on a TLS 1.3 connection. If requestPeer
+// is true, the peer is asked to update its sending keys too. Every key update is logged at
+// [LogLevelInfo] and counted in [ConnectionState.KeyUpdates].
+func (c *Conn) KeyUpdate(requestPeer bool) error {
+	return c.updateKeys(requestPeer, "requested")
+}
+
+// updateKeys performs a key update, interlocking with Write and Close.
+func (c *Conn) updateKeys(requestPeer bool, reason string) error {
+	if err := c.beginCall(); err != nil {
+		return err
+	}
+	defer c.endCall()
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closeNotifySent {
+		return ErrShutdown
+	}
+	return c.keyUpdate(requestPeer, reason)
+}
+
+// keyUpdate sends a KeyUpdate message. The caller must hold c.out.
+func (c *Conn) keyUpdate(requestPeer bool, reason string) error {
+	if !c.handshakeComplete.Load() || c.ConnectionState().Version != Version13 {
+		return ErrKeyUpdateUnavailable
+	}
+	// A KeyUpdate message that could not be sent stays queued, and libssl refuses to queue
+	// another one until it is sent.
+	if !c.keyUpdateQueued {
+		if err := c.withSSL(func() error {
+			libssl.SSLClearError()
+			return libssl.SSLKeyUpdate(c.ssl, requestPeer)
+		}); err != nil {
+			c.l.Logf(LogLevelErr, "Key update failed: %v", err)
+			return err
+		}
+		c.keyUpdateQueued = true
+	}
+	if _, err := c.doIO(nil, func(b []byte) (int, error) { return 0, c.doHandshake() },
+		opWrite); err != nil {
+		c.l.Logf(LogLevelErr, "Sending key update failed: %v", err)
+		return err
+	}
+	c.keyUpdateQueued = false
+	c.bytesSinceKeyUpdate = 0
+	c.keyUpdateDue.Store(false)
+	c.stateMu.Lock()
+	c.state.KeyUpdates++
+	n := c.state.KeyUpdates
+	c.stateMu.Unlock()
+	c.l.Logf(LogLevelInfo, "Key update %d sent (%s, peer update requested: %v)", n, reason,
+		requestPeer)
+	c.resetKeyUpdateTimer()
+	return nil
+}
+
+// resetKeyUpdateTimer restarts the interval of the key update policy, if any.
+func (c *Conn) resetKeyUpdateTimer() {
+	if t := c.keyUpdateTimer.Load(); t != nil {
+		t.Reset(c.keyUpdatePolicy.Interval)
+	}
+}
+
+func (c *Conn) doHandshake() error {
+	if c.closed.Load() {
+		return c.closeErr
+	}
+	libssl.SSLClearError()
+	return libssl.SSLDoHandshake(c.ssl)
+}
+
+// startKeyUpdatePolicy arms the key update timer once the handshake completes.
+func (c *Conn) startKeyUpdatePolicy() {
+	p := c.keyUpdatePolicy
+	if !p.enabled() {
+		return
+	}
+	if c.ConnectionState().Version != Version13 {
+		c.l.Logf(LogLevelInfo, "Key update policy ignored, TLS 1.3 was not negotiated")
+		return
+	}
+	if p.Interval > 0 {
+		c.keyUpdateTimer.Store(time.AfterFunc(p.Interval, func() {
+			c.scheduledKeyUpdate(p.RequestPeer)
+		}))
+	}
+}
+
+// scheduledKeyUpdate performs the key update of the interval timer. It never waits for c.out,
+// so that Close can wait for it: a write holding c.out updates the keys once done instead. The
+// timer is re-armed whether or not the keys were updated, so that a deferred or failed key
+// update is retried.
+func (c *Conn) scheduledKeyUpdate(requestPeer bool) {
+	c.keyUpdateMu.Lock()
+	defer c.keyUpdateMu.Unlock()
+	if c.keyUpdateStopped {
+		return
+	}
+	defer c.resetKeyUpdateTimer()
+	if !c.out.TryLock() {
+		c.keyUpdateDue.Store(true)
+		return
+	}
+	defer c.out.Unlock()
+	if c.closeNotifySent {
+		return
+	}
+	if err := c.keyUpdate(requestPeer, "interval"); err != nil {
+		c.l.Logf(LogLevelErr, "Scheduled key update failed: %v", err)
+	}
+}
+
+// stopKeyUpdatePolicy stops the key update timer, and waits for a scheduled key update in
+// progress.
+func (c *Conn) stopKeyUpdatePolicy() {
+	if t := c.keyUpdateTimer.Swap(nil); t != nil {
+		t.Stop()
+	}
+	c.keyUpdateMu.Lock()
+	c.keyUpdateStopped = true
+	c.keyUpdateMu.Unlock()
+}
+
+// countWritten updates the keys if n more bytes of application data exceed the byte limit of
+// the policy, or if the interval elapsed during the write. The caller must hold c.out.
+func (c *Conn) countWritten(n int) error {
+	p := c.keyUpdatePolicy
+	if c.keyUpdateDue.Swap(false) {
+		return c.keyUpdate(p.RequestPeer, "interval")
+	}
+	if p.Bytes == 0 || c.ConnectionState().Version != Version13 {
+		return nil
+	}
+	c.bytesSinceKeyUpdate += uint64(n)
+	if c.bytesSinceKeyUpdate < p.Bytes {
+		return nil
+	}
+	return c.keyUpdate(p.RequestPeer, "byte limit")
+}
@@ -0,0 +1,195 @@
+package fipstls_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+func TestKeyUpdate(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, requestPeer := range []bool{false, true} {
+		t.Run(fmt.Sprintf("requestPeer %v", requestPeer), func(t *testing.T) {
+			d := fipstls.NewDialer(&fipstls.Config{CaFile: ts.CaFile}, getFipsDialOpts()...)
+			conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			c := conn.(*fipstls.Conn)
+			if err := c.KeyUpdate(requestPeer); err != nil {
+				t.Fatalf("KeyUpdate() failed: %v", err)
+			}
+			// The connection keeps working with the updated keys
+			getRequest(t, conn, u.Host)
+			if n := c.ConnectionState().KeyUpdates; n != 1 {
+				t.Errorf("KeyUpdates = %d, want 1", n)
+			}
+		})
+	}
+
+	t.Run("TLS 1.2", func(t *testing.T) {
+		d := fipstls.NewDialer(&fipstls.Config{
+			CaFile:        ts.CaFile,
+			MaxTLSVersion: fipstls.Version12,
+		}, getFipsDialOpts()...)
+		conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		defer conn.Close()
+		err = conn.(*fipstls.Conn).KeyUpdate(false)
+		if !errors.Is(err, fipstls.ErrKeyUpdateUnavailable) {
+			t.Fatalf("KeyUpdate() err = %v, want %v", err, fipstls.ErrKeyUpdateUnavailable)
+		}
+	})
+}
+
+func TestKeyUpdatePolicy(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name   string
+		policy fipstls.KeyUpdatePolicy
+		wait   time.Duration
+	}{
+		{
+			name:   "Byte limit",
+			policy: fipstls.KeyUpdatePolicy{Bytes: 1},
+		},
+		{
+			name:   "Interval",
+			policy: fipstls.KeyUpdatePolicy{Interval: 50 * time.Millisecond, RequestPeer: true},
+			wait:   200 * time.Millisecond,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := fipstls.NewDialer(&fipstls.Config{CaFile: ts.CaFile},
+				append(getFipsDialOpts(), fipstls.WithKeyUpdatePolicy(tc.policy))...)
+			conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			time.Sleep(tc.wait)
+			getRequest(t, conn, u.Host)
+			if n := conn.(*fipstls.Conn).ConnectionState().KeyUpdates; n == 0 {
+				t.Error("KeyUpdates = 0, want at least 1")
+			}
+		})
+	}
+}
+
+// TestKeyUpdateIntervalClose checks that a scheduled key update racing Close does not prevent
+// the close_notify.
+func TestKeyUpdateIntervalClose(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			server := fipstls.Server(conn, &fipstls.Config{
+				CertFile: testutils.CertPath,
+				KeyFile:  testKeyPath,
+			})
+			server.SetDeadline(time.Now().Add(10 * time.Second))
+			// The client closes without sending data, so reading only ends with its close_notify.
+			_, err = server.Read(make([]byte, 1))
+			server.Close()
+			errCh <- err
+		}
+	}()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath}, append(getFipsDialOpts(),
+		fipstls.WithKeyUpdatePolicy(fipstls.KeyUpdatePolicy{Interval: time.Millisecond}))...)
+	defer d.Close()
+	for i := 0; i < 50; i++ {
+		conn, err := d.DialContext(context.Background(), "tcp4",
+			net.JoinHostPort("localhost", port))
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		time.Sleep(time.Duration(i%5) * time.Millisecond)
+		if err := conn.Close(); err != nil {
+			t.Fatalf("Close() failed: %v", err)
+		}
+		if err := <-errCh; err != io.EOF {
+			t.Fatalf("Server Read() err = %v, want %v", err, io.EOF)
+		}
+	}
+}
+
+// TestKeyUpdateIntervalRetry checks that the keys are still updated periodically after a
+// scheduled key update failed.
+func TestKeyUpdateIntervalRetry(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	ln := newTLSListener(t, tls.VersionTLS13)
+	defer ln.Close()
+	received := readAllTLS(ln)
+	const interval = 20 * time.Millisecond
+	conn := dialListener(t, ln, &fipstls.Config{CaFile: testutils.CertPath},
+		fipstls.WithKeyUpdatePolicy(fipstls.KeyUpdatePolicy{Interval: interval}))
+	defer conn.Close()
+
+	// The expired write deadline fails the first scheduled key updates.
+	conn.SetWriteDeadline(time.Unix(1, 0))
+	time.Sleep(3 * interval)
+	if n := conn.ConnectionState().KeyUpdates; n != 0 {
+		t.Fatalf("KeyUpdates = %d with an expired write deadline, want 0", n)
+	}
+	conn.SetWriteDeadline(time.Time{})
+	time.Sleep(5 * interval)
+	if n := conn.ConnectionState().KeyUpdates; n == 0 {
+		t.Error("KeyUpdates = 0 after the write deadline was cleared, want at least 1")
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if got := <-received; string(got) != "ping" {
+		t.Errorf("Server received %q, want %q", got, "ping")
+	}
+}
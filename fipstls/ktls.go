@@ -0,0 +1,131 @@
+package fipstls
+
+import (
+	"io"
+	"net"
+	"os"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// maxSendfileSize bounds the bytes sent by a single SSL_sendfile, like the net package does
+// for sendfile(2).
+const maxSendfileSize = 4 << 20
+
+// writerOnly hides the ReadFrom method of a [Conn] from io.Copy.
+type writerOnly struct {
+	io.Writer
+}
+
+// ReadFrom implements io.ReaderFrom. If the kernel encrypts the records sent, see
+// [ConnectionState.KTLSSend], and r is a regular *os.File or an *io.LimitedReader of one, the
+// file is sent with SSL_sendfile without being copied to user space. The data is copied with
+// [Conn.Write] otherwise. The file offset is advanced past the bytes sent.
+func (c *Conn) ReadFrom(r io.Reader) (int64, error) {
+	f, remain, lr := sendfileSource(r)
+	if f == nil {
+		return io.Copy(writerOnly{c}, r)
+	}
+	if err := c.beginCall(); err != nil {
+		return 0, err
+	}
+	defer c.endCall()
+	if err := c.handshakeFor(opWrite); err != nil {
+		return 0, err
+	}
+	if !c.ConnectionState().KTLSSend {
+		return io.Copy(writerOnly{c}, r)
+	}
+	written, err := c.sendfile(f, remain)
+	if lr != nil {
+		lr.N -= written
+	}
+	return written, err
+}
+
+// sendfileSource returns the regular file read by r and the number of bytes to send from it,
+// or a nil file if r cannot be sent with SSL_sendfile. lr is the *io.LimitedReader wrapping the
+// file, if any.
+func sendfileSource(r io.Reader) (f *os.File, remain int64, lr *io.LimitedReader) {
+	remain = 1<<63 - 1
+	if l, ok := r.(*io.LimitedReader); ok {
+		lr, remain, r = l, l.N, l.R
+		if remain <= 0 {
+			return nil, 0, nil
+		}
+	}
+	f, ok := r.(*os.File)
+	if !ok {
+		return nil, 0, nil
+	}
+	if fi, err := f.Stat(); err != nil || !fi.Mode().IsRegular() {
+		return nil, 0, nil
+	}
+	return f, remain, lr
+}
+
+// sendfile sends up to remain bytes of f from its current offset until the end of the file.
+func (c *Conn) sendfile(f *os.File, remain int64) (int64, error) {
+	rawConn, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closeNotifySent {
+		return 0, ErrShutdown
+	}
+	// The data buffered by write coalescing was written before the file.
+	if err := c.flush(); err != nil {
+		return 0, err
+	}
+	c.l.Logf(LogLevelDebug, "Sendfile from offset %d", offset)
+	var written int64
+	for remain > 0 {
+		size := int(min(remain, maxSendfileSize))
+		var n int
+		cerr := rawConn.Control(func(fd uintptr) {
+			n, err = c.doIO(nil, func([]byte) (int, error) {
+				return c.sendfileOnce(int(fd), offset, size)
+			}, opWrite)
+		})
+		if cerr != nil {
+			err = cerr
+		}
+		if n > 0 {
+			offset += int64(n)
+			written += int64(n)
+			remain -= int64(n)
+			if cerr := c.countWritten(n); err == nil {
+				err = cerr
+			}
+		}
+		// SSL_sendfile sends nothing at the end of the file.
+		if err != nil || n == 0 {
+			break
+		}
+	}
+	// SSL_sendfile does not move the file offset.
+	if _, serr := f.Seek(offset, io.SeekStart); err == nil {
+		err = serr
+	}
+	return written, err
+}
+
+// sendfileOnce sends up to size bytes of the file fd from offset.
+func (c *Conn) sendfileOnce(fd int, offset int64, size int) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	libssl.SSLClearError()
+	n, err := libssl.SSLSendfile(c.ssl, fd, offset, size)
+	if sslErr, ok := err.(*libssl.SSLError); ok && sslErr.Code != libssl.SSL_ERROR_WANT_WRITE {
+		// Failing to read the file is not an error of the socket, so it must not be retried.
+		return 0, libssl.NewOpenSSLError("libssl: SSL_sendfile")
+	}
+	return n, err
+}
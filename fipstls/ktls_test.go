@@ -0,0 +1,137 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// readAllTLS accepts a single connection on ln and returns everything read from it until EOF.
+func readAllTLS(ln net.Listener) <-chan []byte {
+	ch := make(chan []byte, 1)
+	go func() {
+		defer close(ch)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := io.ReadAll(conn)
+		ch <- b
+	}()
+	return ch
+}
+
+func TestKTLSSendfile(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	data := make([]byte, 1<<20+123)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		t.Run(fmt.Sprintf("version %x", version), func(t *testing.T) {
+			ln := newTLSListener(t, version)
+			defer ln.Close()
+			received := readAllTLS(ln)
+			conn := dialListener(t, ln, &fipstls.Config{
+				CaFile:     testutils.CertPath,
+				EnableKTLS: true,
+			})
+			defer conn.Close()
+			// kTLS depends on the kernel, and the file is copied through user space without it.
+			state := conn.ConnectionState()
+			t.Logf("kTLS send: %v, receive: %v", state.KTLSSend, state.KTLSRecv)
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			// Send the first 1000 bytes through a LimitedReader and the rest of the file.
+			lr := &io.LimitedReader{R: f, N: 1000}
+			if n, err := io.Copy(conn, lr); err != nil || n != 1000 {
+				t.Fatalf("io.Copy() of LimitedReader = %d, %v, want 1000", n, err)
+			}
+			if lr.N != 0 {
+				t.Errorf("LimitedReader.N = %d, want 0", lr.N)
+			}
+			if n, err := io.Copy(conn, f); err != nil || n != int64(len(data)-1000) {
+				t.Fatalf("io.Copy() = %d, %v, want %d", n, err, len(data)-1000)
+			}
+			if off, _ := f.Seek(0, io.SeekCurrent); off != int64(len(data)) {
+				t.Errorf("File offset = %d, want %d", off, len(data))
+			}
+			if err := conn.Close(); err != nil {
+				t.Fatalf("Close() failed: %v", err)
+			}
+			if got := <-received; !bytes.Equal(got, data) {
+				t.Errorf("Server received %d bytes, want the %d bytes of the file", len(got),
+					len(data))
+			}
+		})
+	}
+}
+
+// TestKTLSSendfileAfterBufferedWrite checks that the data buffered by write coalescing is sent
+// before a file.
+func TestKTLSSendfileAfterBufferedWrite(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	data := make([]byte, 64<<10)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ln := newTLSListener(t, tls.VersionTLS13)
+	defer ln.Close()
+	received := readAllTLS(ln)
+	// The delay keeps the header buffered until the file is sent.
+	conn := dialListener(t, ln, &fipstls.Config{
+		CaFile:     testutils.CertPath,
+		EnableKTLS: true,
+	}, fipstls.WithWriteCoalescing(fipstls.WriteCoalescing{Delay: time.Hour}))
+	defer conn.Close()
+	t.Logf("kTLS send: %v", conn.ConnectionState().KTLSSend)
+
+	header := []byte("header")
+	if _, err := conn.Write(header); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if n, err := conn.ReadFrom(f); err != nil || n != int64(len(data)) {
+		t.Fatalf("ReadFrom() = %d, %v, want %d", n, err, len(data))
+	}
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if got, want := <-received, append(header, data...); !bytes.Equal(got, want) {
+		t.Errorf("Server received %d bytes, want the header followed by the %d bytes of the file",
+			len(got), len(data))
+	}
+}
@@ -0,0 +1,68 @@
+package fipstls
+
+import (
+	"net"
+)
+
+// Client returns a new TLS client side connection using conn as the underlying transport, like
+// [crypto/tls.Client]. The hostname is verified against [Config.ServerName], or the host of
+// the remote address of conn if it is empty. If conn has no remote host, such as the
+// connections of [net.Pipe], either [Config.ServerName] or [Config.InsecureSkipVerify] must be
+// set, otherwise the handshake fails with [ErrMissingServerName]. A nil config uses the default
+// configuration.
+//
+// The handshake runs on the first [Conn.Read] or [Conn.Write], or when [Conn.Handshake] is
+// called. If the connection could not be set up, the error is returned by the handshake.
+// Closing the [Conn] closes conn.
+func Client(conn net.Conn, config *Config) *Conn {
+	return newNetConn(conn, config, ClientMethod)
+}
+
+// Server returns a new TLS server side connection using conn as the underlying transport, like
+// [crypto/tls.Server]. The config must set CertFile and KeyFile, or GetPSK. Client certificates
+// are requested and verified according to [Config.VerifyMode].
+//
+// The handshake runs on the first [Conn.Read] or [Conn.Write], or when [Conn.Handshake] is
+// called. If the connection could not be set up, the error is returned by the handshake.
+// Closing the [Conn] closes conn.
+func Server(conn net.Conn, config *Config) *Conn {
+	return newNetConn(conn, config, ServerMethod)
+}
+
+// newNetConn creates a [Conn] over conn with a [Context] of its own. On error, the returned
+// [Conn] fails the handshake with it.
+func newNetConn(conn net.Conn, config *Config, method Method) *Conn {
+	if config == nil {
+		config = newDefaultConfig()
+	}
+	tls := *config
+	tls.Method = method
+	bio, err := NewConnBIO(conn)
+	if err != nil {
+		return newFailedConn(bio, &tls, bio.Close, err)
+	}
+	ctx, err := NewCtx(&tls)
+	if err != nil {
+		ctx.Close()
+		return newFailedConn(bio, &tls, bio.Close, err)
+	}
+	// The Conn holds its own reference to the context, which is freed when the Conn is closed.
+	c, err := NewConn(ctx, bio, &tls, nil)
+	ctx.Close()
+	if err != nil {
+		return newFailedConn(bio, &tls, bio.Close, err)
+	}
+	return c
+}
+
+// newFailedConn returns a [Conn] that could not be set up over bio. Its handshake fails with err
+// and closing it calls closeFunc.
+func newFailedConn(bio *BIO, tls *Config, closeFunc func() error, err error) *Conn {
+	return &Conn{
+		bio:          bio,
+		config:       tls,
+		closer:       newOnceCloser(closeFunc),
+		l:            noopLogger{},
+		handshakeErr: err,
+	}
+}
@@ -0,0 +1,516 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// newConnPair returns the two ends of a connection over network, which is either "pipe" for
+// net.Pipe or "tcp" for a loopback TCP connection.
+func newConnPair(t testing.TB, network string) (net.Conn, net.Conn) {
+	t.Helper()
+	if network == "pipe" {
+		c1, c2 := net.Pipe()
+		return c1, c2
+	}
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	c1, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := ln.Accept()
+	if err != nil {
+		c1.Close()
+		t.Fatal(err)
+	}
+	return c1, c2
+}
+
+// runServer serves a single connection with fn in a goroutine and returns its result.
+func runServer(conn *fipstls.Conn, fn func(*fipstls.Conn) error) <-chan error {
+	errCh := make(chan error, 1)
+	go func() {
+		defer conn.Close()
+		errCh <- fn(conn)
+	}()
+	return errCh
+}
+
+// echoOnce greets the client, then echoes one 4 byte message.
+func echoOnce(conn *fipstls.Conn) error {
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		return err
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return err
+	}
+	_, err := conn.Write(buf)
+	return err
+}
+
+// checkEcho reads the greeting of echoOnce and checks that a message is echoed.
+func checkEcho(t *testing.T, conn *fipstls.Conn) {
+	t.Helper()
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Failed to read greeting: %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("Greeting = %q, want %q", buf, "hello")
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		t.Fatalf("Failed to read echo: %v", err)
+	}
+	if string(buf[:4]) != "ping" {
+		t.Fatalf("Echo = %q, want %q", buf[:4], "ping")
+	}
+}
+
+// expectEOF reads the close_notify of a server that closed its connection. On a net.Pipe, the
+// server blocks sending it until it is read.
+func expectEOF(t *testing.T, conn *fipstls.Conn) {
+	t.Helper()
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read() err = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestClientServer(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	for _, network := range []string{"pipe", "tcp"} {
+		for _, version := range []uint16{fipstls.Version12, fipstls.Version13} {
+			t.Run(fmt.Sprintf("%s version %x", network, version), func(t *testing.T) {
+				c1, c2 := newConnPair(t, network)
+				server := fipstls.Server(c2, &fipstls.Config{
+					CertFile:      testutils.CertPath,
+					KeyFile:       testKeyPath,
+					MinTLSVersion: version,
+					MaxTLSVersion: version,
+				})
+				errCh := runServer(server, echoOnce)
+
+				client := fipstls.Client(c1, &fipstls.Config{
+					CaFile:     testutils.CertPath,
+					ServerName: "localhost",
+				})
+				defer client.Close()
+				checkEcho(t, client)
+				expectEOF(t, client)
+				if err := <-errCh; err != nil {
+					t.Fatalf("Server failed: %v", err)
+				}
+				if got := client.ConnectionState().Version; got != version {
+					t.Errorf("Client version = %x, want %x", got, version)
+				}
+				if got := server.ConnectionState().Version; got != version {
+					t.Errorf("Server version = %x, want %x", got, version)
+				}
+			})
+		}
+	}
+
+	t.Run("hostname mismatch", func(t *testing.T) {
+		// The client alert would block on a net.Pipe until the server reads it.
+		c1, c2 := newConnPair(t, "tcp")
+		server := fipstls.Server(c2, &fipstls.Config{
+			CertFile: testutils.CertPath,
+			KeyFile:  testKeyPath,
+		})
+		errCh := runServer(server, func(c *fipstls.Conn) error { return c.Handshake(time.Time{}) })
+		client := fipstls.Client(c1, &fipstls.Config{
+			CaFile:     testutils.CertPath,
+			ServerName: "example.com",
+		})
+		defer client.Close()
+		if err := client.Handshake(time.Time{}); err == nil {
+			t.Fatal("Handshake() succeeded with a mismatched hostname")
+		}
+		// The handshake error is sticky.
+		if _, err := client.Write([]byte("ping")); err == nil {
+			t.Error("Write() succeeded after a failed handshake")
+		}
+		client.Close()
+		<-errCh
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		c1, c2 := newConnPair(t, "pipe")
+		defer c2.Close()
+		client := fipstls.Client(c1, &fipstls.Config{MaxFragmentLength: 3})
+		if err := client.Handshake(time.Time{}); !errors.Is(err, fipstls.ErrInvalidRecordSize) {
+			t.Fatalf("Handshake() err = %v, want %v", err, fipstls.ErrInvalidRecordSize)
+		}
+		if _, err := client.Read(make([]byte, 1)); !errors.Is(err, fipstls.ErrInvalidRecordSize) {
+			t.Errorf("Read() err = %v, want %v", err, fipstls.ErrInvalidRecordSize)
+		}
+		if err := client.Close(); err != nil {
+			t.Errorf("Close() err = %v", err)
+		}
+		// Closing the client closes the underlying connection.
+		if _, err := c2.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("Peer Read() err = %v, want %v", err, io.EOF)
+		}
+	})
+}
+
+func TestClientMissingServerName(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	// A net.Pipe has no remote host to verify the certificate against.
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	client := fipstls.Client(c1, &fipstls.Config{CaFile: testutils.CertPath})
+	defer client.Close()
+	if err := client.Handshake(time.Now().Add(5 * time.Second)); !errors.Is(err,
+		fipstls.ErrMissingServerName) {
+		t.Errorf("Handshake() err = %v, want %v", err, fipstls.ErrMissingServerName)
+	}
+}
+
+func TestClientServerDeadlines(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	c1, c2 := newConnPair(t, "pipe")
+	server := fipstls.Server(c2, &fipstls.Config{
+		CertFile: testutils.CertPath,
+		KeyFile:  testKeyPath,
+	})
+	release := make(chan struct{})
+	errCh := runServer(server, func(c *fipstls.Conn) error {
+		if err := c.Handshake(time.Time{}); err != nil {
+			return err
+		}
+		<-release
+		if err := echoOnce(c); err != nil {
+			return err
+		}
+		// Read the close_notify of the client.
+		_, err := io.Copy(io.Discard, c)
+		return err
+	})
+	client := fipstls.Client(c1, &fipstls.Config{
+		CaFile:     testutils.CertPath,
+		ServerName: "localhost",
+	})
+	defer client.Close()
+
+	// The server does not write anything until released.
+	client.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read() err = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	// A read that timed out can be retried.
+	client.SetReadDeadline(time.Time{})
+	close(release)
+	checkEcho(t, client)
+
+	// Closing a connection unblocks a pending Read.
+	readErr := make(chan error, 1)
+	go func() {
+		_, err := client.Read(make([]byte, 1))
+		readErr <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	client.Close()
+	select {
+	case err := <-readErr:
+		// The server may answer the close_notify of the client before its connection is closed.
+		if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+			t.Errorf("Read() err = %v, want %v or %v", err, io.EOF, net.ErrClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read() did not return after Close()")
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server failed: %v", err)
+	}
+}
+
+func TestServerPSK(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	key := bytes.Repeat([]byte{0x42}, 32)
+	for _, version := range []uint16{fipstls.Version12, fipstls.Version13} {
+		t.Run(fmt.Sprintf("version %x", version), func(t *testing.T) {
+			c1, c2 := newConnPair(t, "pipe")
+			var identity string
+			server := fipstls.Server(c2, &fipstls.Config{
+				MinTLSVersion: version,
+				MaxTLSVersion: version,
+				GetPSK: func(id string) (*fipstls.PSK, error) {
+					identity = id
+					return &fipstls.PSK{Identity: id, Key: key}, nil
+				},
+			})
+			errCh := runServer(server, echoOnce)
+			client := fipstls.Client(c1, &fipstls.Config{
+				ServerName:    "localhost",
+				MaxTLSVersion: version,
+				PSK:           &fipstls.PSK{Identity: "client", Key: key},
+			})
+			defer client.Close()
+			checkEcho(t, client)
+			expectEOF(t, client)
+			if err := <-errCh; err != nil {
+				t.Fatalf("Server failed: %v", err)
+			}
+			if identity != "client" {
+				t.Errorf("Server got identity %q, want %q", identity, "client")
+			}
+		})
+	}
+}
+
+// writeClientCertificate writes a self-signed client certificate and its key to dir.
+func writeClientCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY",
+		Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerPostHandshakeAuth(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	certFile, keyFile := writeClientCertificate(t, t.TempDir())
+	c1, c2 := newConnPair(t, "tcp")
+	server := fipstls.Server(c2, &fipstls.Config{
+		CertFile: testutils.CertPath,
+		KeyFile:  testKeyPath,
+		CaFile:   certFile,
+		// Don't ask for a certificate during the handshake.
+		InsecureSkipVerify: true,
+	})
+	errCh := runServer(server, func(c *fipstls.Conn) error {
+		if err := c.Handshake(time.Time{}); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.RequestClientCertificate(ctx); err != nil {
+			return err
+		}
+		_, err := c.Write([]byte("ok"))
+		return err
+	})
+	client := fipstls.Client(c1, &fipstls.Config{
+		CaFile:            testutils.CertPath,
+		ServerName:        "localhost",
+		CertFile:          certFile,
+		KeyFile:           keyFile,
+		PostHandshakeAuth: true,
+	})
+	defer client.Close()
+	// The certificate request is answered while reading.
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(client, buf); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("RequestClientCertificate() failed: %v", err)
+	}
+}
+
+func TestServerPostHandshakeAuthWait(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	certFile, keyFile := writeClientCertificate(t, t.TempDir())
+	newPair := func(t *testing.T) (client, server *fipstls.Conn) {
+		c1, c2 := newConnPair(t, "tcp")
+		server = fipstls.Server(c2, &fipstls.Config{
+			CertFile:           testutils.CertPath,
+			KeyFile:            testKeyPath,
+			CaFile:             certFile,
+			InsecureSkipVerify: true,
+		})
+		client = fipstls.Client(c1, &fipstls.Config{
+			CaFile:            testutils.CertPath,
+			ServerName:        "localhost",
+			CertFile:          certFile,
+			KeyFile:           keyFile,
+			PostHandshakeAuth: true,
+		})
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+		errCh := make(chan error, 1)
+		go func() { errCh <- client.Handshake(time.Now().Add(5 * time.Second)) }()
+		if err := server.Handshake(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		if err := <-errCh; err != nil {
+			t.Fatalf("Client handshake failed: %v", err)
+		}
+		return client, server
+	}
+
+	t.Run("Read in progress", func(t *testing.T) {
+		client, server := newPair(t)
+		readCh := make(chan error, 1)
+		go func() {
+			buf := make([]byte, 4)
+			_, err := io.ReadFull(server, buf)
+			readCh <- err
+		}()
+		clientCh := make(chan error, 1)
+		go func() {
+			// The certificate request is answered while reading.
+			buf := make([]byte, 2)
+			if _, err := io.ReadFull(client, buf); err != nil {
+				clientCh <- err
+				return
+			}
+			_, err := client.Write([]byte("done"))
+			clientCh <- err
+		}()
+		// Let the Read start, it processes the certificate instead.
+		time.Sleep(50 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.RequestClientCertificate(ctx); err != nil {
+			t.Fatalf("RequestClientCertificate() failed: %v", err)
+		}
+		if _, err := server.Write([]byte("ok")); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		if err := <-clientCh; err != nil {
+			t.Fatalf("Client failed: %v", err)
+		}
+		if err := <-readCh; err != nil {
+			t.Fatalf("Read() failed: %v", err)
+		}
+	})
+
+	t.Run("Context done", func(t *testing.T) {
+		client, server := newPair(t)
+		// The client does not read, so it never answers.
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := server.RequestClientCertificate(ctx); !errors.Is(err,
+			context.DeadlineExceeded) {
+			t.Fatalf("RequestClientCertificate() err = %v, want %v", err,
+				context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("RequestClientCertificate() returned after %v", elapsed)
+		}
+		// The read deadline of the connection is not left expired.
+		if _, err := client.Write([]byte("x")); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+		if _, err := server.Read(make([]byte, 1)); err != nil {
+			t.Errorf("Read() after RequestClientCertificate() failed: %v", err)
+		}
+	})
+
+	t.Run("Read deadline", func(t *testing.T) {
+		_, server := newPair(t)
+		server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		if err := server.RequestClientCertificate(context.Background()); !errors.Is(err,
+			os.ErrDeadlineExceeded) {
+			t.Fatalf("RequestClientCertificate() err = %v, want %v", err,
+				os.ErrDeadlineExceeded)
+		}
+	})
+}
+
+func TestServerExportKeyingMaterial(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	c1, c2 := newConnPair(t, "pipe")
+	server := fipstls.Server(c2, &fipstls.Config{
+		CertFile: testutils.CertPath,
+		KeyFile:  testKeyPath,
+	})
+	ekmCh := make(chan []byte, 1)
+	errCh := runServer(server, func(c *fipstls.Conn) error {
+		if err := echoOnce(c); err != nil {
+			return err
+		}
+		ekm, err := c.ExportKeyingMaterial("EXPERIMENTAL test", nil, 32)
+		ekmCh <- ekm
+		return err
+	})
+	client := fipstls.Client(c1, &fipstls.Config{
+		CaFile:     testutils.CertPath,
+		ServerName: "localhost",
+	})
+	defer client.Close()
+	checkEcho(t, client)
+	expectEOF(t, client)
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server failed: %v", err)
+	}
+	want, err := client.ExportKeyingMaterial("EXPERIMENTAL test", nil, 32)
+	if err != nil {
+		t.Fatalf("ExportKeyingMaterial() failed: %v", err)
+	}
+	if got := <-ekmCh; !bytes.Equal(got, want) {
+		t.Errorf("Server keying material = %x, client = %x", got, want)
+	}
+}
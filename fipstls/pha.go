@@ -0,0 +1,190 @@
+package fipstls
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// RequestClientCertificate asks the client of a TLS 1.3 server connection to authenticate with
+// a certificate after the handshake, as defined in RFC 8446, Section 4.6.2. It blocks until the
+// certificate has been received and verified, ctx is done, the read deadline expires or the
+// connection is closed. It returns immediately if the client already presented a certificate.
+//
+// The client must have enabled [Config.PostHandshakeAuth]. A client that declines to send a
+// certificate fails the connection. The response is read from the connection while no Read is
+// in progress, so if the client sends application data first, the response is processed once
+// that data has been read.
+func (c *Conn) RequestClientCertificate(ctx context.Context) error {
+	if err := c.beginCall(); err != nil {
+		return err
+	}
+	defer c.endCall()
+	if !c.handshakeComplete.Load() || c.ConnectionState().Version != Version13 {
+		return ErrPostHandshakeAuthUnavailable
+	}
+	var server bool
+	if err := c.withSSL(func() error {
+		server = libssl.SSLIsServer(c.ssl)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !server {
+		return ErrPostHandshakeAuthUnavailable
+	}
+	if ok, err := c.hasPeerCertificate(); ok || err != nil {
+		return err
+	}
+	if err := c.sendCertificateRequest(); err != nil {
+		return err
+	}
+	return c.awaitClientCertificate(ctx)
+}
+
+// sendCertificateRequest sends a post-handshake CertificateRequest, interlocking with Write.
+func (c *Conn) sendCertificateRequest() error {
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closeNotifySent {
+		return ErrShutdown
+	}
+	if err := c.withSSL(func() error {
+		libssl.SSLClearError()
+		return libssl.SSLVerifyClientPostHandshake(c.ssl)
+	}); err != nil {
+		c.l.Logf(LogLevelErr, "Post-handshake authentication failed: %v", err)
+		return err
+	}
+	if _, err := c.doIO(nil, func(b []byte) (int, error) { return 0, c.doHandshake() },
+		opWrite); err != nil {
+		c.l.Logf(LogLevelErr, "Sending certificate request failed: %v", err)
+		return err
+	}
+	c.l.Logf(LogLevelInfo, "Post-handshake certificate request sent")
+	return nil
+}
+
+// errDataPending is returned by readClientCertificate when application data sent by the client
+// before its certificate has to be read first.
+var errDataPending = errors.New("fipstls: application data pending")
+
+// awaitClientCertificate processes incoming records until the client certificate arrives. A
+// Read in progress processes them instead, and wakes it through certWait.
+func (c *Conn) awaitClientCertificate(ctx context.Context) error {
+	defer c.certWait.Store(nil)
+	for {
+		// Register before checking, so reads that process the certificate from here on wake it.
+		wait := make(chan struct{})
+		c.certWait.Store(&wait)
+		if ok, err := c.hasPeerCertificate(); ok || err != nil {
+			if err == nil {
+				c.l.Logf(LogLevelInfo, "Post-handshake client certificate verified")
+			}
+			return err
+		}
+		if c.in.TryLock() {
+			err := c.readClientCertificate(ctx)
+			c.in.Unlock()
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, errDataPending) {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				c.l.Logf(LogLevelErr, "Post-handshake authentication failed: %v", err)
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.closer.Done():
+			return net.ErrClosed
+		case <-wait:
+		}
+	}
+}
+
+// readClientCertificate reads records until the client certificate arrives, waiting for the
+// socket until the read deadline expires or ctx is done. It returns errDataPending if the client
+// sent application data first.
+func (c *Conn) readClientCertificate(ctx context.Context) error {
+	// Expiring the read deadline of the BIO interrupts the wait when ctx is done.
+	interrupted := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(interrupted)
+		c.deadlineMu.Lock()
+		defer c.deadlineMu.Unlock()
+		c.bio.setReadDeadline(time.Now())
+	})
+	defer func() {
+		if !stop() {
+			<-interrupted
+			c.restoreDeadlines()
+		}
+	}()
+	_, err := c.doIO(nil, func([]byte) (int, error) {
+		if c.closed.Load() {
+			return 0, net.ErrClosed
+		}
+		libssl.SSLClearError()
+		n, err := libssl.SSLPeek(c.ssl)
+		if cert, _ := libssl.SSLGetPeerCertificate(c.ssl); cert != nil {
+			return 0, nil
+		}
+		switch {
+		case err == nil && n > 0:
+			return 0, errDataPending
+		case err != nil && !wantIO(err):
+			// Fail without retrying, with the verification error if there is one.
+			if verifyErr := libssl.SSLGetVerifyResult(c.ssl); verifyErr != nil {
+				return 0, verifyErr
+			}
+			return 0, newConnError(opRead, c.bio.RemoteAddr(), err)
+		}
+		return 0, err
+	}, opRead)
+	return err
+}
+
+// wakeCertWaiter wakes awaitClientCertificate after a read, which may have processed the client
+// certificate, or released the input for it to read the certificate itself.
+func (c *Conn) wakeCertWaiter() {
+	if c.certWait.Load() == nil {
+		return
+	}
+	if wait := c.certWait.Swap(nil); wait != nil {
+		close(*wait)
+	}
+}
+
+// hasPeerCertificate returns true if the peer presented a certificate, and the verification
+// error if it was rejected.
+func (c *Conn) hasPeerCertificate() (ok bool, err error) {
+	var verifyErr error
+	err = c.withSSL(func() error {
+		cert, err := libssl.SSLGetPeerCertificate(c.ssl)
+		if err != nil || cert == nil {
+			return err
+		}
+		ok = true
+		verifyErr = libssl.SSLGetVerifyResult(c.ssl)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return ok, verifyErr
+}
+
+// wantIO returns true if err only means the operation has to wait for the socket.
+func wantIO(err error) bool {
+	sslErr, ok := err.(*libssl.SSLError)
+	return ok && (sslErr.Code == libssl.SSL_ERROR_WANT_READ ||
+		sslErr.Code == libssl.SSL_ERROR_WANT_WRITE)
+}
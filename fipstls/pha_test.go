@@ -0,0 +1,43 @@
+package fipstls_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+func TestPostHandshakeAuth(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	ln := newTLSListener(t, tls.VersionTLS13)
+	defer ln.Close()
+	errCh := serveTLS(ln, func(tls.ConnectionState) ([]byte, error) {
+		return []byte("ok"), nil
+	})
+
+	conn := dialListener(t, ln, &fipstls.Config{
+		CaFile:            testutils.CertPath,
+		CertFile:          testutils.CertPath,
+		KeyFile:           testKeyPath,
+		PostHandshakeAuth: true,
+	})
+	defer conn.Close()
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server failed: %v", err)
+	}
+	err := conn.RequestClientCertificate(context.Background())
+	if !errors.Is(err, fipstls.ErrPostHandshakeAuthUnavailable) {
+		t.Errorf("RequestClientCertificate() on client = %v, want %v", err,
+			fipstls.ErrPostHandshakeAuthUnavailable)
+	}
+}
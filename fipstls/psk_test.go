@@ -0,0 +1,42 @@
+package fipstls_test
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+func TestPSKCertificateFallback(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The server does not know the PSK, so the handshake falls back to certificates.
+	for _, version := range []uint16{fipstls.Version12, fipstls.Version13} {
+		t.Run(fmt.Sprintf("version %x", version), func(t *testing.T) {
+			d := fipstls.NewDialer(&fipstls.Config{
+				CaFile:        ts.CaFile,
+				MaxTLSVersion: version,
+				PSK:           &fipstls.PSK{Identity: "client", Key: []byte("secret")},
+			}, getFipsDialOpts()...)
+			conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			defer conn.Close()
+			getRequest(t, conn, u.Host)
+			if state := conn.(*fipstls.Conn).ConnectionState(); state.Version != version {
+				t.Errorf("Version = %x, want %x", state.Version, version)
+			}
+		})
+	}
+}
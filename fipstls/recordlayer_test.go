@@ -0,0 +1,267 @@
+package fipstls_test
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+const (
+	recordTypeApplicationData = 23
+	recordHeaderLen           = 5
+	aeadTagLen                = 16
+)
+
+// recordConn records the lengths of the application data records read from the connection.
+type recordConn struct {
+	net.Conn
+	mu      sync.Mutex
+	buf     []byte
+	lengths []int
+}
+
+func (c *recordConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = append(c.buf, b[:n]...)
+	for len(c.buf) >= recordHeaderLen {
+		l := int(binary.BigEndian.Uint16(c.buf[3:5]))
+		if len(c.buf) < recordHeaderLen+l {
+			break
+		}
+		if c.buf[0] == recordTypeApplicationData {
+			c.lengths = append(c.lengths, l)
+		}
+		c.buf = c.buf[recordHeaderLen+l:]
+	}
+	return n, err
+}
+
+func (c *recordConn) recordLengths() []int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]int(nil), c.lengths...)
+}
+
+func TestRecordLayer(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	cert, err := tls.LoadX509KeyPair(testutils.CertPath, testKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const size = 2000
+	testCases := []struct {
+		name    string
+		version uint16
+		config  fipstls.Config
+		check   func(lengths []int) error
+	}{
+		{
+			name:    "MaxSendFragment",
+			version: tls.VersionTLS12,
+			config:  fipstls.Config{MaxSendFragment: 512},
+			check: func(lengths []int) error {
+				if len(lengths) < size/512 {
+					return fmt.Errorf("got %d records, want at least %d", len(lengths), size/512)
+				}
+				for _, l := range lengths {
+					if l > 512+64 {
+						return fmt.Errorf("record of %d bytes exceeds the send fragment", l)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:    "RecordPadding",
+			version: tls.VersionTLS13,
+			config:  fipstls.Config{RecordPadding: 256},
+			check: func(lengths []int) error {
+				if len(lengths) == 0 {
+					return fmt.Errorf("no application data records")
+				}
+				for _, l := range lengths {
+					if (l-aeadTagLen)%256 != 0 {
+						return fmt.Errorf("record of %d bytes is not padded", l)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:    "MaxFragmentLength unsupported by server",
+			version: tls.VersionTLS13,
+			config:  fipstls.Config{MaxFragmentLength: 1024},
+			check:   func([]int) error { return nil },
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			connCh := make(chan *recordConn, 1)
+			errCh := make(chan error, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					errCh <- err
+					return
+				}
+				rc := &recordConn{Conn: conn}
+				connCh <- rc
+				tlsConn := tls.Server(rc, &tls.Config{
+					Certificates: []tls.Certificate{cert},
+					MinVersion:   tc.version,
+					MaxVersion:   tc.version,
+				})
+				defer tlsConn.Close()
+				_, err = io.CopyN(io.Discard, tlsConn, size)
+				if err == nil {
+					_, err = tlsConn.Write([]byte("ok"))
+				}
+				errCh <- err
+			}()
+
+			cfg := tc.config
+			cfg.CaFile = testutils.CertPath
+			conn := dialListener(t, ln, &cfg)
+			defer conn.Close()
+			if _, err := conn.Write(make([]byte, size)); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			b := make([]byte, 2)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if err := <-errCh; err != nil {
+				t.Fatalf("Server failed: %v", err)
+			}
+			if err := tc.check((<-connCh).recordLengths()); err != nil {
+				t.Error(err)
+			}
+			if got := conn.ConnectionState().MaxFragmentLength; got != 0 {
+				t.Errorf("MaxFragmentLength = %d, want 0", got)
+			}
+		})
+	}
+}
+
+// checkRecordLengths checks that the lengths of the records carrying size bytes fit limit bytes
+// of plaintext, and returns the length of the largest one.
+func checkRecordLengths(lengths []int, size, limit int) (int, error) {
+	if len(lengths) < size/limit {
+		return 0, fmt.Errorf("got %d records, want at least %d", len(lengths), size/limit)
+	}
+	largest := 0
+	for _, l := range lengths {
+		// Records are larger than their plaintext by the nonce, tag and content type.
+		if l > limit+64 {
+			return 0, fmt.Errorf("record of %d bytes exceeds %d bytes of plaintext", l, limit)
+		}
+		largest = max(largest, l)
+	}
+	return largest, nil
+}
+
+func TestRecordLayerServer(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	const size = 8000
+	for _, version := range []uint16{fipstls.Version12, fipstls.Version13} {
+		t.Run(fmt.Sprintf("MaxFragmentLength version %x", version), func(t *testing.T) {
+			client, server, clientIn, serverIn := recordConnPair(t,
+				&fipstls.Config{MaxFragmentLength: 1024}, newEngineServerConfig(version))
+			exchange(t, client, server, size)
+			for _, c := range []*fipstls.Conn{client, server} {
+				if got := c.ConnectionState().MaxFragmentLength; got != 1024 {
+					t.Errorf("MaxFragmentLength = %d, want 1024", got)
+				}
+			}
+			// The negotiated length limits the records sent in both directions.
+			if _, err := checkRecordLengths(clientIn.recordLengths(), size, 1024); err != nil {
+				t.Errorf("Server records: %v", err)
+			}
+			if _, err := checkRecordLengths(serverIn.recordLengths(), size, 1024); err != nil {
+				t.Errorf("Client records: %v", err)
+			}
+		})
+	}
+
+	t.Run("SplitSendFragment", func(t *testing.T) {
+		serverConfig := newEngineServerConfig(fipstls.Version13)
+		serverConfig.MaxSendFragment = 2048
+		serverConfig.SplitSendFragment = 512
+		client, server, clientIn, _ := recordConnPair(t, &fipstls.Config{}, serverConfig)
+		exchange(t, client, server, size)
+		// Writes are not pipelined without a cipher supporting it. Before OpenSSL 3.0 their
+		// records are then only limited by MaxSendFragment.
+		limit := 512
+		if strings.HasPrefix(libssl.VersionText(), "OpenSSL 1.") {
+			limit = 2048
+		}
+		largest, err := checkRecordLengths(clientIn.recordLengths(), size, limit)
+		if err != nil {
+			t.Fatalf("Server records: %v", err)
+		}
+		if largest <= limit/2 {
+			t.Errorf("Largest record is %d bytes, want records of %d bytes", largest, limit)
+		}
+	})
+}
+
+// recordConnPair returns a client and a server connected over TCP, and the record lengths read
+// by each.
+func recordConnPair(t *testing.T, clientConfig, serverConfig *fipstls.Config) (client,
+	server *fipstls.Conn, clientIn, serverIn *recordConn) {
+	t.Helper()
+	c1, c2 := newConnPair(t, "tcp")
+	clientIn, serverIn = &recordConn{Conn: c1}, &recordConn{Conn: c2}
+	clientConfig.CaFile = testutils.CertPath
+	clientConfig.ServerName = "localhost"
+	client = fipstls.Client(clientIn, clientConfig)
+	server = fipstls.Server(serverIn, serverConfig)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server, clientIn, serverIn
+}
+
+// exchange sends size bytes from client to server, and back.
+func exchange(t *testing.T, client, server *fipstls.Conn, size int) {
+	t.Helper()
+	errCh := make(chan error, 1)
+	go func() {
+		buf := make([]byte, size)
+		_, err := io.ReadFull(server, buf)
+		if err == nil {
+			_, err = server.Write(buf)
+		}
+		errCh <- err
+	}()
+	if _, err := client.Write(make([]byte, size)); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if _, err := io.ReadFull(client, make([]byte, size)); err != nil {
+		t.Fatalf("Failed to read: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("Server failed: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package fipstls
+
+import (
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// RenegotiationSupport enumerates the different levels of support for TLS renegotiation. TLS
+// renegotiation is the act of performing subsequent handshakes on a connection after the first.
+// This significantly complicates the state machine and has been the source of numerous, subtle
+// security issues. Initiating a renegotiation is not supported, but support for accepting
+// renegotiation requests from a server may be enabled.
+//
+// Even when enabled, the server may not change its identity between handshakes (i.e. the leaf
+// certificate must be the same). Additionally, concurrent handshake and application data flow
+// is not permitted so renegotiation can only be used with protocols that synchronise with the
+// renegotiation, such as HTTPS.
+//
+// Renegotiation is not defined in TLS 1.3.
+type RenegotiationSupport int
+
+const (
+	// RenegotiateNever disables renegotiation.
+	RenegotiateNever RenegotiationSupport = iota
+
+	// RenegotiateOnceAsClient allows a remote server to request renegotiation once per
+	// connection.
+	RenegotiateOnceAsClient
+
+	// RenegotiateFreelyAsClient allows a remote server to repeatedly request renegotiation.
+	RenegotiateFreelyAsClient
+)
+
+// renegotiation returns the renegotiation policy of a client.
+func (c *Config) renegotiation() RenegotiationSupport {
+	if c.RenegotiationDisabled || c.Method == ServerMethod {
+		return RenegotiateNever
+	}
+	return c.Renegotiation
+}
+
+// handshakeStart counts the HelloRequests sent by the server, and refuses renegotiation once
+// the policy is exhausted. It is called by libssl when a handshake starts, before the
+// HelloRequest is checked against SSL_OP_NO_RENEGOTIATION.
+func (c *Conn) handshakeStart() {
+	// The first handshake and TLS 1.3 post-handshake messages are not renegotiations.
+	if !c.handshakeComplete.Load() || libssl.SSLVersion(c.ssl) == Version13 {
+		return
+	}
+	c.renegotiations++
+	if c.config.renegotiation() == RenegotiateOnceAsClient && c.renegotiations > 1 {
+		c.l.Logf(LogLevelInfo, "Refusing renegotiation %d requested by server", c.renegotiations)
+		if err := libssl.SSLSetOptions(c.ssl, libssl.SSL_OP_NO_RENEGOTIATION); err != nil {
+			c.l.Logf(LogLevelErr, "Failed to refuse renegotiation: %v", err)
+		}
+		return
+	}
+	c.l.Logf(LogLevelInfo, "Renegotiation %d requested by server", c.renegotiations)
+}
@@ -0,0 +1,256 @@
+package fipstls_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// newLibsslPeer returns a TLS 1.2 SSL object blocking on conn, with the SSL_OP options. It is
+// driven with libssl directly, for the behaviours a [fipstls.Conn] never has, such as
+// initiating renegotiation.
+func newLibsslPeer(t *testing.T, conn net.Conn, server bool, options int64) *libssl.SSL {
+	t.Helper()
+	newMethod := libssl.NewTLSClientMethod
+	config := &libssl.CtxConfig{
+		MinTLS:     libssl.TLS1_2_VERSION,
+		MaxTLS:     libssl.TLS1_2_VERSION,
+		VerifyMode: libssl.SSL_VERIFY_NONE,
+		Options:    options,
+	}
+	if server {
+		newMethod = libssl.NewTLSServerMethod
+		config.CertFile, config.KeyFile = testutils.CertPath, testKeyPath
+	}
+	method, err := newMethod()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sslCtx, err := libssl.NewSSLCtx(method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { libssl.SSLCtxFree(sslCtx) })
+	if err := libssl.SSLCtxConfigure(sslCtx, config); err != nil {
+		t.Fatal(err)
+	}
+	ssl, err := libssl.NewSSL(sslCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { libssl.SSLFree(ssl) })
+	bio, err := libssl.NewGoBIO(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server {
+		err = libssl.SSLConfigureServerBIO(ssl, bio)
+	} else {
+		err = libssl.SSLConfigureBIO(ssl, bio, "")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ssl
+}
+
+// logRecorder is a [fipstls.Logger] keeping the messages logged at LogLevelInfo and above, or
+// at every level if debug is set.
+type logRecorder struct {
+	debug bool
+	mu    sync.Mutex
+	msgs  []string
+}
+
+func (r *logRecorder) Logf(level fipstls.LogLevel, format string, args ...any) {
+	if level > fipstls.LogLevelInfo && !r.debug {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, fmt.Sprintf(format, args...))
+}
+
+func (r *logRecorder) Wrap(string) fipstls.Logger { return r }
+
+// count returns the number of messages starting with prefix.
+func (r *logRecorder) count(prefix string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, msg := range r.msgs {
+		if strings.HasPrefix(msg, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// renegotiations is the number of renegotiations requested by the server of TestRenegotiation.
+const renegotiations = 3
+
+// serveRenegotiations requests a renegotiation before each of the replies to the client, and
+// returns the number of renegotiations completed before the client refused one.
+func serveRenegotiations(ssl *libssl.SSL) (int, error) {
+	if err := libssl.SSLDoHandshake(ssl); err != nil {
+		return 0, err
+	}
+	for i := 0; i < renegotiations; i++ {
+		// The HelloRequest is sent by the handshake, which the client answers while reading
+		// the reply. The renegotiation completes while reading the next request.
+		if err := libssl.SSLRenegotiate(ssl); err != nil {
+			return i, err
+		}
+		if err := libssl.SSLDoHandshake(ssl); err != nil {
+			return i, err
+		}
+		if _, err := libssl.SSLWriteEx(ssl, []byte("pong")); err != nil {
+			return i, err
+		}
+		buf := make([]byte, 4)
+		if _, err := libssl.SSLReadEx(ssl, buf); err != nil {
+			return i, err
+		}
+	}
+	return renegotiations, nil
+}
+
+func TestRenegotiation(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	for _, tc := range []struct {
+		name    string
+		support fipstls.RenegotiationSupport
+		// want is the number of renegotiations the client accepts before refusing one.
+		want int
+	}{
+		{name: "Never", support: fipstls.RenegotiateNever, want: 0},
+		{name: "Once", support: fipstls.RenegotiateOnceAsClient, want: 1},
+		{name: "Freely", support: fipstls.RenegotiateFreelyAsClient, want: renegotiations},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c1, c2 := newConnPair(t, "tcp")
+			c2.SetDeadline(time.Now().Add(10 * time.Second))
+			server := newLibsslPeer(t, c2, true, 0)
+			type result struct {
+				n   int
+				err error
+			}
+			results := make(chan result, 1)
+			go func() {
+				// Unblock the client once the server is done.
+				defer c2.Close()
+				n, err := serveRenegotiations(server)
+				results <- result{n, err}
+			}()
+
+			config := &fipstls.Config{
+				InsecureSkipVerify: true,
+				MaxTLSVersion:      fipstls.Version12,
+				Renegotiation:      tc.support,
+			}
+			ctx, err := fipstls.NewCtx(config)
+			if err != nil {
+				t.Fatalf("NewCtx() failed: %v", err)
+			}
+			defer ctx.Close()
+			bio, err := fipstls.NewConnBIO(c1)
+			if err != nil {
+				t.Fatalf("NewConnBIO() failed: %v", err)
+			}
+			logs := &logRecorder{}
+			client, err := fipstls.NewConn(ctx, bio, config, logs)
+			if err != nil {
+				bio.Close()
+				t.Fatalf("NewConn() failed: %v", err)
+			}
+			defer client.Close()
+			client.SetDeadline(time.Now().Add(10 * time.Second))
+			for {
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(client, buf); err != nil {
+					break
+				}
+				if _, err := client.Write([]byte("ping")); err != nil {
+					break
+				}
+			}
+
+			res := <-results
+			if res.n != tc.want {
+				t.Errorf("Renegotiations = %d, want %d", res.n, tc.want)
+			}
+			if refused := tc.want < renegotiations; refused != (res.err != nil) {
+				t.Errorf("Server err = %v, want refused = %v", res.err, refused)
+			}
+			// Servers requesting more are counted until the policy is exhausted.
+			if got := logs.count("Renegotiation "); got != tc.want {
+				t.Errorf("Logged %d renegotiations, want %d", got, tc.want)
+			}
+			wantRefusals := 0
+			if tc.support == fipstls.RenegotiateOnceAsClient {
+				wantRefusals = 1
+			}
+			if got := logs.count("Refusing renegotiation 2 "); got != wantRefusals {
+				t.Errorf("Logged %d refusals of the second renegotiation, want %d", got,
+					wantRefusals)
+			}
+		})
+	}
+}
+
+// TestServerRefusesRenegotiation checks that servers refuse renegotiation initiated by the
+// client. Since OpenSSL 3.0, libssl refuses it too unless SSL_OP_ALLOW_CLIENT_RENEGOTIATION is
+// set, so only earlier releases depend on SSL_OP_NO_RENEGOTIATION.
+func TestServerRefusesRenegotiation(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	c1, c2 := newConnPair(t, "tcp")
+	c1.SetDeadline(time.Now().Add(10 * time.Second))
+	server := fipstls.Server(c2, &fipstls.Config{
+		CertFile:      testutils.CertPath,
+		KeyFile:       testKeyPath,
+		MaxTLSVersion: fipstls.Version12,
+	})
+	defer server.Close()
+	server.SetDeadline(time.Now().Add(10 * time.Second))
+	errCh := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(server, buf); err != nil {
+			errCh <- err
+			return
+		}
+		// The renegotiation is refused while reading, which fails once the client gives up.
+		_, err := server.Read(buf)
+		errCh <- err
+	}()
+
+	client := newLibsslPeer(t, c1, false, 0)
+	if err := libssl.SSLConnect(client); err != nil {
+		t.Fatalf("SSLConnect() failed: %v", err)
+	}
+	if _, err := libssl.SSLWriteEx(client, []byte("ping")); err != nil {
+		t.Fatalf("SSLWriteEx() failed: %v", err)
+	}
+	if err := libssl.SSLRenegotiate(client); err != nil {
+		t.Fatalf("SSLRenegotiate() failed: %v", err)
+	}
+	if err := libssl.SSLDoHandshake(client); err == nil {
+		t.Error("Renegotiation initiated by the client succeeded")
+	}
+	c1.Close()
+	if err := <-errCh; err == nil {
+		t.Error("Server Read() succeeded after refusing renegotiation")
+	}
+}
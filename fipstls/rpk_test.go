@@ -0,0 +1,169 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// parseCertFile parses the first certificate in the PEM encoded certFile.
+func parseCertFile(t *testing.T, certFile string) *x509.Certificate {
+	t.Helper()
+	b, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatalf("No PEM data in %s", certFile)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestRawPublicKeys(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpkTypes := []fipstls.CertificateType{
+		fipstls.CertificateTypeRawPublicKey,
+		fipstls.CertificateTypeX509,
+	}
+
+	if !libssl.SupportsRPK() {
+		ctx, err := fipstls.NewCtx(&fipstls.Config{ServerCertificateTypes: rpkTypes})
+		if ctx != nil {
+			ctx.Close()
+		}
+		if !errors.Is(err, fipstls.ErrRawPublicKeyUnsupported) {
+			t.Fatalf("NewCtx() err = %v, want %v", err, fipstls.ErrRawPublicKeyUnsupported)
+		}
+		t.Skipf("Raw public keys are not supported by %s", libssl.VersionText())
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := x509.MarshalPKIXPublicKey(&other.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crypto/tls servers only send certificates, whose key is matched against the expected keys.
+	testCases := []struct {
+		name    string
+		keys    [][]byte
+		wantErr bool
+	}{
+		{name: "Matching key", keys: [][]byte{otherKey, parseCertFile(t, ts.CaFile).RawSubjectPublicKeyInfo}},
+		{name: "Mismatched key", keys: [][]byte{otherKey}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := fipstls.NewDialer(&fipstls.Config{
+				CaFile:                 ts.CaFile,
+				ServerCertificateTypes: rpkTypes,
+				PeerPublicKeys:         tc.keys,
+			}, getFipsDialOpts()...)
+			conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("DialContext() err = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			if key := conn.(*fipstls.Conn).ConnectionState().PeerRawPublicKey; key != nil {
+				t.Errorf("PeerRawPublicKey = %x, want nil", key)
+			}
+		})
+	}
+}
+
+func TestRawPublicKeyHandshake(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	if !libssl.SupportsRPK() {
+		t.Skipf("Raw public keys are not supported by %s", libssl.VersionText())
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := x509.MarshalPKIXPublicKey(&other.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverKey := parseCertFile(t, testutils.CertPath).RawSubjectPublicKeyInfo
+	rpkOnly := []fipstls.CertificateType{fipstls.CertificateTypeRawPublicKey}
+
+	testCases := []struct {
+		name    string
+		keys    [][]byte
+		wantErr bool
+	}{
+		{name: "Matching key", keys: [][]byte{otherKey, serverKey}},
+		{name: "Mismatched key", keys: [][]byte{otherKey}, wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c1, c2 := newConnPair(t, "tcp")
+			deadline := time.Now().Add(10 * time.Second)
+			server := fipstls.Server(c2, &fipstls.Config{
+				CertFile:               testutils.CertPath,
+				KeyFile:                testKeyPath,
+				ServerCertificateTypes: rpkOnly,
+			})
+			errCh := runServer(server, func(conn *fipstls.Conn) error {
+				return conn.Handshake(deadline)
+			})
+			// No CaFile is needed, the key alone authenticates the server.
+			client := fipstls.Client(c1, &fipstls.Config{
+				ServerName:             "localhost",
+				ServerCertificateTypes: rpkOnly,
+				PeerPublicKeys:         tc.keys,
+			})
+			defer client.Close()
+			err := client.Handshake(deadline)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Handshake() err = %v, wantErr %v", err, tc.wantErr)
+			}
+			serverErr := <-errCh
+			if tc.wantErr {
+				if serverErr == nil {
+					t.Error("Server handshake succeeded with a mismatched key")
+				}
+				return
+			}
+			if serverErr != nil {
+				t.Fatalf("Server handshake failed: %v", serverErr)
+			}
+			if got := client.ConnectionState().PeerRawPublicKey; !bytes.Equal(got, serverKey) {
+				t.Errorf("PeerRawPublicKey = %x, want %x", got, serverKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package fipstls
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// ClientSessionState contains the state needed by a client to resume a previous TLS session.
+// The session is stored in its serialized form so it holds no libssl resources.
+type ClientSessionState struct {
+	session      []byte
+	maxEarlyData uint32
+}
+
+// MaxEarlyData returns the maximum amount of early data the server will accept when the
+// session is resumed. Zero means the session does not permit early data.
+func (s *ClientSessionState) MaxEarlyData() uint32 {
+	return s.maxEarlyData
+}
+
+// ClientSessionCache is a cache of [ClientSessionState] objects that can be used by a client to
+// resume a TLS session with a given server. ClientSessionCache implementations should expect to
+// be called concurrently from different goroutines.
+type ClientSessionCache interface {
+	// Get searches for a ClientSessionState associated with the given key.
+	// On return, ok is true if one was found.
+	Get(sessionKey string) (session *ClientSessionState, ok bool)
+
+	// Put adds the ClientSessionState to the cache with the given key. A nil
+	// session removes the entry for the key.
+	Put(sessionKey string, cs *ClientSessionState)
+}
+
+// newClientSessionState serializes the session currently held by ssl. It returns nil if there is
+// no session that can be resumed.
+func newClientSessionState(ssl *libssl.SSL) (*ClientSessionState, error) {
+	sess, err := libssl.SSLGet1Session(ssl)
+	if err != nil {
+		return nil, err
+	}
+	defer libssl.SSLSessionFree(sess)
+	if !libssl.SSLSessionIsResumable(sess) {
+		return nil, nil
+	}
+	der, err := libssl.I2DSSLSession(sess)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientSessionState{
+		session:      der,
+		maxEarlyData: libssl.SSLSessionGetMaxEarlyData(sess),
+	}, nil
+}
+
+// setSession offers the cached session to the peer when ssl connects.
+func (s *ClientSessionState) setSession(ssl *libssl.SSL) error {
+	sess, err := libssl.D2ISSLSession(s.session)
+	if err != nil {
+		return err
+	}
+	// SSL_set_session takes its own reference
+	defer libssl.SSLSessionFree(sess)
+	return libssl.SSLSetSession(ssl, sess)
+}
+
+type lruSessionCache struct {
+	sync.Mutex
+
+	m        map[string]*list.Element
+	q        *list.List
+	capacity int
+}
+
+type lruSessionCacheEntry struct {
+	sessionKey string
+	state      *ClientSessionState
+}
+
+// NewLRUClientSessionCache returns a [ClientSessionCache] with the given capacity that uses an
+// LRU strategy. If capacity is < 1, a default capacity is used instead.
+func NewLRUClientSessionCache(capacity int) ClientSessionCache {
+	const defaultSessionCacheCapacity = 64
+
+	if capacity < 1 {
+		capacity = defaultSessionCacheCapacity
+	}
+	return &lruSessionCache{
+		m:        make(map[string]*list.Element),
+		q:        list.New(),
+		capacity: capacity,
+	}
+}
+
+// Put adds the provided (sessionKey, cs) pair to the cache. If cs is nil, the entry
+// corresponding to sessionKey is removed from the cache instead.
+func (c *lruSessionCache) Put(sessionKey string, cs *ClientSessionState) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.m[sessionKey]; ok {
+		if cs == nil {
+			c.q.Remove(elem)
+			delete(c.m, sessionKey)
+		} else {
+			entry := elem.Value.(*lruSessionCacheEntry)
+			entry.state = cs
+			c.q.MoveToFront(elem)
+		}
+		return
+	}
+
+	if cs == nil {
+		return
+	}
+
+	if c.q.Len() < c.capacity {
+		entry := &lruSessionCacheEntry{sessionKey, cs}
+		c.m[sessionKey] = c.q.PushFront(entry)
+		return
+	}
+
+	elem := c.q.Back()
+	entry := elem.Value.(*lruSessionCacheEntry)
+	delete(c.m, entry.sessionKey)
+	entry.sessionKey = sessionKey
+	entry.state = cs
+	c.q.MoveToFront(elem)
+	c.m[sessionKey] = elem
+}
+
+// Get returns the [ClientSessionState] value associated with a given key. It
+// returns (nil, false) if no value is found.
+func (c *lruSessionCache) Get(sessionKey string) (*ClientSessionState, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if elem, ok := c.m[sessionKey]; ok {
+		c.q.MoveToFront(elem)
+		return elem.Value.(*lruSessionCacheEntry).state, true
+	}
+	return nil, false
+}
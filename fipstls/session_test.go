@@ -0,0 +1,407 @@
+package fipstls_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// getRequest writes a GET request to conn and checks the response status line.
+func getRequest(t *testing.T, conn net.Conn, host string) {
+	t.Helper()
+	request := fmt.Sprintf("GET /get HTTP/1.1\r\nHost: %s\r\n\r\n", host)
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("Failed to write request: %v", err)
+	}
+	readResponse(t, conn)
+}
+
+func readResponse(t *testing.T, conn net.Conn) {
+	t.Helper()
+	response, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if !strings.Contains(response, "200 OK") {
+		t.Fatalf("Unexpected response: %s", response)
+	}
+}
+
+func TestSessionResumption(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, version := range []uint16{fipstls.Version12, fipstls.Version13} {
+		t.Run(fmt.Sprintf("version %x", version), func(t *testing.T) {
+			d := fipstls.NewDialer(&fipstls.Config{
+				CaFile:             ts.CaFile,
+				MaxTLSVersion:      version,
+				ClientSessionCache: fipstls.NewLRUClientSessionCache(0),
+			}, getFipsDialOpts()...)
+			for i := 0; i < 2; i++ {
+				conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+				if err != nil {
+					t.Fatalf("Failed to dial: %v", err)
+				}
+				// TLS 1.3 session tickets are received after the handshake
+				getRequest(t, conn, u.Host)
+				state := conn.(*fipstls.Conn).ConnectionState()
+				if err := conn.Close(); err != nil {
+					t.Fatalf("Failed to close: %v", err)
+				}
+				if !state.HandshakeComplete {
+					t.Error("HandshakeComplete = false, want true")
+				}
+				if state.Version != version {
+					t.Errorf("Version = %x, want %x", state.Version, version)
+				}
+				if wantResume := i > 0; state.DidResume != wantResume {
+					t.Errorf("Connection %d: DidResume = %v, want %v", i, state.DidResume,
+						wantResume)
+				}
+			}
+		})
+	}
+}
+
+func TestSessionCacheDisabled(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := fipstls.NewLRUClientSessionCache(0)
+	d := fipstls.NewDialer(&fipstls.Config{
+		CaFile:               ts.CaFile,
+		ClientSessionCache:   cache,
+		SessionCacheDisabled: true,
+	}, getFipsDialOpts()...)
+	conn, err := d.DialContext(context.Background(), "tcp", u.Host)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	getRequest(t, conn, u.Host)
+	conn.Close()
+	if _, ok := cache.Get(u.Host); ok {
+		t.Error("Session was cached with SessionCacheDisabled")
+	}
+}
+
+func TestWriteEarlyData(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := []byte(fmt.Sprintf("GET /get HTTP/1.1\r\nHost: %s\r\n\r\n", u.Host))
+
+	t.Run("No session", func(t *testing.T) {
+		cfg := &fipstls.Config{CaFile: ts.CaFile}
+		bio, err := fipstls.NewBIO(u.Host, "tcp", fipstls.SOCK_NONBLOCK)
+		if err != nil {
+			t.Fatalf("Failed to create BIO: %v", err)
+		}
+		ctx, err := fipstls.NewCtx(cfg)
+		if err != nil {
+			bio.Close()
+			t.Fatalf("Failed to create context: %v", err)
+		}
+		defer ctx.Close()
+		conn, err := fipstls.NewConn(ctx, bio, cfg, nil)
+		if err != nil {
+			bio.Close()
+			t.Fatalf("Failed to create conn: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.WriteEarlyData(request); !errors.Is(err, fipstls.ErrEarlyDataUnavailable) {
+			t.Fatalf("WriteEarlyData() err = %v, want %v", err, fipstls.ErrEarlyDataUnavailable)
+		}
+		if err := conn.Handshake(time.Now().Add(10 * time.Second)); err != nil {
+			t.Fatalf("Handshake failed: %v", err)
+		}
+		if _, err := conn.WriteEarlyData(request); !errors.Is(err, fipstls.ErrHandshakeComplete) {
+			t.Fatalf("WriteEarlyData() err = %v, want %v", err, fipstls.ErrHandshakeComplete)
+		}
+	})
+
+	t.Run("Dial early without 0-RTT support", func(t *testing.T) {
+		// crypto/tls servers never permit early data, so the request is sent after the
+		// handshake on the resumed connection.
+		d := fipstls.NewDialer(&fipstls.Config{
+			CaFile:             ts.CaFile,
+			ClientSessionCache: fipstls.NewLRUClientSessionCache(0),
+		}, getFipsDialOpts()...)
+		for i := 0; i < 2; i++ {
+			conn, err := d.DialEarlyContext(context.Background(), "tcp", u.Host, request)
+			if err != nil {
+				t.Fatalf("Failed to dial: %v", err)
+			}
+			readResponse(t, conn)
+			state := conn.(*fipstls.Conn).ConnectionState()
+			conn.Close()
+			if state.EarlyData != fipstls.EarlyDataNotSent {
+				t.Errorf("EarlyData = %v, want %v", state.EarlyData, fipstls.EarlyDataNotSent)
+			}
+			if wantResume := i > 0; state.DidResume != wantResume {
+				t.Errorf("Connection %d: DidResume = %v, want %v", i, state.DidResume, wantResume)
+			}
+		}
+	})
+}
+
+// earlyDataResult is what the server of TestServerEarlyData received on a connection.
+type earlyDataResult struct {
+	data      []byte
+	earlyData fipstls.EarlyDataStatus
+	err       error
+}
+
+func TestServerEarlyData(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	serverConfig := newEngineServerConfig(fipstls.Version13)
+	serverConfig.MaxEarlyData = 1024
+	// Sessions are only resumed, and early data accepted, by connections sharing a Context.
+	newServerCtx := func() *fipstls.Context {
+		ctx, err := fipstls.NewCtx(serverConfig)
+		if err != nil {
+			t.Fatalf("NewCtx() failed: %v", err)
+		}
+		t.Cleanup(func() { ctx.Close() })
+		return ctx
+	}
+	var serverCtx atomic.Pointer[fipstls.Context]
+	serverCtx.Store(newServerCtx())
+
+	request := []byte("GET / HTTP/1.1\r\n\r\n")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	results := make(chan earlyDataResult, 1)
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			results <- serveEarlyData(serverCtx.Load(), nc, serverConfig, len(request))
+		}
+	}()
+
+	d := fipstls.NewDialer(&fipstls.Config{
+		CaFile:             testutils.CertPath,
+		ServerName:         "localhost",
+		MinTLSVersion:      fipstls.Version13,
+		ClientSessionCache: fipstls.NewLRUClientSessionCache(0),
+	}, getFipsDialOpts()...)
+	defer d.Close()
+	for _, tc := range []struct {
+		name       string
+		newCtx     bool
+		wantResume bool
+		want       fipstls.EarlyDataStatus
+	}{
+		// Without a session, DialEarlyContext writes the request after the handshake.
+		{name: "No session", want: fipstls.EarlyDataNotSent},
+		{name: "Accepted", wantResume: true, want: fipstls.EarlyDataAccepted},
+		// A server with another Context does not know the session, so it rejects the early
+		// data and the client replays it.
+		{name: "Rejected", newCtx: true, want: fipstls.EarlyDataRejected},
+	} {
+		if tc.newCtx {
+			serverCtx.Store(newServerCtx())
+		}
+		conn, err := d.DialEarlyContext(context.Background(), "tcp", ln.Addr().String(), request)
+		if err != nil {
+			t.Fatalf("%s: Failed to dial: %v", tc.name, err)
+		}
+		buf := make([]byte, 2)
+		_, err = io.ReadFull(conn, buf)
+		state := conn.(*fipstls.Conn).ConnectionState()
+		conn.Close()
+		if err != nil {
+			t.Fatalf("%s: Failed to read response: %v", tc.name, err)
+		}
+		res := <-results
+		if res.err != nil {
+			t.Fatalf("%s: Server failed: %v", tc.name, res.err)
+		}
+		if !bytes.Equal(res.data, request) {
+			t.Errorf("%s: Server received %q, want %q", tc.name, res.data, request)
+		}
+		if state.EarlyData != tc.want || res.earlyData != tc.want {
+			t.Errorf("%s: EarlyData = %v, server %v, want %v", tc.name, state.EarlyData,
+				res.earlyData, tc.want)
+		}
+		if state.DidResume != tc.wantResume {
+			t.Errorf("%s: DidResume = %v, want %v", tc.name, state.DidResume, tc.wantResume)
+		}
+	}
+}
+
+// serveEarlyData reads a request of n bytes from a server Conn over nc, including the early
+// data it accepts, and answers "ok".
+func serveEarlyData(ctx *fipstls.Context, nc net.Conn, config *fipstls.Config,
+	n int) earlyDataResult {
+	bio, err := fipstls.NewConnBIO(nc)
+	if err != nil {
+		nc.Close()
+		return earlyDataResult{err: err}
+	}
+	conn, err := fipstls.NewConn(ctx, bio, config, nil)
+	if err != nil {
+		bio.Close()
+		return earlyDataResult{err: err}
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	data := make([]byte, n)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return earlyDataResult{err: err}
+	}
+	// Nothing else arrives, the request was not received twice.
+	conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if extra, err := conn.Read(make([]byte, 1)); extra > 0 || !errors.Is(err, os.ErrDeadlineExceeded) {
+		return earlyDataResult{err: fmt.Errorf("read %d more bytes, err = %v", extra, err)}
+	}
+	conn.SetReadDeadline(time.Time{})
+	if _, err := conn.Write([]byte("ok")); err != nil {
+		return earlyDataResult{err: err}
+	}
+	return earlyDataResult{data: data, earlyData: conn.ConnectionState().EarlyData}
+}
+
+// TestEarlyDataReplayFailure checks that the connection fails for good if the early data
+// rejected by the server cannot be resent.
+func TestEarlyDataReplayFailure(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	serverConfig := newEngineServerConfig(fipstls.Version13)
+	serverConfig.MaxEarlyData = 1024
+	request := []byte("GET / HTTP/1.1\r\n\r\n")
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	results := make(chan earlyDataResult, 1)
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Every connection has a Context of its own, which rejects the early data.
+			ctx, err := fipstls.NewCtx(serverConfig)
+			if err != nil {
+				nc.Close()
+				results <- earlyDataResult{err: err}
+				continue
+			}
+			results <- serveEarlyData(ctx, nc, serverConfig, len(request))
+			ctx.Close()
+		}
+	}()
+
+	config := &fipstls.Config{
+		CaFile:             testutils.CertPath,
+		ServerName:         "localhost",
+		MinTLSVersion:      fipstls.Version13,
+		ClientSessionCache: fipstls.NewLRUClientSessionCache(0),
+	}
+	ctx, err := fipstls.NewCtx(config)
+	if err != nil {
+		t.Fatalf("NewCtx() failed: %v", err)
+	}
+	defer ctx.Close()
+	newConn := func() *fipstls.Conn {
+		t.Helper()
+		nc, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		bio, err := fipstls.NewConnBIO(nc)
+		if err != nil {
+			nc.Close()
+			t.Fatalf("NewConnBIO() failed: %v", err)
+		}
+		conn, err := fipstls.NewConn(ctx, bio, config, nil)
+		if err != nil {
+			bio.Close()
+			t.Fatalf("NewConn() failed: %v", err)
+		}
+		return conn
+	}
+
+	// The first connection caches a session permitting early data.
+	conn := newConn()
+	if _, err := conn.Write(request); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 2)); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	conn.Close()
+	if res := <-results; res.err != nil {
+		t.Fatalf("Server failed: %v", res.err)
+	}
+
+	conn = newConn()
+	defer conn.Close()
+	if _, err := conn.WriteEarlyData(request); err != nil {
+		t.Fatalf("WriteEarlyData() failed: %v", err)
+	}
+	// The handshake completes, but the expired write deadline fails the replay.
+	conn.SetWriteDeadline(time.Unix(1, 0))
+	err = conn.Handshake(time.Now().Add(10 * time.Second))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Handshake() err = %v, want %v", err, os.ErrDeadlineExceeded)
+	}
+	conn.SetWriteDeadline(time.Time{})
+	if _, err := conn.Write(request); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write() after the failed replay err = %v, want %v", err,
+			os.ErrDeadlineExceeded)
+	}
+	if _, err := conn.Read(make([]byte, 2)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read() after the failed replay err = %v, want %v", err,
+			os.ErrDeadlineExceeded)
+	}
+	if state := conn.ConnectionState(); state.EarlyData != fipstls.EarlyDataRejected {
+		t.Errorf("EarlyData = %v, want %v", state.EarlyData, fipstls.EarlyDataRejected)
+	}
+	conn.Close()
+	// The server never receives the request.
+	if res := <-results; res.err == nil {
+		t.Errorf("Server received %q", res.data)
+	}
+}
@@ -70,18 +70,17 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	}
 	address := net.JoinHostPort(req.URL.Hostname(), port)
 
+	// Handle case where there are concurrent requests made using the same Dialer
+	t.Lock()
 	// If the caller didn't configure a dialer, use the default one
 	if t.Dialer == nil {
 		t.Dialer = NewDialer(nil)
 	}
-
-	// Handle case where there are concurrent requests made using the same Dialer
-	t.Lock()
 	conn, err := t.Dialer.DialContext(req.Context(), "tcp", address)
+	t.Unlock()
 	if err != nil {
 		return nil, err
 	}
-	t.Unlock()
 
 	if deadline, ok := req.Context().Deadline(); ok {
 		conn.SetDeadline(deadline)
@@ -119,6 +118,18 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+// CloseIdleConnections releases the [Context] shared by the connections of the [Dialer]. The
+// Transport dials a new connection for every request and keeps no idle connections, so
+// connections still in use keep their reference to the context. The next request creates a new
+// one. It is called by [http.Client.CloseIdleConnections].
+func (t *Transport) CloseIdleConnections() {
+	t.Lock()
+	defer t.Unlock()
+	if t.Dialer != nil {
+		t.Dialer.releaseCtx()
+	}
+}
+
 // gzipReaderWithConnClose is a custom io.ReadCloser that closes the
 // underlying gzip reader and the connection
 type gzipReaderWithClose struct {
@@ -76,3 +76,28 @@ func createRequests(t *testing.T, ts *testutils.TestServer, client *http.Client)
 		}
 	}
 }
+
+func TestTransportCloseIdleConnections(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+	ts := testutils.NewServer(t, *enableServerTrace)
+	defer ts.Close()
+
+	client := fipstls.NewClient(&fipstls.Config{CaFile: ts.CaFile}, getFipsDialOpts()...)
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(ts.URL)
+		if err != nil {
+			t.Fatalf("Get() failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if _, err := io.ReadAll(resp.Body); err != nil {
+			t.Fatalf("Failed to read body: %v", err)
+		}
+	}
+	get()
+	// Releases the context of the Dialer, and the next request creates a new one.
+	client.CloseIdleConnections()
+	get()
+	client.CloseIdleConnections()
+}
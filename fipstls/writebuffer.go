@@ -0,0 +1,243 @@
+package fipstls
+
+import (
+	"net"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/libssl"
+)
+
+// WriteCoalescing buffers small writes and sends them together, so that protocols issuing many
+// small writes, such as HTTP/2 and gRPC, send fewer and fuller TLS records. Buffered data is
+// sent once Threshold bytes are buffered, Delay after the first buffered write, before a Read,
+// and by [Conn.Flush] and [Conn.Close].
+type WriteCoalescing struct {
+	// Threshold is the number of buffered bytes that are sent at once. Writes of at least
+	// Threshold bytes are not buffered. It defaults to the maximum record size of 16384 bytes.
+	Threshold int
+
+	// Delay is the longest time written data is buffered. Zero disables write coalescing.
+	Delay time.Duration
+}
+
+func (p WriteCoalescing) enabled() bool {
+	return p.Delay > 0
+}
+
+func (p WriteCoalescing) threshold() int {
+	if p.Threshold > 0 {
+		return p.Threshold
+	}
+	return maxFragmentLen
+}
+
+// writeBuffersSize is the most data [Conn.WriteBuffers] packs into a single write.
+const writeBuffersSize = 4 * maxFragmentLen
+
+// enableWriteCoalescing sets the write coalescing policy of the [Conn]. Flushes may send part
+// of the buffer and keep the rest, so libssl is told to report the records written by each
+// write and to accept the buffer being moved between retries.
+func (c *Conn) enableWriteCoalescing(p WriteCoalescing) error {
+	if !p.enabled() {
+		return nil
+	}
+	if err := libssl.SSLSetMode(c.ssl, libssl.SSL_MODE_ENABLE_PARTIAL_WRITE|
+		libssl.SSL_MODE_ACCEPT_MOVING_WRITE_BUFFER); err != nil {
+		return err
+	}
+	c.writeCoalescing = p
+	return nil
+}
+
+// writeAll writes all of b. libssl may write only part of b in partial write mode. The caller
+// must hold c.out.
+func (c *Conn) writeAll(b []byte) (int, error) {
+	var written int
+	for written < len(b) {
+		n, err := c.doIO(b[written:], c.write, opWrite)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if err := c.countWritten(n); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// bufferWrite buffers b, and sends the buffered data once it reaches the threshold of the
+// [WriteCoalescing] policy. The caller must hold c.out.
+func (c *Conn) bufferWrite(b []byte) (int, error) {
+	threshold := c.writeCoalescing.threshold()
+	if len(c.wbuf)+len(b) < threshold {
+		if len(c.wbuf) == 0 {
+			c.startFlushTimer()
+		}
+		c.wbuf = append(c.wbuf, b...)
+		return len(b), nil
+	}
+	// Fill the buffer up to the threshold and send it, then send or buffer the rest. The data
+	// that was buffered stays buffered if sending it fails.
+	var n int
+	if len(c.wbuf) > 0 {
+		n = threshold - len(c.wbuf)
+		c.wbuf = append(c.wbuf, b[:n]...)
+		if err := c.flush(); err != nil {
+			return n, err
+		}
+	}
+	rest := b[n:]
+	if len(rest) >= threshold {
+		written, err := c.writeAll(rest)
+		return n + written, err
+	}
+	if len(rest) > 0 {
+		c.startFlushTimer()
+		c.wbuf = append(c.wbuf, rest...)
+	}
+	return len(b), nil
+}
+
+// flush sends the buffered data. Data that could not be sent stays buffered. The caller must
+// hold c.out.
+func (c *Conn) flush() error {
+	if len(c.wbuf) == 0 {
+		return nil
+	}
+	n, err := c.writeAll(c.wbuf)
+	c.wbuf = c.wbuf[:copy(c.wbuf, c.wbuf[n:])]
+	if err != nil {
+		c.l.Logf(LogLevelErr, "Flush failed with %d bytes buffered: %v", len(c.wbuf), err)
+	}
+	return err
+}
+
+// Flush sends the data buffered by the [WriteCoalescing] policy.
+func (c *Conn) Flush() error {
+	if err := c.beginCall(); err != nil {
+		return err
+	}
+	defer c.endCall()
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closeNotifySent {
+		return ErrShutdown
+	}
+	return c.flush()
+}
+
+// startFlushTimer arms the timer that flushes the buffered data after the delay of the
+// [WriteCoalescing] policy. The caller must hold c.out.
+func (c *Conn) startFlushTimer() {
+	if t := c.flushTimer.Load(); t != nil {
+		t.Reset(c.writeCoalescing.Delay)
+		return
+	}
+	c.flushTimer.Store(time.AfterFunc(c.writeCoalescing.Delay, c.scheduledFlush))
+}
+
+// scheduledFlush sends the buffered data once the delay expires. It is not a call of its own,
+// so that a racing Close still sends the buffered data and the close_notify, and it is delayed
+// again while c.out is held.
+func (c *Conn) scheduledFlush() {
+	if !c.out.TryLock() {
+		if t := c.flushTimer.Load(); t != nil {
+			t.Reset(c.writeCoalescing.Delay)
+		}
+		return
+	}
+	defer c.out.Unlock()
+	if c.closeNotifySent || c.closed.Load() {
+		return
+	}
+	if err := c.flush(); err != nil && !c.closed.Load() {
+		c.l.Logf(LogLevelErr, "Scheduled flush failed: %v", err)
+	}
+}
+
+// stopFlushTimer stops the flush timer.
+func (c *Conn) stopFlushTimer() {
+	if t := c.flushTimer.Swap(nil); t != nil {
+		t.Stop()
+	}
+}
+
+// flushBeforeRead sends the buffered data unless a write is in progress, so that a request is
+// not held back while its response is awaited.
+func (c *Conn) flushBeforeRead() {
+	if !c.writeCoalescing.enabled() || !c.out.TryLock() {
+		return
+	}
+	defer c.out.Unlock()
+	if !c.closeNotifySent {
+		c.flush()
+	}
+}
+
+// WriteBuffers writes the contents of v, packing consecutive buffers into large writes so that
+// small buffers share full-size records, and consumes the bytes written from v like
+// [net.Buffers.WriteTo].
+func (c *Conn) WriteBuffers(v *net.Buffers) (int64, error) {
+	if err := c.beginCall(); err != nil {
+		return 0, err
+	}
+	defer c.endCall()
+	if err := c.handshakeFor(opWrite); err != nil {
+		return 0, err
+	}
+	c.out.Lock()
+	defer c.out.Unlock()
+	if c.closeNotifySent {
+		return 0, ErrShutdown
+	}
+	var written int64
+	for len(*v) > 0 {
+		var n int
+		var err error
+		if c.writeCoalescing.enabled() {
+			n, err = c.bufferWrite((*v)[0])
+		} else if len((*v)[0]) >= writeBuffersSize {
+			n, err = c.writeAll((*v)[0])
+		} else {
+			n, err = c.writeAll(c.packBuffers(*v))
+		}
+		written += int64(n)
+		consumeBuffers(v, int64(n))
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// packBuffers copies the leading buffers of v into a single buffer of up to writeBuffersSize
+// bytes. The caller must hold c.out.
+func (c *Conn) packBuffers(v net.Buffers) []byte {
+	if c.packBuf == nil {
+		c.packBuf = make([]byte, 0, writeBuffersSize)
+	}
+	b := c.packBuf[:0]
+	for _, buf := range v {
+		n := min(len(buf), writeBuffersSize-len(b))
+		b = append(b, buf[:n]...)
+		if len(b) == writeBuffersSize {
+			break
+		}
+	}
+	return b
+}
+
+// consumeBuffers removes the first n bytes from v.
+func consumeBuffers(v *net.Buffers, n int64) {
+	for len(*v) > 0 {
+		l := int64(len((*v)[0]))
+		if l > n {
+			(*v)[0] = (*v)[0][n:]
+			return
+		}
+		n -= l
+		(*v)[0] = nil
+		*v = (*v)[1:]
+	}
+}
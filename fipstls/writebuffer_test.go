@@ -0,0 +1,257 @@
+package fipstls_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/aristanetworks/go-openssl-fips/fipstls"
+	"github.com/aristanetworks/go-openssl-fips/fipstls/internal/testutils"
+)
+
+// serveRecords accepts a single TLS 1.2 connection on ln, and returns the connection recording
+// the records sent by the client and everything read from it until EOF. TLS 1.2 is used as the
+// Finished message of a TLS 1.3 client would be recorded as application data.
+func serveRecords(t *testing.T, ln net.Listener) (<-chan *recordConn, <-chan []byte) {
+	t.Helper()
+	cert, err := tls.LoadX509KeyPair(testutils.CertPath, testKeyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connCh := make(chan *recordConn, 1)
+	dataCh := make(chan []byte, 1)
+	go func() {
+		defer close(dataCh)
+		conn, err := ln.Accept()
+		if err != nil {
+			close(connCh)
+			return
+		}
+		rc := &recordConn{Conn: conn}
+		connCh <- rc
+		tlsConn := tls.Server(rc, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MaxVersion:   tls.VersionTLS12,
+		})
+		defer tlsConn.Close()
+		b, _ := io.ReadAll(tlsConn)
+		dataCh <- b
+	}()
+	return connCh, dataCh
+}
+
+// waitRecords waits until rc has recorded n application data records.
+func waitRecords(t *testing.T, rc *recordConn, n int) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 5*time.Second; {
+		if got := len(rc.recordLengths()); got >= n {
+			if got > n {
+				t.Fatalf("Got %d records, want %d", got, n)
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("Got %d records, want %d", len(rc.recordLengths()), n)
+}
+
+// smallBuffers returns n buffers of size bytes and their concatenation.
+func smallBuffers(n, size int) (net.Buffers, []byte) {
+	var v net.Buffers
+	var all []byte
+	for i := 0; i < n; i++ {
+		b := []byte(fmt.Sprintf("%0*d", size, i))
+		v = append(v, b)
+		all = append(all, b...)
+	}
+	return v, all
+}
+
+func TestWriteBuffers(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	for _, tc := range []struct {
+		name    string
+		n, size int
+		records int
+	}{
+		{name: "small", n: 200, size: 10, records: 1},
+		// 100000 bytes are packed into one write of 65536 bytes and one of 34464 bytes.
+		{name: "large", n: 100, size: 1000, records: 7},
+		{name: "full writes", n: 2, size: 70000, records: 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+			connCh, dataCh := serveRecords(t, ln)
+			conn := dialListener(t, ln, &fipstls.Config{CaFile: testutils.CertPath})
+			defer conn.Close()
+			rc := <-connCh
+
+			v, want := smallBuffers(tc.n, tc.size)
+			n, err := conn.WriteBuffers(&v)
+			if err != nil || n != int64(len(want)) {
+				t.Fatalf("WriteBuffers() = %d, %v, want %d", n, err, len(want))
+			}
+			if len(v) != 0 {
+				t.Errorf("WriteBuffers() left %d buffers", len(v))
+			}
+			waitRecords(t, rc, tc.records)
+			conn.Close()
+			if got := <-dataCh; !bytes.Equal(got, want) {
+				t.Errorf("Server received %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	connCh, dataCh := serveRecords(t, ln)
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath},
+		append(getFipsDialOpts(), fipstls.WithWriteCoalescing(fipstls.WriteCoalescing{
+			Threshold: 1000,
+			Delay:     50 * time.Millisecond,
+		}))...)
+	nc, err := d.DialContext(context.Background(), "tcp4", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	conn := nc.(*fipstls.Conn)
+	defer conn.Close()
+	rc := <-connCh
+
+	var want []byte
+	write := func(b []byte) {
+		t.Helper()
+		if n, err := conn.Write(b); err != nil || n != len(b) {
+			t.Fatalf("Write() = %d, %v, want %d", n, err, len(b))
+		}
+		want = append(want, b...)
+	}
+
+	// Small writes are sent together once the delay expires.
+	v, _ := smallBuffers(10, 10)
+	for _, b := range v {
+		write(b)
+	}
+	waitRecords(t, rc, 1)
+
+	// Writes are sent whenever the threshold is reached, and the rest on Flush.
+	v, _ = smallBuffers(25, 100)
+	for _, b := range v {
+		write(b)
+	}
+	waitRecords(t, rc, 3)
+	if err := conn.Flush(); err != nil {
+		t.Fatalf("Flush() failed: %v", err)
+	}
+	waitRecords(t, rc, 4)
+
+	// Writes of at least the threshold are not buffered.
+	write(bytes.Repeat([]byte{'x'}, 1500))
+	waitRecords(t, rc, 5)
+
+	// Close sends the buffered data before the close_notify alert.
+	write([]byte("bye"))
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close() failed: %v", err)
+	}
+	if got := <-dataCh; !bytes.Equal(got, want) {
+		t.Errorf("Server received %q, want %q", got, want)
+	}
+	if got := len(rc.recordLengths()); got != 6 {
+		t.Errorf("Got %d records, want 6", got)
+	}
+}
+
+// TestWriteCoalescingFlushClose checks that Close during a scheduled flush waits for the flush
+// and sends the close_notify after the buffered data. The peer reads nothing until Close is
+// called, so the flush blocks on the full socket buffers.
+func TestWriteCoalescingFlushClose(t *testing.T) {
+	initTest(t)
+	defer testutils.LeakCheck(t)
+
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	start := make(chan struct{})
+	type result struct {
+		data []byte
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			results <- result{nil, err}
+			return
+		}
+		server := fipstls.Server(conn, &fipstls.Config{
+			CertFile: testutils.CertPath,
+			KeyFile:  testKeyPath,
+		})
+		defer server.Close()
+		server.SetDeadline(time.Now().Add(10 * time.Second))
+		if err := server.Handshake(time.Time{}); err != nil {
+			results <- result{nil, err}
+			return
+		}
+		<-start
+		data, err := io.ReadAll(server)
+		results <- result{data, err}
+	}()
+
+	d := fipstls.NewDialer(&fipstls.Config{CaFile: testutils.CertPath}, append(getFipsDialOpts(),
+		fipstls.WithWriteCoalescing(fipstls.WriteCoalescing{
+			Threshold: 8 << 20,
+			Delay:     10 * time.Millisecond,
+		}))...)
+	defer d.Close()
+	conn, err := d.DialContext(context.Background(), "tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	want := bytes.Repeat([]byte("0123456789abcdef"), 4<<20/16)
+	if _, err := conn.Write(want); err != nil {
+		t.Fatalf("Write() failed: %v", err)
+	}
+	// Close once the scheduled flush is blocked.
+	time.Sleep(100 * time.Millisecond)
+	closeErr := make(chan error, 1)
+	go func() { closeErr <- conn.Close() }()
+	time.Sleep(50 * time.Millisecond)
+	close(start)
+	res := <-results
+	if err := <-closeErr; err != nil {
+		t.Errorf("Close() failed: %v", err)
+	}
+	if res.err != nil {
+		t.Fatalf("Server read failed: %v", res.err)
+	}
+	if !bytes.Equal(res.data, want) {
+		t.Errorf("Server received %d bytes, want %d", len(res.data), len(want))
+	}
+}